@@ -0,0 +1,474 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"yom-kitchen/pkg/config"
+	connection "yom-kitchen/pkg/db"
+	"yom-kitchen/pkg/models"
+	"yom-kitchen/pkg/money"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// The integration tests boot the router built by main against a throwaway Postgres. They use the
+// server at TEST_DATABASE_URL (a postgres:// URL of a user allowed to create databases) or else
+// start one with the initdb and pg_ctl found in PG_BIN_DIR or on the PATH. Without either they are
+// skipped.
+
+const (
+	testJWTSecret   = "integration-test-secret"
+	adminPassword   = "admin-password"
+	cashierPassword = "cashier-password"
+)
+
+var errNoPostgres = errors.New("no Postgres available, set TEST_DATABASE_URL or put initdb and pg_ctl on the PATH")
+
+// testPostgres is the server the tests run against. Every harness gets its own database, copied
+// from the template database that was migrated once in TestMain.
+var testPostgres struct {
+	skip     string
+	url      *url.URL
+	admin    *gorm.DB
+	template string
+}
+
+var databaseCounter atomic.Int64
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+	if !testing.Verbose() {
+		log.SetOutput(io.Discard)
+	}
+	os.Exit(runTests(m))
+}
+
+func runTests(m *testing.M) int {
+	serverURL, stop, err := startPostgres()
+	if errors.Is(err, errNoPostgres) {
+		testPostgres.skip = err.Error()
+		return m.Run()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start Postgres: %v\n", err)
+		return 1
+	}
+	defer stop()
+
+	if err := createTemplateDatabase(serverURL); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create the template database: %v\n", err)
+		return 1
+	}
+	defer dropDatabase(testPostgres.template)
+	return m.Run()
+}
+
+// startPostgres returns the URL of the server to test against, starting one in a temporary
+// directory unless TEST_DATABASE_URL is set.
+func startPostgres() (*url.URL, func(), error) {
+	if raw := os.Getenv("TEST_DATABASE_URL"); raw != "" {
+		serverURL, err := url.Parse(raw)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid TEST_DATABASE_URL: %w", err)
+		}
+		return serverURL, func() {}, nil
+	}
+
+	initdb, pgCtl, ok := findPostgresBinaries()
+	if !ok {
+		return nil, nil, errNoPostgres
+	}
+	if os.Geteuid() == 0 {
+		return nil, nil, fmt.Errorf("%w: initdb refuses to run as root", errNoPostgres)
+	}
+
+	dir, err := os.MkdirTemp("", "yom-kitchen-postgres-")
+	if err != nil {
+		return nil, nil, err
+	}
+	dataDir := filepath.Join(dir, "data")
+	if out, err := exec.Command(initdb, "-D", dataDir, "-U", "postgres", "-A", "trust", "-E", "UTF8", "--no-sync").CombinedOutput(); err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, fmt.Errorf("initdb: %w\n%s", err, out)
+	}
+	port, err := freePort()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+	options := fmt.Sprintf("-p %d -c listen_addresses=127.0.0.1 -c unix_socket_directories=%s -c fsync=off", port, dir)
+	if out, err := exec.Command(pgCtl, "-D", dataDir, "-l", filepath.Join(dir, "postgres.log"), "-o", options, "-w", "start").CombinedOutput(); err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, fmt.Errorf("pg_ctl start: %w\n%s", err, out)
+	}
+
+	stop := func() {
+		exec.Command(pgCtl, "-D", dataDir, "-m", "immediate", "stop").Run()
+		os.RemoveAll(dir)
+	}
+	serverURL := &url.URL{
+		Scheme:   "postgres",
+		User:     url.User("postgres"),
+		Host:     net.JoinHostPort("127.0.0.1", strconv.Itoa(port)),
+		Path:     "/postgres",
+		RawQuery: "sslmode=disable",
+	}
+	return serverURL, stop, nil
+}
+
+func findPostgresBinaries() (initdb, pgCtl string, ok bool) {
+	var dirs []string
+	if dir := os.Getenv("PG_BIN_DIR"); dir != "" {
+		dirs = append(dirs, dir)
+	}
+	if path, err := exec.LookPath("initdb"); err == nil {
+		dirs = append(dirs, filepath.Dir(path))
+	}
+	installed, _ := filepath.Glob("/usr/lib/postgresql/*/bin")
+	dirs = append(dirs, installed...)
+
+	for _, dir := range dirs {
+		initdb, pgCtl = filepath.Join(dir, "initdb"), filepath.Join(dir, "pg_ctl")
+		if isExecutable(initdb) && isExecutable(pgCtl) {
+			return initdb, pgCtl, true
+		}
+	}
+	return "", "", false
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir() && info.Mode()&0111 != 0
+}
+
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// createTemplateDatabase creates the database every harness database is copied from, with the
+// schema and the system roles but no users.
+func createTemplateDatabase(serverURL *url.URL) error {
+	admin, err := gorm.Open(postgres.Open(serverURL.String()), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		return err
+	}
+	testPostgres.url = serverURL
+	testPostgres.admin = admin
+	testPostgres.template = fmt.Sprintf("yom_kitchen_test_%d_template", os.Getpid())
+
+	if err := admin.Exec("CREATE DATABASE " + testPostgres.template).Error; err != nil {
+		return err
+	}
+	db, err := openTestDatabase(testPostgres.template)
+	if err != nil {
+		return err
+	}
+	defer closeDatabase(db)
+	_, err = prepareDatabase(db, config.Auth{AdminUsername: "admin"})
+	return err
+}
+
+func databaseURL(name string) string {
+	u := *testPostgres.url
+	u.Path = "/" + name
+	return u.String()
+}
+
+func openTestDatabase(name string) (*gorm.DB, error) {
+	cfg := config.Default().Database
+	cfg.URL = databaseURL(name)
+	db, err := connection.InitializeDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+	db.Logger = logger.Discard
+	return db, nil
+}
+
+func closeDatabase(db *gorm.DB) {
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+}
+
+func dropDatabase(name string) {
+	testPostgres.admin.Exec("DROP DATABASE IF EXISTS " + name)
+}
+
+// harness is the API as main serves it, on a database of its own.
+type harness struct {
+	t          *testing.T
+	cfg        *config.Config
+	db         *gorm.DB
+	router     *gin.Engine
+	setupToken string
+	fixtures
+}
+
+// fixtures are the records newHarness seeds. The clients' Passcode fields hold their passcodes.
+type fixtures struct {
+	Admin           models.User
+	Cashier         models.User
+	Client          models.Client
+	OtherClient     models.Client
+	InactiveClient  models.Client
+	Menu            models.MenuItem
+	UnavailableMenu models.MenuItem
+}
+
+// newHarness boots the router on a new database seeded with the fixtures.
+func newHarness(t *testing.T) *harness {
+	return startHarness(t, true)
+}
+
+// newEmptyHarness boots the router on a new database without users, so that the admin account
+// has to be created through POST /setup.
+func newEmptyHarness(t *testing.T) *harness {
+	return startHarness(t, false)
+}
+
+func startHarness(t *testing.T, seed bool) *harness {
+	t.Helper()
+	if testPostgres.skip != "" {
+		t.Skip(testPostgres.skip)
+	}
+
+	name := fmt.Sprintf("yom_kitchen_test_%d_%d", os.Getpid(), databaseCounter.Add(1))
+	if err := testPostgres.admin.Exec("CREATE DATABASE " + name + " TEMPLATE " + testPostgres.template).Error; err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	t.Cleanup(func() { dropDatabase(name) })
+	db, err := openTestDatabase(name)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { closeDatabase(db) })
+
+	cfg := config.Default()
+	cfg.Database.URL = databaseURL(name)
+	cfg.Auth.JWTSecret = testJWTSecret
+	cfg.Uploads.Dir = t.TempDir()
+
+	h := &harness{t: t, cfg: cfg, db: db}
+	if seed {
+		h.fixtures = seedFixtures(t, db)
+	}
+	h.setupToken, err = prepareDatabase(db, cfg.Auth)
+	if err != nil {
+		t.Fatalf("Failed to prepare database: %v", err)
+	}
+	imageStore, err := newImageStore(cfg.Uploads)
+	if err != nil {
+		t.Fatalf("Failed to set up image store: %v", err)
+	}
+	h.router = newRouter(cfg, db, imageStore, h.setupToken)
+	return h
+}
+
+func seedFixtures(t *testing.T, db *gorm.DB) fixtures {
+	t.Helper()
+	var f fixtures
+	f.Admin = seedUser(t, db, "admin", adminPassword, true)
+	f.Cashier = seedUser(t, db, "cashier", cashierPassword, false)
+	var cashierRole models.Role
+	if err := db.Where("name = ?", models.RoleCashier).First(&cashierRole).Error; err != nil {
+		t.Fatalf("Failed to find cashier role: %v", err)
+	}
+	if err := db.Model(&f.Cashier).Association("Roles").Append(&cashierRole); err != nil {
+		t.Fatalf("Failed to give cashier role: %v", err)
+	}
+
+	f.Client = seedClient(t, db, "Abebe Kebede", "abebe@example.com", true)
+	f.OtherClient = seedClient(t, db, "Almaz Tesfaye", "almaz@example.com", true)
+	f.InactiveClient = seedClient(t, db, "Dawit Haile", "dawit@example.com", false)
+
+	f.Menu = seedMenuItem(t, db, "Doro Wat", "Mains", money.FromFloat(250), true)
+	f.UnavailableMenu = seedMenuItem(t, db, "Kitfo", "Mains", money.FromFloat(300), false)
+	return f
+}
+
+func seedUser(t *testing.T, db *gorm.DB, username, password string, isAdmin bool) models.User {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{Username: username, PasswordHash: string(hash), IsAdmin: isAdmin}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("Failed to seed user %s: %v", username, err)
+	}
+	return user
+}
+
+func seedClient(t *testing.T, db *gorm.DB, name, email string, active bool) models.Client {
+	t.Helper()
+	client := models.Client{Name: name, Email: email, IsActive: active}
+	if err := db.Create(&client).Error; err != nil {
+		t.Fatalf("Failed to seed client %s: %v", name, err)
+	}
+	return client
+}
+
+func seedMenuItem(t *testing.T, db *gorm.DB, name, category string, price money.Amount, available bool) models.MenuItem {
+	t.Helper()
+	item := models.MenuItem{Name: name, Category: category, Price: price, Available: true}
+	if err := db.Create(&item).Error; err != nil {
+		t.Fatalf("Failed to seed menu item %s: %v", name, err)
+	}
+	// Available defaults to true in the database, so a false value is not inserted.
+	if !available {
+		if err := db.Model(&item).UpdateColumn("available", false).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return item
+}
+
+// do sends a request with body encoded as JSON, authorized by token unless it is empty.
+func (h *harness) do(method, path, token string, body any) *httptest.ResponseRecorder {
+	h.t.Helper()
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			h.t.Fatal(err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return h.send(req, token)
+}
+
+// send sends a prepared request, authorized by token unless it is empty.
+func (h *harness) send(req *http.Request, token string) *httptest.ResponseRecorder {
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// login logs a staff user in with a password and returns the access token.
+func (h *harness) login(username, password string) string {
+	h.t.Helper()
+	rec := h.do(http.MethodPost, "/login", "", map[string]string{"username": username, "password": password})
+	expectStatus(h.t, rec, http.StatusOK)
+	return decode[tokenBody](h.t, rec).Token
+}
+
+func (h *harness) adminToken() string {
+	h.t.Helper()
+	return h.login(h.Admin.Username, adminPassword)
+}
+
+func (h *harness) cashierToken() string {
+	h.t.Helper()
+	return h.login(h.Cashier.Username, cashierPassword)
+}
+
+// clientLogin logs a client in with their email and passcode and returns the client token.
+func (h *harness) clientLogin(client models.Client) string {
+	h.t.Helper()
+	rec := h.do(http.MethodPost, "/client/login", "", map[string]string{"email": client.Email, "passcode": client.Passcode})
+	expectStatus(h.t, rec, http.StatusOK)
+	return decode[tokenBody](h.t, rec).Token
+}
+
+// latestOrder returns the most recently created order of a client.
+func (h *harness) latestOrder(clientID uint) models.Order {
+	h.t.Helper()
+	var order models.Order
+	if err := h.db.Where("client_id = ?", clientID).Order("id DESC").First(&order).Error; err != nil {
+		h.t.Fatalf("Failed to find order: %v", err)
+	}
+	return order
+}
+
+type tokenBody struct {
+	Token              string    `json:"token"`
+	ExpiresAt          time.Time `json:"expires_at"`
+	RefreshToken       string    `json:"refresh_token"`
+	MustChangePassword bool      `json:"must_change_password"`
+	MFARequired        bool      `json:"mfa_required"`
+	EnrollmentRequired bool      `json:"totp_enrollment_required"`
+	MFAToken           string    `json:"mfa_token"`
+}
+
+type errorBody struct {
+	Error struct {
+		Code      string `json:"code"`
+		Message   string `json:"message"`
+		RequestID string `json:"request_id"`
+		Fields    []struct {
+			Field string `json:"field"`
+			Code  string `json:"code"`
+		} `json:"fields"`
+		Details map[string]any `json:"details"`
+	} `json:"error"`
+}
+
+type listBody[T any] struct {
+	Items    []T   `json:"items"`
+	Total    int64 `json:"total"`
+	Page     int   `json:"page"`
+	PageSize int   `json:"page_size"`
+}
+
+func expectStatus(t *testing.T, rec *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if rec.Code != want {
+		t.Fatalf("Expected status %d, got %d: %s", want, rec.Code, rec.Body.String())
+	}
+}
+
+// expectError checks the status and the code of an error response.
+func expectError(t *testing.T, rec *httptest.ResponseRecorder, status int, code string) errorBody {
+	t.Helper()
+	expectStatus(t, rec, status)
+	body := decode[errorBody](t, rec)
+	if body.Error.Code != code {
+		t.Fatalf("Expected error code %q, got %q: %s", code, body.Error.Code, rec.Body.String())
+	}
+	return body
+}
+
+func decode[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+		t.Fatalf("Failed to decode response %q: %v", rec.Body.String(), err)
+	}
+	return v
+}
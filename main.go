@@ -1,163 +1,518 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 	"log"
+	"net/http"
+	"os"
+	"strconv"
 	"time"
+	"yom-kitchen/pkg/apierror"
+	"yom-kitchen/pkg/bruteforce"
+	"yom-kitchen/pkg/config"
 	connection "yom-kitchen/pkg/db"
+	"yom-kitchen/pkg/events"
 	"yom-kitchen/pkg/handlers"
 	"yom-kitchen/pkg/middlewares"
+	"yom-kitchen/pkg/migrate"
 	"yom-kitchen/pkg/models"
+	"yom-kitchen/pkg/repository"
+	"yom-kitchen/pkg/services"
+	"yom-kitchen/pkg/storage"
 )
 
-const uploadDirectory = "./uploads"
+// eventHistorySize is the number of order events kept for kitchen displays resuming a stream.
+const eventHistorySize = 1000
+
+// readHeaderTimeout is how long a client may take to send the headers of a request.
+const readHeaderTimeout = 10 * time.Second
+
+// loginFailureRetention is how long failed logins count against an account or IP address.
+const loginFailureRetention = time.Hour
 
 func main() {
-	router := gin.Default()
-	db, err := connection.InitializeDB()
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if cfg.InsecureJWTSecret() {
+		log.Println("WARNING: JWT_SECRET_KEY is not set. Using insecure default key!")
+	}
+	imageStore, err := newImageStore(cfg.Uploads)
+	if err != nil {
+		log.Fatalf("Failed to set up image store: %v", err)
+	}
+	db, err := connection.InitializeDB(cfg.Database)
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
+	setupToken, err := prepareDatabase(db, cfg.Auth)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	server := &http.Server{
+		Addr:              cfg.HTTP.Addr,
+		Handler:           newRouter(cfg, db, imageStore, setupToken),
+		ReadHeaderTimeout: readHeaderTimeout,
+		WriteTimeout:      cfg.HTTP.WriteTimeout,
+	}
+	log.Printf("Listening on %s", cfg.HTTP.Addr)
+	if err := server.ListenAndServe(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// prepareDatabase migrates the schema and creates the admin user and the system roles. It returns
+// the one-time setup token when no admin could be created.
+func prepareDatabase(db *gorm.DB, auth config.Auth) (string, error) {
+	migrator, err := newMigrator(db)
+	if err != nil {
+		return "", err
+	}
+	applied, err := migrator.Up(context.Background())
+	if err != nil {
+		return "", fmt.Errorf("failed to migrate database: %w", err)
+	}
+	for _, m := range applied {
+		log.Printf("Applied migration %s.", m)
+	}
+	log.Println("Database migration completed.")
+
+	setupToken, err := setupAdminUser(db, auth) // Create admin user if not present
+	if err != nil {
+		return "", fmt.Errorf("error setting up admin user: %w", err)
+	}
+	log.Println("Admin user setup completed (if needed).")
+
+	if err := setupRoles(db); err != nil {
+		return "", fmt.Errorf("error setting up roles: %w", err)
+	}
+	return setupToken, nil
+}
+
+// runMigrate runs the migrate subcommand: "migrate up", "migrate down [steps]" or "migrate status",
+// followed by the usual configuration flags.
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate up|down [steps]|status [flags]")
+	}
+	action, args := args[0], args[1:]
+	steps := 1
+	if action == "down" && len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid number of steps %q", args[0])
+		}
+		steps, args = n, args[1:]
+	}
+
+	cfg, err := config.Load(args)
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	db, err := connection.InitializeDB(cfg.Database)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	migrator, err := newMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch action {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			log.Printf("Applied migration %s.", m)
+		}
+		return err
+	case "down":
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			log.Printf("Reverted migration %s.", m)
+		}
+		return err
+	case "status":
+		states, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, state := range states {
+			appliedAt := "pending"
+			if state.AppliedAt != nil {
+				appliedAt = "applied " + state.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%s\t%s\n", state.Migration, appliedAt)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q, want up, down or status", action)
+	}
+}
+
+// newMigrator returns the migrator for the embedded migrations. Databases created before
+// migrations existed are brought up to the baseline by upgradeLegacySchema.
+func newMigrator(db *gorm.DB) (*migrate.Migrator, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	migrator, err := migrate.New(sqlDB, func(ctx context.Context) error {
+		return upgradeLegacySchema(db.WithContext(ctx))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+	return migrator, nil
+}
+
+// upgradeLegacySchema brings a schema created by AutoMigrate, possibly by an old release, up to
+// the baseline migration.
+func upgradeLegacySchema(db *gorm.DB) error {
+	if err := connection.ConvertMoneyColumns(db); err != nil {
+		return fmt.Errorf("failed to convert money columns: %w", err)
+	}
+	if err := connection.HashClientPasscodes(db); err != nil {
+		return fmt.Errorf("failed to hash client passcodes: %w", err)
+	}
+	if err := connection.MenuImageKeys(db); err != nil {
+		return fmt.Errorf("failed to convert menu image URLs to keys: %w", err)
+	}
+	if err := connection.CompleteLegacySchema(db); err != nil {
+		return fmt.Errorf("failed to complete the schema: %w", err)
+	}
+	return nil
+}
+
+// newRouter builds the router serving the API. POST /setup is only routed when there is a setup token.
+func newRouter(cfg *config.Config, db *gorm.DB, imageStore storage.ImageStore, setupToken string) *gin.Engine {
+	router := gin.New()
+	// Only the configured proxies may set the client IP with X-Forwarded-For, otherwise anyone
+	// could choose the IP seen by the login limiter, the sessions and the audit log.
+	if err := router.SetTrustedProxies(cfg.HTTP.TrustedProxies); err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
+	}
+	router.Use(middlewares.RequestIDMiddleware(), gin.Logger(), gin.CustomRecovery(func(c *gin.Context, recovered any) {
+		apierror.Internal(c, fmt.Errorf("panic: %v", recovered), "Internal server error")
+	}))
+	router.NoRoute(func(c *gin.Context) {
+		apierror.NotFound(c, "No such endpoint")
+	})
+	router.Use(middlewares.ConfigMiddleware(cfg))
 	router.Use(middlewares.DatabaseMiddleware(db))
+	bus := events.NewBus(eventHistorySize)
+	router.Use(middlewares.EventBusMiddleware(bus))
+	router.Use(middlewares.LoginLimiterMiddleware(newLoginLimiter(db, cfg.Login)))
+	router.Use(middlewares.ServicesMiddleware(newServices(db, cfg.Business, imageStore, bus)))
 	router.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"*"},
+		AllowOrigins:     cfg.HTTP.CORSOrigins,
 		AllowMethods:     []string{"PUT", "PATCH", "POST", "GET", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization", "Access-Control-Allow-Origin"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization", "Access-Control-Allow-Origin", middlewares.IdempotencyKeyHeader, apierror.RequestIDHeader},
+		ExposeHeaders:    []string{"Content-Length", middlewares.IdempotentReplayedHeader, apierror.RequestIDHeader},
 		AllowCredentials: true,
 		AllowOriginFunc: func(origin string) bool {
 			return origin == "*"
 		},
 		MaxAge: 12 * time.Hour,
 	}))
-	err = db.AutoMigrate(&models.User{}, &models.MenuItem{}, &models.Client{}, &models.Order{}, &models.OrderItem{})
-	if err != nil {
-		log.Fatalf("Failed to auto-migrate database: %v", err)
-		return
-	}
-	log.Println("Database migration completed.")
 
-	err = setupAdminUser(db) // Create admin user if not present
-	if err != nil {
-		log.Fatalf("Error setting up admin user: %v", err)
-		return
+	if cfg.Uploads.Store == "local" {
+		router.Static(cfg.Uploads.BaseURL, cfg.Uploads.Dir)
 	}
-	log.Println("Admin user setup completed (if needed).")
-
-	router.Static("/uploads", uploadDirectory)
 	adminGroup := router.Group("/admin")
-	adminGroup.Use(middlewares.AuthenticationMiddleware())
-	adminGroup.Use(middlewares.AdminAuthorizationMiddleware())
+	adminGroup.Use(middlewares.AuthenticationMiddleware(), middlewares.RequirePasswordChanged())
 	{
 		stats := adminGroup.Group("/stats")
 		{
-			stats.GET("", handlers.GetStatsAdmin)
+			stats.GET("", middlewares.RequirePermission(models.PermissionStatsRead), handlers.GetStatsAdmin)
+			stats.GET("/sales", middlewares.RequirePermission(models.PermissionStatsRead), handlers.GetSalesStatsAdmin)
+		}
+
+		adminGroup.GET("/audit", middlewares.RequirePermission(models.PermissionAuditRead), handlers.GetAuditLogAdmin)
+		adminGroup.GET("/permissions", middlewares.RequirePermission(models.PermissionRolesManage), handlers.GetPermissionsAdmin)
+
+		roles := adminGroup.Group("/roles")
+		{
+			roles.GET("", middlewares.RequirePermission(models.PermissionRolesManage), handlers.GetAllRolesAdmin)
+			roles.POST("", middlewares.RequirePermission(models.PermissionRolesManage), handlers.CreateRoleAdmin)
+			roles.GET("/:id", middlewares.RequirePermission(models.PermissionRolesManage), handlers.GetRoleAdmin)
+			roles.PUT("/:id", middlewares.RequirePermission(models.PermissionRolesManage), handlers.UpdateRoleAdmin)
+			roles.DELETE("/:id", middlewares.RequirePermission(models.PermissionRolesManage), handlers.DeleteRoleAdmin)
 		}
+
 		users := adminGroup.Group("/users")
 		{
-			users.POST("", handlers.CreateUserAdmin)
-			users.GET("/:id", handlers.GetUserAdmin)
-			users.GET("", handlers.GetAllUsersAdmin)
-			users.PUT("/:id", handlers.UpdateUserAdmin)
-			users.DELETE("/:id", handlers.DeleteUserAdmin)
+			users.POST("", middlewares.RequirePermission(models.PermissionUsersManage), handlers.CreateUserAdmin)
+			users.GET("/:id", middlewares.RequirePermission(models.PermissionUsersRead), handlers.GetUserAdmin)
+			users.GET("", middlewares.RequirePermission(models.PermissionUsersRead), handlers.GetAllUsersAdmin)
+			users.PUT("/:id", middlewares.RequirePermission(models.PermissionUsersManage), handlers.UpdateUserAdmin)
+			users.DELETE("/:id", middlewares.RequirePermission(models.PermissionUsersManage), handlers.DeleteUserAdmin)
+			users.GET("/:id/sessions", middlewares.RequirePermission(models.PermissionUsersManage), handlers.GetUserSessionsAdmin)
+			users.DELETE("/:id/sessions", middlewares.RequirePermission(models.PermissionUsersManage), handlers.RevokeUserSessionsAdmin)
+			users.DELETE("/:id/sessions/:sessionId", middlewares.RequirePermission(models.PermissionUsersManage), handlers.RevokeUserSessionAdmin)
+			users.PUT("/:id/roles", middlewares.RequirePermission(models.PermissionRolesManage), handlers.SetUserRolesAdmin)
+			users.DELETE("/:id/lockout", middlewares.RequirePermission(models.PermissionUsersManage), handlers.UnlockUserLoginAdmin)
+			users.DELETE("/:id/totp", middlewares.RequirePermission(models.PermissionUsersManage), handlers.ResetUserTOTPAdmin)
 		}
 
 		menus := adminGroup.Group("/menus")
 		{
-			menus.POST("", handlers.CreateMenuAdmin)
-			menus.GET("", handlers.GetAllMenusAdmin)
-			menus.GET("/:id", handlers.GetMenuByIdAdmin)
-			menus.PUT("/:id", handlers.UpdateMenuAdmin)
-			menus.DELETE("/:id", handlers.DeleteMenuAdmin)
-			menus.PATCH("/:id", handlers.UpdateMenuItemAvailabilityAdmin)
+			menus.POST("", middlewares.RequirePermission(models.PermissionMenusManage), handlers.CreateMenuAdmin)
+			menus.GET("", middlewares.RequirePermission(models.PermissionMenusRead), handlers.GetAllMenusAdmin)
+			menus.GET("/:id", middlewares.RequirePermission(models.PermissionMenusRead), handlers.GetMenuByIdAdmin)
+			menus.PUT("/:id", middlewares.RequirePermission(models.PermissionMenusManage), handlers.UpdateMenuAdmin)
+			menus.DELETE("/:id", middlewares.RequirePermission(models.PermissionMenusManage), handlers.DeleteMenuAdmin)
+			menus.PATCH("/:id", middlewares.RequirePermission(models.PermissionMenusAvailability), handlers.UpdateMenuItemAvailabilityAdmin)
+			menus.GET("/:id/recipe", middlewares.RequirePermission(models.PermissionInventoryRead), handlers.GetMenuRecipeAdmin)
+			menus.PUT("/:id/recipe", middlewares.RequirePermission(models.PermissionInventoryManage), handlers.SetMenuRecipeAdmin)
+		}
+
+		inventory := adminGroup.Group("/inventory")
+		{
+			inventory.GET("/ingredients", middlewares.RequirePermission(models.PermissionInventoryRead), handlers.GetAllIngredientsAdmin)
+			inventory.POST("/ingredients", middlewares.RequirePermission(models.PermissionInventoryManage), handlers.CreateIngredientAdmin)
+			inventory.PUT("/ingredients/:id", middlewares.RequirePermission(models.PermissionInventoryManage), handlers.UpdateIngredientAdmin)
+			inventory.DELETE("/ingredients/:id", middlewares.RequirePermission(models.PermissionInventoryManage), handlers.DeleteIngredientAdmin)
+			inventory.POST("/ingredients/:id/adjustments", middlewares.RequirePermission(models.PermissionInventoryManage), handlers.AdjustIngredientStockAdmin)
+			inventory.POST("/ingredients/:id/deliveries", middlewares.RequirePermission(models.PermissionInventoryManage), handlers.RecordIngredientDeliveryAdmin)
+			inventory.GET("/ingredients/:id/movements", middlewares.RequirePermission(models.PermissionInventoryRead), handlers.GetIngredientMovementsAdmin)
+			inventory.GET("/low-stock", middlewares.RequirePermission(models.PermissionInventoryRead), handlers.GetLowStockAdmin)
+		}
+
+		taxRates := adminGroup.Group("/tax-rates")
+		{
+			taxRates.GET("", middlewares.RequirePermission(models.PermissionTaxRatesManage), handlers.GetAllTaxRatesAdmin)
+			taxRates.PUT("/:category", middlewares.RequirePermission(models.PermissionTaxRatesManage), handlers.SetCategoryTaxRateAdmin)
+			taxRates.DELETE("/:category", middlewares.RequirePermission(models.PermissionTaxRatesManage), handlers.DeleteCategoryTaxRateAdmin)
+		}
+
+		promos := adminGroup.Group("/promos")
+		{
+			promos.POST("", middlewares.RequirePermission(models.PermissionPromosManage), handlers.CreatePromoAdmin)
+			promos.GET("", middlewares.RequirePermission(models.PermissionPromosManage), handlers.GetAllPromosAdmin)
+			promos.GET("/:id", middlewares.RequirePermission(models.PermissionPromosManage), handlers.GetPromoAdmin)
+			promos.PUT("/:id", middlewares.RequirePermission(models.PermissionPromosManage), handlers.UpdatePromoAdmin)
+			promos.DELETE("/:id", middlewares.RequirePermission(models.PermissionPromosManage), handlers.DeletePromoAdmin)
 		}
 
 		clients := adminGroup.Group("/clients")
 		{
-			clients.POST("", handlers.CreateClientAdmin)
-			clients.GET("", handlers.GetAllClientsAdmin)
-			clients.GET("/:id", handlers.GetClientByIdAdmin)
-			clients.PUT("/:id", handlers.UpdateClient)
-			clients.DELETE("/:id", handlers.DeleteClientAdmin)
-			clients.PATCH("/:id", handlers.UpdateClientStatusAdmin)
+			clients.POST("", middlewares.RequirePermission(models.PermissionClientsManage), handlers.CreateClientAdmin)
+			clients.GET("", middlewares.RequirePermission(models.PermissionClientsRead), handlers.GetAllClientsAdmin)
+			clients.GET("/:id", middlewares.RequirePermission(models.PermissionClientsRead), handlers.GetClientByIdAdmin)
+			clients.PUT("/:id", middlewares.RequirePermission(models.PermissionClientsManage), handlers.UpdateClient)
+			clients.DELETE("/:id", middlewares.RequirePermission(models.PermissionClientsManage), handlers.DeleteClientAdmin)
+			clients.PATCH("/:id", middlewares.RequirePermission(models.PermissionClientsManage), handlers.UpdateClientStatusAdmin)
+			clients.POST("/:id/passcode", middlewares.RequirePermission(models.PermissionClientsManage), handlers.RotateClientPasscodeAdmin)
+			clients.POST("/:id/login-link", middlewares.RequirePermission(models.PermissionClientsManage), handlers.CreateClientLoginLinkAdmin)
+			clients.DELETE("/:id/lockout", middlewares.RequirePermission(models.PermissionClientsManage), handlers.UnlockClientLoginAdmin)
 		}
 
 		orders := adminGroup.Group("/orders")
 		{
-			orders.POST("", handlers.CreateOrderAdmin)
-			orders.GET("/:id", handlers.GetOrderAdmin)
-			orders.GET("", handlers.GetAllOrdersAdmin)
-			orders.DELETE("/:id", handlers.DeleteOrderAdmin)
-			orders.PUT("/:id/status", handlers.UpdateOrderStatusAdmin)
+			orders.POST("", middlewares.RequirePermission(models.PermissionOrdersCreate), middlewares.Idempotency(), handlers.CreateOrderAdmin)
+			orders.GET("/:id", middlewares.RequirePermission(models.PermissionOrdersRead), handlers.GetOrderAdmin)
+			orders.GET("", middlewares.RequirePermission(models.PermissionOrdersRead), handlers.GetAllOrdersAdmin)
+			orders.GET("/stream", middlewares.RequirePermission(models.PermissionOrdersRead), handlers.StreamOrdersAdmin)
+			orders.GET("/export", middlewares.RequirePermission(models.PermissionOrdersExport), handlers.ExportOrdersAdmin)
+			orders.DELETE("/:id", middlewares.RequirePermission(models.PermissionOrdersDelete), handlers.DeleteOrderAdmin)
+			orders.PUT("/:id/status", middlewares.RequirePermission(models.PermissionOrdersUpdate), handlers.UpdateOrderStatusAdmin)
+			orders.GET("/:id/history", middlewares.RequirePermission(models.PermissionOrdersRead), handlers.GetOrderStatusHistoryAdmin)
+			orders.GET("/:id/receipt.pdf", middlewares.RequirePermission(models.PermissionOrdersRead), handlers.GetOrderReceiptAdmin)
 		}
 
 	}
 
 	clientRoutes := router.Group("/client")
 	{
-		clientRoutes.POST("/orders", handlers.ClientCreateOrderHandler)
-		clientRoutes.GET("/orders", handlers.ClientGetOrdersHandler)
+		clientRoutes.POST("/login", handlers.ClientLogin)
 		clientRoutes.GET("/menus", handlers.GetActiveMenus)
+
+		authenticatedClientRoutes := clientRoutes.Group("")
+		authenticatedClientRoutes.Use(middlewares.ClientAuthenticationMiddleware())
+		{
+			authenticatedClientRoutes.POST("/orders", middlewares.Idempotency(), handlers.ClientCreateOrderHandler)
+			authenticatedClientRoutes.GET("/orders", handlers.ClientGetOrdersHandler)
+			authenticatedClientRoutes.GET("/orders/:id/receipt.pdf", handlers.ClientGetOrderReceiptHandler)
+			authenticatedClientRoutes.POST("/passcode", handlers.ClientRotatePasscodeHandler)
+		}
 	}
-	router.POST("/login", handlers.Login)
-	err = router.Run(":8080")
-	if err != nil {
-		return
+	if setupToken != "" {
+		router.POST("/setup", handlers.Setup(setupToken))
 	}
+	router.POST("/login", handlers.Login)
+	router.POST("/login/totp", handlers.LoginTOTP)
+	router.POST("/login/totp/enroll", handlers.BeginLoginTOTPEnrollment)
+	router.POST("/login/totp/enroll/verify", handlers.ConfirmLoginTOTPEnrollment)
+	router.POST("/refresh", handlers.RefreshToken)
+	router.POST("/logout", middlewares.AuthenticationMiddleware(), handlers.Logout)
 
+	accountRoutes := router.Group("/account")
+	accountRoutes.Use(middlewares.AuthenticationMiddleware())
+	{
+		accountRoutes.POST("/password", handlers.ChangePassword)
+		accountRoutes.POST("/totp", handlers.BeginTOTPEnrollment)
+		accountRoutes.POST("/totp/verify", handlers.ConfirmTOTPEnrollment)
+		accountRoutes.POST("/totp/recovery-codes", handlers.RegenerateRecoveryCodes)
+		accountRoutes.POST("/totp/disable", handlers.DisableTOTP)
+	}
+	return router
 }
 
-func setupAdminUser(db *gorm.DB) error {
+// setupAdminUser makes sure an admin account can be reached. Without one, an admin is created with
+// the initial password from the configuration, which has to be changed at the first login, or else a
+// one-time setup token is returned for POST /setup. An existing admin still using the old default
+// password has to change it.
+func setupAdminUser(db *gorm.DB, auth config.Auth) (string, error) {
 	var existingAdminUser models.User
 	result := db.Where("is_admin = ?", true).First(&existingAdminUser)
 	if result.Error == nil { // Admin user already exists
 		log.Println("Admin user already exists, skipping creation.")
-		return nil
+		if !existingAdminUser.MustChangePassword &&
+			bcrypt.CompareHashAndPassword([]byte(existingAdminUser.PasswordHash), []byte("admin")) == nil {
+			log.Printf("WARNING: admin user '%s' still has the default password, it must be changed at the next login.", existingAdminUser.Username)
+			return "", db.Model(&existingAdminUser).UpdateColumn("must_change_password", true).Error
+		}
+		return "", nil
 	}
 	if !errors.Is(result.Error, gorm.ErrRecordNotFound) { // Actual database error
-		return result.Error
+		return "", result.Error
 	}
 
-	var userRequest struct {
-		Username string `json:"username" binding:"required"`
-		Password string `json:"password" binding:"required"`
-		IsAdmin  bool   `json:"is_admin"`
+	password := auth.AdminInitialPassword
+	if password == "" {
+		tokenBytes := make([]byte, 16)
+		if _, err := rand.Read(tokenBytes); err != nil {
+			return "", err
+		}
+		setupToken := hex.EncodeToString(tokenBytes)
+		log.Printf("No admin user exists. Create one with POST /setup using the one-time setup token: %s", setupToken)
+		return setupToken, nil
 	}
-	userRequest.Username = "admin"
-	userRequest.IsAdmin = true
-	userRequest.Password = "admin"
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(userRequest.Password), bcrypt.DefaultCost)
+
+	username := auth.AdminUsername
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
-		log.Printf("Error creating User")
-		return nil
+		return "", err
 	}
 
 	var existingUser models.User
-	result = db.Unscoped().Where("username = ?", userRequest.Username).First(&existingUser)
+	result = db.Unscoped().Where("username = ?", username).First(&existingUser)
 	if result.Error == nil {
-		log.Printf("Error creating User")
-		return nil
+		return "", errors.New("cannot create admin user: username '" + username + "' is taken, set ADMIN_USERNAME")
 	}
 	if !errors.Is(result.Error, gorm.ErrRecordNotFound) {
-		log.Printf("Error creating User")
-		return nil
+		return "", result.Error
 	}
 
 	newUser := models.User{
-		Username:     userRequest.Username,
-		PasswordHash: string(hashedPassword),
-		IsAdmin:      userRequest.IsAdmin,
+		Username:           username,
+		PasswordHash:       string(hashedPassword),
+		IsAdmin:            true,
+		MustChangePassword: true,
 	}
 
-	createResult := db.Create(&newUser)
-	if createResult.Error != nil {
-		log.Printf("Error creating User")
-		return nil
+	if err := db.Create(&newUser).Error; err != nil {
+		return "", err
+	}
+	log.Printf("Admin user '%s' created successfully (ID: %d), the password must be changed at the first login.", newUser.Username, newUser.ID)
+	return "", nil
+}
+
+// setupRoles creates the system roles that do not exist yet. Existing roles are left as they are,
+// so that changes made through the API are kept.
+func setupRoles(db *gorm.DB) error {
+	for _, role := range models.DefaultRoles() {
+		var existingRole models.Role
+		result := db.Where("name = ?", role.Name).First(&existingRole)
+		if result.Error == nil {
+			continue
+		}
+		if !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return result.Error
+		}
+		if err := db.Create(&role).Error; err != nil {
+			return err
+		}
+		log.Printf("Role '%s' created.", role.Name)
 	}
-	log.Printf("Admin user '%s' created successfully (ID: %d).", newUser.Username, newUser.ID)
 	return nil
 }
+
+// newLoginLimiter builds the limiter for failed staff and client logins. Counters are kept in
+// memory unless the limiter store is "postgres", which shares them between instances.
+func newLoginLimiter(db *gorm.DB, login config.Login) *bruteforce.Limiter {
+	account := bruteforce.Policy{
+		MaxFailures:     login.MaxFailures,
+		LockoutDuration: login.LockoutDuration,
+		BaseDelay:       time.Second,
+		MaxDelay:        30 * time.Second,
+		ResetAfter:      loginFailureRetention,
+	}
+	ip := bruteforce.Policy{
+		MaxFailures:     login.IPMaxFailures,
+		LockoutDuration: login.LockoutDuration,
+		ResetAfter:      loginFailureRetention,
+	}
+
+	var store bruteforce.Store = bruteforce.NewMemoryStore(loginFailureRetention)
+	if login.LimiterStore == "postgres" {
+		store = bruteforce.NewPostgresStore(db)
+	}
+	return bruteforce.NewLimiter(store, map[bruteforce.Scope]bruteforce.Policy{
+		bruteforce.ScopeUser:   account,
+		bruteforce.ScopeClient: account,
+		bruteforce.ScopeIP:     ip,
+	})
+}
+
+// newServices builds the services over the Postgres repositories.
+func newServices(db *gorm.DB, business config.Business, imageStore storage.ImageStore, bus *events.Bus) *services.Services {
+	return services.New(services.Repositories{
+		Transactor: repository.NewPostgresTransactor(db),
+		Orders:     repository.NewPostgresOrderRepository(db),
+		Menu:       repository.NewPostgresMenuRepository(db),
+		Clients:    repository.NewPostgresClientRepository(db),
+		Users:      repository.NewPostgresUserRepository(db),
+		Promos:     repository.NewPostgresPromoRepository(db),
+		Inventory:  repository.NewPostgresInventoryRepository(db),
+		Invoices:   repository.NewPostgresInvoiceRepository(db),
+		Audit:      repository.NewPostgresAuditRepository(db),
+	}, business, imageStore, bus)
+}
+
+func newImageStore(uploads config.Uploads) (storage.ImageStore, error) {
+	if uploads.Store == "s3" {
+		return storage.NewS3Store(storage.S3Options{
+			Endpoint:        uploads.S3.Endpoint,
+			Region:          uploads.S3.Region,
+			Bucket:          uploads.S3.Bucket,
+			AccessKeyID:     uploads.S3.AccessKeyID,
+			SecretAccessKey: uploads.S3.SecretAccessKey,
+			UseSSL:          uploads.S3.UseSSL,
+			PublicBaseURL:   uploads.S3.PublicBaseURL,
+			URLExpiry:       uploads.S3.URLExpiry,
+		})
+	}
+	if err := os.MkdirAll(uploads.Dir, 0755); err != nil {
+		return nil, err
+	}
+	return storage.NewLocalStore(uploads.Dir, uploads.BaseURL), nil
+}
@@ -0,0 +1,118 @@
+// Package apierror writes error responses in the one shape every endpoint uses:
+//
+//	{"error": {"code": "not_found", "message": "Order not found", "request_id": "..."}}
+//
+// The code is stable and meant for programs; the message is for people and may change. Requests
+// rejected by binding also list the invalid fields. Internal errors are logged with the request ID
+// and answered with a generic message, so that database and other internal errors never reach
+// clients.
+package apierror
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the ID of a request, which is also included in its error responses so
+// that they can be matched with the server logs.
+const RequestIDHeader = "X-Request-ID"
+
+const (
+	CodeBadRequest      = "bad_request"
+	CodeValidation      = "validation_failed"
+	CodeUnauthorized    = "unauthorized"
+	CodeInvalidToken    = "invalid_token"
+	CodeForbidden       = "forbidden"
+	CodeNotFound        = "not_found"
+	CodeConflict        = "conflict"
+	CodePayloadTooLarge = "payload_too_large"
+	CodeTooManyRequests = "too_many_requests"
+	CodeInternal        = "internal_error"
+
+	CodePasswordChangeRequired   = "password_change_required"
+	CodePasscodeRotationRequired = "passcode_rotation_required"
+	CodeTOTPEnrollmentRequired   = "totp_enrollment_required"
+	CodeIdempotencyKeyReused     = "idempotency_key_reused"
+	CodeRequestInProgress        = "request_in_progress"
+	CodeInvalidStatusTransition  = "invalid_status_transition"
+)
+
+// FieldError describes one invalid field of a request. Code is the failed validation rule, such
+// as required or min.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type Error struct {
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"`
+	// Details holds extra information specific to the code, such as retry_after.
+	Details   map[string]any `json:"details,omitempty"`
+	RequestID string         `json:"request_id,omitempty"`
+}
+
+type envelope struct {
+	Error Error `json:"error"`
+}
+
+// Abort responds with the error and stops the remaining handlers.
+func Abort(c *gin.Context, status int, e Error) {
+	e.RequestID = c.Writer.Header().Get(RequestIDHeader)
+	c.AbortWithStatusJSON(status, envelope{Error: e})
+}
+
+func Respond(c *gin.Context, status int, code, message string) {
+	Abort(c, status, Error{Code: code, Message: message})
+}
+
+func BadRequest(c *gin.Context, message string) {
+	Respond(c, http.StatusBadRequest, CodeBadRequest, message)
+}
+
+func Unauthorized(c *gin.Context, message string) {
+	Respond(c, http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+func Forbidden(c *gin.Context, message string) {
+	Respond(c, http.StatusForbidden, CodeForbidden, message)
+}
+
+func NotFound(c *gin.Context, message string) {
+	Respond(c, http.StatusNotFound, CodeNotFound, message)
+}
+
+func Conflict(c *gin.Context, message string) {
+	Respond(c, http.StatusConflict, CodeConflict, message)
+}
+
+func PayloadTooLarge(c *gin.Context, message string) {
+	Respond(c, http.StatusRequestEntityTooLarge, CodePayloadTooLarge, message)
+}
+
+// InvalidToken logs why a token was rejected and responds with 401 Unauthorized. The reason is
+// left out of the response, so that callers cannot probe how tokens are checked.
+func InvalidToken(c *gin.Context, err error) {
+	logRequest(c, err, "Invalid or expired token")
+	Respond(c, http.StatusUnauthorized, CodeInvalidToken, "Invalid or expired token")
+}
+
+// Internal logs err, which may be nil, and responds with 500 Internal Server Error and message,
+// which must not include err.
+func Internal(c *gin.Context, err error, message string) {
+	logRequest(c, err, message)
+	Respond(c, http.StatusInternalServerError, CodeInternal, message)
+}
+
+func logRequest(c *gin.Context, err error, message string) {
+	requestID := c.Writer.Header().Get(RequestIDHeader)
+	if err != nil {
+		log.Printf("request %s %s %s: %s: %v", requestID, c.Request.Method, c.Request.URL.Path, message, err)
+	} else {
+		log.Printf("request %s %s %s: %s", requestID, c.Request.Method, c.Request.URL.Path, message)
+	}
+}
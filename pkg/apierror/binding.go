@@ -0,0 +1,133 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Validation errors name fields as clients send them, by their json or form tag.
+func init() {
+	validate, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{"json", "form"} {
+			if name := strings.Split(field.Tag.Get(tag), ",")[0]; name != "" && name != "-" {
+				return name
+			}
+		}
+		return field.Name
+	})
+}
+
+// Bind responds to an error from binding a request: with the invalid fields when validation
+// failed, with 413 Payload Too Large when the body went over its limit, or with 400 Bad Request
+// when the body could not be decoded.
+func Bind(c *gin.Context, err error) {
+	var validationErrors validator.ValidationErrors
+	var typeError *json.UnmarshalTypeError
+	var syntaxError *json.SyntaxError
+	var maxBytesError *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesError):
+		PayloadTooLarge(c, fmt.Sprintf("Request body too large, the maximum is %d bytes", maxBytesError.Limit))
+	case errors.As(err, &validationErrors):
+		fields := make([]FieldError, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			fields[i] = newFieldError(fieldError)
+		}
+		Invalid(c, fields...)
+	case errors.As(err, &typeError):
+		Invalid(c, FieldError{
+			Field:   typeError.Field,
+			Code:    "type",
+			Message: fmt.Sprintf("%s must be a %s", typeError.Field, typeName(typeError.Type)),
+		})
+	case errors.As(err, &syntaxError), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		BadRequest(c, "Request body is not valid JSON")
+	default:
+		BadRequest(c, "Invalid request body: "+err.Error())
+	}
+}
+
+// Invalid responds with 400 Bad Request listing the invalid fields.
+func Invalid(c *gin.Context, fields ...FieldError) {
+	message := "The request has invalid fields"
+	if len(fields) == 1 {
+		message = fields[0].Message
+	}
+	Abort(c, http.StatusBadRequest, Error{Code: CodeValidation, Message: message, Fields: fields})
+}
+
+func newFieldError(fieldError validator.FieldError) FieldError {
+	// The namespace starts with the name of the bound struct, which means nothing to clients.
+	field := fieldError.Namespace()
+	if i := strings.Index(field, "."); i >= 0 {
+		field = field[i+1:]
+	}
+
+	param := fieldError.Param()
+	var rule string
+	switch fieldError.Tag() {
+	case "required", "required_if", "required_unless", "required_with", "required_without":
+		rule = "is required"
+	case "email":
+		rule = "must be a valid email address"
+	case "oneof":
+		rule = "must be one of " + strings.Join(strings.Fields(param), ", ")
+	case "min", "gte":
+		rule = "must be at least " + param + lengthUnit(fieldError.Kind())
+	case "max", "lte":
+		rule = "must be at most " + param + lengthUnit(fieldError.Kind())
+	case "gt":
+		rule = "must be greater than " + param + lengthUnit(fieldError.Kind())
+	case "lt":
+		rule = "must be less than " + param + lengthUnit(fieldError.Kind())
+	case "len":
+		rule = "must be exactly " + param + lengthUnit(fieldError.Kind())
+	default:
+		rule = "is invalid"
+	}
+	return FieldError{Field: field, Code: fieldError.Tag(), Message: field + " " + rule}
+}
+
+// lengthUnit says what the limit of a min or max rule counts: characters of a string, items of
+// a collection, or the value itself for numbers.
+func lengthUnit(kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return " items"
+	}
+	return ""
+}
+
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "whole number"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "list"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	}
+	return t.String()
+}
@@ -0,0 +1,118 @@
+// Package audit builds the entries of the audit log from the state of an entity before and after
+// a change.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+
+	"yom-kitchen/pkg/models"
+)
+
+// redactedFields are recorded as changed without their values.
+var redactedFields = []string{"password", "password_hash", "passcode", "passcode_hash", "token", "refresh_token"}
+
+// ignoredFields change on every update and would only add noise to the diff.
+var ignoredFields = []string{"updated_at", "UpdatedAt"}
+
+const redacted = "[redacted]"
+
+// Actor is who makes a change: a staff user, a client, or nobody for changes made by the system.
+type Actor struct {
+	UserID    *uint
+	Username  string
+	ClientID  *uint
+	IPAddress string
+}
+
+// NewEntry builds the audit log entry for a change made by actor. before is nil for creations and
+// after is nil for deletions; both are compared by their JSON representation. It returns false
+// for updates that changed nothing, which are not recorded.
+func NewEntry(actor Actor, action, entityType string, entityID any, before, after any) (models.AuditLog, bool, error) {
+	changes, err := diff(before, after)
+	if err != nil {
+		return models.AuditLog{}, false, fmt.Errorf("computing changes: %w", err)
+	}
+	if action == models.AuditActionUpdate && len(changes) == 0 {
+		return models.AuditLog{}, false, nil
+	}
+	changesJSON, err := json.Marshal(changes)
+	if err != nil {
+		return models.AuditLog{}, false, fmt.Errorf("encoding changes: %w", err)
+	}
+
+	entry := models.AuditLog{
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   fmt.Sprint(entityID),
+		Changes:    changesJSON,
+		IPAddress:  actor.IPAddress,
+	}
+	if actor.UserID != nil {
+		entry.ActorID = actor.UserID
+		entry.ActorName = actor.Username
+	} else if actor.ClientID != nil {
+		entry.ActorName = "client:" + strconv.Itoa(int(*actor.ClientID))
+	}
+	return entry, true, nil
+}
+
+type change struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+func diff(before, after any) (map[string]change, error) {
+	beforeFields, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]change)
+	for _, entityFields := range []map[string]any{beforeFields, afterFields} {
+		for field := range entityFields {
+			if _, done := changes[field]; done || slices.Contains(ignoredFields, field) {
+				continue
+			}
+			from, to := beforeFields[field], afterFields[field]
+			if reflect.DeepEqual(from, to) {
+				continue
+			}
+			if slices.Contains(redactedFields, field) {
+				from, to = redact(from), redact(to)
+			}
+			changes[field] = change{From: from, To: to}
+		}
+	}
+	return changes, nil
+}
+
+// fields flattens an entity to its top level JSON fields.
+func fields(entity any) (map[string]any, error) {
+	result := make(map[string]any)
+	if entity == nil {
+		return result, nil
+	}
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func redact(value any) any {
+	if value == nil {
+		return nil
+	}
+	return redacted
+}
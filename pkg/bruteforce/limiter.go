@@ -0,0 +1,143 @@
+// Package bruteforce slows down and locks out repeated failed logins.
+package bruteforce
+
+import (
+	"context"
+	"time"
+)
+
+// Scope separates the counters of different kinds of keys, each with its own Policy.
+type Scope string
+
+const (
+	ScopeUser   Scope = "user"
+	ScopeClient Scope = "client"
+	ScopeIP     Scope = "ip"
+)
+
+// Key identifies a counter, such as a username or an IP address.
+type Key struct {
+	Scope Scope
+	Value string
+}
+
+func (k Key) String() string {
+	return string(k.Scope) + ":" + k.Value
+}
+
+func UserKey(username string) Key { return Key{ScopeUser, username} }
+func ClientKey(client string) Key { return Key{ScopeClient, client} }
+func IPKey(ip string) Key         { return Key{ScopeIP, ip} }
+
+// Policy sets how failures of a scope are throttled. After each failure the next attempt has to
+// wait BaseDelay, doubling with every further failure up to MaxDelay. After MaxFailures failures
+// the key is locked out for LockoutDuration. Failures are forgotten after ResetAfter without any.
+type Policy struct {
+	MaxFailures     int
+	LockoutDuration time.Duration
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	ResetAfter      time.Duration
+}
+
+// Counter is the failure count of a key.
+type Counter struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// Store keeps the counters.
+type Store interface {
+	// Get returns the counter of a key, or a zero Counter if there is none.
+	Get(ctx context.Context, key string) (Counter, error)
+	// RecordFailure counts a failure at now, starting from zero if the last failure is older than
+	// resetAfter, and returns the updated counter.
+	RecordFailure(ctx context.Context, key string, now time.Time, resetAfter time.Duration) (Counter, error)
+	// Lock locks the key out until the given time.
+	Lock(ctx context.Context, key string, until time.Time) error
+	// Reset forgets the failures of a key.
+	Reset(ctx context.Context, key string) error
+}
+
+type Limiter struct {
+	store    Store
+	policies map[Scope]Policy
+}
+
+func NewLimiter(store Store, policies map[Scope]Policy) *Limiter {
+	return &Limiter{store: store, policies: policies}
+}
+
+// Check returns how long the caller has to wait before trying again with any of the keys, or
+// zero if it may try now.
+func (l *Limiter) Check(ctx context.Context, now time.Time, keys ...Key) (time.Duration, error) {
+	var wait time.Duration
+	for _, key := range keys {
+		policy := l.policies[key.Scope]
+		counter, err := l.store.Get(ctx, key.String())
+		if err != nil {
+			return 0, err
+		}
+		if keyWait := policy.wait(counter, now); keyWait > wait {
+			wait = keyWait
+		}
+	}
+	return wait, nil
+}
+
+// Failure records a failed attempt for each of the keys, locking out those that reached their
+// policy's maximum.
+func (l *Limiter) Failure(ctx context.Context, now time.Time, keys ...Key) error {
+	for _, key := range keys {
+		policy := l.policies[key.Scope]
+		counter, err := l.store.RecordFailure(ctx, key.String(), now, policy.ResetAfter)
+		if err != nil {
+			return err
+		}
+		if policy.MaxFailures > 0 && counter.Failures >= policy.MaxFailures {
+			if err := l.store.Lock(ctx, key.String(), now.Add(policy.LockoutDuration)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Reset forgets the failures of the keys, for example after a successful login or when an admin
+// unlocks an account.
+func (l *Limiter) Reset(ctx context.Context, keys ...Key) error {
+	for _, key := range keys {
+		if err := l.store.Reset(ctx, key.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p Policy) wait(counter Counter, now time.Time) time.Duration {
+	if now.Before(counter.LockedUntil) {
+		return counter.LockedUntil.Sub(now)
+	}
+	if counter.Failures == 0 || p.BaseDelay <= 0 || (p.ResetAfter > 0 && now.Sub(counter.LastFailure) > p.ResetAfter) {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < counter.Failures && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	if next := counter.LastFailure.Add(delay); now.Before(next) {
+		return next.Sub(now)
+	}
+	return 0
+}
+
+// Counter returns the current counter of a key.
+func (l *Limiter) Counter(ctx context.Context, key Key) (Counter, error) {
+	return l.store.Get(ctx, key.String())
+}
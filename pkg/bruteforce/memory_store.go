@@ -0,0 +1,73 @@
+package bruteforce
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps counters in memory. Counters are not shared between instances and are lost
+// on restart; use PostgresStore when running several instances.
+type MemoryStore struct {
+	mu        sync.Mutex
+	counters  map[string]Counter
+	retention time.Duration
+	lastPrune time.Time
+}
+
+// NewMemoryStore returns a store that drops counters without failures or lockout for retention.
+func NewMemoryStore(retention time.Duration) *MemoryStore {
+	return &MemoryStore{counters: make(map[string]Counter), retention: retention}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (Counter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counters[key], nil
+}
+
+func (s *MemoryStore) RecordFailure(ctx context.Context, key string, now time.Time, resetAfter time.Duration) (Counter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune(now)
+	counter := s.counters[key]
+	if resetAfter > 0 && now.Sub(counter.LastFailure) > resetAfter {
+		counter.Failures = 0
+	}
+	counter.Failures++
+	counter.LastFailure = now
+	s.counters[key] = counter
+	return counter, nil
+}
+
+func (s *MemoryStore) Lock(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counter := s.counters[key]
+	counter.LockedUntil = until
+	s.counters[key] = counter
+	return nil
+}
+
+func (s *MemoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.counters, key)
+	return nil
+}
+
+// prune drops stale counters, at most once per retention period.
+func (s *MemoryStore) prune(now time.Time) {
+	if s.retention <= 0 || now.Sub(s.lastPrune) < s.retention {
+		return
+	}
+	s.lastPrune = now
+	for key, counter := range s.counters {
+		if now.Sub(counter.LastFailure) > s.retention && now.After(counter.LockedUntil) {
+			delete(s.counters, key)
+		}
+	}
+}
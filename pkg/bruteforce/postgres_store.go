@@ -0,0 +1,78 @@
+package bruteforce
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoginAttempt is the row of a counter in PostgresStore.
+type LoginAttempt struct {
+	Key           string    `gorm:"primarykey"`
+	Failures      int       `gorm:"not null;default:0"`
+	LastFailureAt time.Time `gorm:"not null"`
+	LockedUntil   *time.Time
+}
+
+// PostgresStore keeps counters in the login_attempts table, so that they are shared by all the
+// instances using the database.
+type PostgresStore struct {
+	db *gorm.DB
+}
+
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Get(ctx context.Context, key string) (Counter, error) {
+	var attempt LoginAttempt
+	if err := s.db.WithContext(ctx).Where("key = ?", key).First(&attempt).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return Counter{}, nil
+		}
+		return Counter{}, err
+	}
+	return attempt.counter(), nil
+}
+
+func (s *PostgresStore) RecordFailure(ctx context.Context, key string, now time.Time, resetAfter time.Duration) (Counter, error) {
+	failures := gorm.Expr("login_attempts.failures + 1")
+	if resetAfter > 0 {
+		failures = gorm.Expr("CASE WHEN login_attempts.last_failure_at < ? THEN 1 ELSE login_attempts.failures + 1 END", now.Add(-resetAfter))
+	}
+
+	attempt := LoginAttempt{Key: key, Failures: 1, LastFailureAt: now}
+	err := s.db.WithContext(ctx).Clauses(
+		clause.OnConflict{
+			Columns: []clause.Column{{Name: "key"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"failures":        failures,
+				"last_failure_at": now,
+			}),
+		},
+		clause.Returning{},
+	).Create(&attempt).Error
+	if err != nil {
+		return Counter{}, err
+	}
+	return attempt.counter(), nil
+}
+
+func (s *PostgresStore) Lock(ctx context.Context, key string, until time.Time) error {
+	return s.db.WithContext(ctx).Model(&LoginAttempt{}).Where("key = ?", key).Update("locked_until", until).Error
+}
+
+func (s *PostgresStore) Reset(ctx context.Context, key string) error {
+	return s.db.WithContext(ctx).Where("key = ?", key).Delete(&LoginAttempt{}).Error
+}
+
+func (a LoginAttempt) counter() Counter {
+	counter := Counter{Failures: a.Failures, LastFailure: a.LastFailureAt}
+	if a.LockedUntil != nil {
+		counter.LockedUntil = *a.LockedUntil
+	}
+	return counter
+}
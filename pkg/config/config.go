@@ -0,0 +1,236 @@
+// Package config loads the application settings. Every setting has an environment variable name;
+// values come from the defaults, then an optional dotenv style file, then the environment, then
+// command line flags, each overriding the one before.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+const ProductionEnvironment = "production"
+
+// devJWTSecret signs tokens outside production when JWT_SECRET_KEY is not set.
+const devJWTSecret = "samuelabebayehu"
+
+type Config struct {
+	// Environment is "production" or anything else for development.
+	Environment string
+	HTTP        HTTP
+	Database    Database
+	Auth        Auth
+	Login       Login
+	Uploads     Uploads
+	Business    Business
+}
+
+type HTTP struct {
+	Addr        string
+	CORSOrigins []string
+	// TrustedProxies are the IP addresses and CIDR ranges of the reverse proxies whose
+	// X-Forwarded-For header is believed. By default there are none and the client IP is the
+	// address of the connection.
+	TrustedProxies []string
+	// WriteTimeout is how long the server gives a request to be handled and answered. Event
+	// streams and exports lift it for their own responses.
+	WriteTimeout time.Duration
+	// IdempotencyKeyTTL is how long responses to requests with an Idempotency-Key are replayed.
+	IdempotencyKeyTTL time.Duration
+}
+
+type Database struct {
+	URL             string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+type Auth struct {
+	JWTSecret          string
+	AccessTokenTTL     time.Duration
+	RefreshTokenTTL    time.Duration
+	MFATokenTTL        time.Duration
+	ClientTokenTTL     time.Duration
+	ClientLoginLinkTTL time.Duration
+	TOTPIssuer         string
+	// AdminUsername and AdminInitialPassword create the first admin when none exists.
+	AdminUsername        string
+	AdminInitialPassword string
+}
+
+// Login configures the limiter for failed logins.
+type Login struct {
+	MaxFailures     int
+	IPMaxFailures   int
+	LockoutDuration time.Duration
+	// LimiterStore is "memory", or "postgres" to share the counters between instances.
+	LimiterStore string
+}
+
+type Uploads struct {
+	// Store is "local" to keep images in Dir, served under BaseURL, or "s3".
+	Store    string
+	Dir      string
+	BaseURL  string
+	MaxBytes int64
+	S3       S3
+}
+
+// S3 configures the S3-compatible bucket images are kept in.
+type S3 struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	UseSSL          bool
+	// PublicBaseURL is the address of a publicly readable bucket or CDN. Without it images get
+	// presigned URLs valid for URLExpiry.
+	PublicBaseURL string
+	URLExpiry     time.Duration
+}
+
+type Business struct {
+	Name     string
+	Address  string
+	TaxID    string
+	Currency string
+	// Location is the timezone the restaurant operates in. Day, week and month boundaries in
+	// reports and date filters are computed in it rather than in the server's or the database's.
+	Location             *time.Location
+	FiscalYearStartMonth int
+	DefaultTaxRate       float64
+	ServiceChargePercent float64
+}
+
+// Default returns the settings used when nothing else is configured.
+func Default() *Config {
+	return &Config{
+		HTTP: HTTP{
+			Addr:              ":8080",
+			CORSOrigins:       []string{"*"},
+			WriteTimeout:      time.Minute,
+			IdempotencyKeyTTL: 24 * time.Hour,
+		},
+		Database: Database{
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+		},
+		Auth: Auth{
+			AccessTokenTTL:     time.Hour,
+			RefreshTokenTTL:    30 * 24 * time.Hour,
+			MFATokenTTL:        5 * time.Minute,
+			ClientTokenTTL:     12 * time.Hour,
+			ClientLoginLinkTTL: 15 * time.Minute,
+			AdminUsername:      "admin",
+		},
+		Login: Login{
+			MaxFailures:     5,
+			IPMaxFailures:   50,
+			LockoutDuration: 15 * time.Minute,
+			LimiterStore:    "memory",
+		},
+		Uploads: Uploads{
+			Store:    "local",
+			Dir:      "./uploads",
+			BaseURL:  "/uploads",
+			MaxBytes: 5 << 20,
+			S3: S3{
+				UseSSL:    true,
+				URLExpiry: time.Hour,
+			},
+		},
+		Business: Business{
+			Name:                 "Yom Kitchen",
+			Location:             time.UTC,
+			FiscalYearStartMonth: 1,
+		},
+	}
+}
+
+func (c *Config) IsProduction() bool {
+	return c.Environment == ProductionEnvironment
+}
+
+// InsecureJWTSecret reports whether tokens are signed with the development secret.
+func (c *Config) InsecureJWTSecret() bool {
+	return c.Auth.JWTSecret == devJWTSecret
+}
+
+// Validate checks the settings after loading. Outside production a missing JWT secret is replaced
+// by an insecure development secret.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	if c.Auth.JWTSecret == "" {
+		check(!c.IsProduction(), "JWT_SECRET_KEY must be set when APP_ENV is %s", ProductionEnvironment)
+		c.Auth.JWTSecret = devJWTSecret
+	}
+	check(c.HTTP.Addr != "", "HTTP_ADDR must not be empty")
+	check(len(c.HTTP.CORSOrigins) > 0, "CORS_ALLOWED_ORIGINS must not be empty")
+	for _, proxy := range c.HTTP.TrustedProxies {
+		_, _, cidrErr := net.ParseCIDR(proxy)
+		check(cidrErr == nil || net.ParseIP(proxy) != nil, "TRUSTED_PROXIES: %q is not an IP address or CIDR range", proxy)
+	}
+	check(c.HTTP.WriteTimeout > 0, "HTTP_WRITE_TIMEOUT must be positive")
+	check(c.HTTP.IdempotencyKeyTTL > 0, "IDEMPOTENCY_KEY_TTL must be positive")
+	check(c.Database.MaxOpenConns >= 0, "DB_MAX_OPEN_CONNS must not be negative")
+	check(c.Database.MaxIdleConns >= 0, "DB_MAX_IDLE_CONNS must not be negative")
+	check(c.Database.MaxOpenConns == 0 || c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+		"DB_MAX_IDLE_CONNS must not be more than DB_MAX_OPEN_CONNS")
+	check(c.Database.ConnMaxLifetime >= 0, "DB_CONN_MAX_LIFETIME must not be negative")
+	check(c.Auth.AccessTokenTTL > 0, "ACCESS_TOKEN_TTL must be positive")
+	check(c.Auth.RefreshTokenTTL > 0, "REFRESH_TOKEN_TTL must be positive")
+	check(c.Auth.MFATokenTTL > 0, "MFA_TOKEN_TTL must be positive")
+	check(c.Auth.ClientTokenTTL > 0, "CLIENT_TOKEN_TTL must be positive")
+	check(c.Auth.ClientLoginLinkTTL > 0, "CLIENT_LOGIN_LINK_TTL must be positive")
+	check(c.Auth.AdminUsername != "", "ADMIN_USERNAME must not be empty")
+	check(c.Login.MaxFailures > 0, "LOGIN_MAX_FAILURES must be positive")
+	check(c.Login.IPMaxFailures > 0, "LOGIN_IP_MAX_FAILURES must be positive")
+	check(c.Login.LockoutDuration > 0, "LOGIN_LOCKOUT_MINUTES must be positive")
+	check(c.Login.LimiterStore == "memory" || c.Login.LimiterStore == "postgres",
+		"LOGIN_LIMITER_STORE must be memory or postgres")
+	check(c.Uploads.MaxBytes > 0, "UPLOAD_MAX_BYTES must be positive")
+	switch c.Uploads.Store {
+	case "local":
+		check(c.Uploads.Dir != "", "UPLOAD_DIR must not be empty")
+		check(strings.HasPrefix(c.Uploads.BaseURL, "/"), "UPLOAD_BASE_URL must start with /")
+	case "s3":
+		check(c.Uploads.S3.Endpoint != "", "S3_ENDPOINT must be set when IMAGE_STORE is s3")
+		check(c.Uploads.S3.Bucket != "", "S3_BUCKET must be set when IMAGE_STORE is s3")
+		check(c.Uploads.S3.URLExpiry > 0 && c.Uploads.S3.URLExpiry <= 7*24*time.Hour,
+			"S3_URL_EXPIRY must be positive and at most 7 days")
+	default:
+		errs = append(errs, errors.New("IMAGE_STORE must be local or s3"))
+	}
+	check(c.Business.FiscalYearStartMonth >= 1 && c.Business.FiscalYearStartMonth <= 12,
+		"FISCAL_YEAR_START_MONTH must be between 1 and 12")
+	check(c.Business.DefaultTaxRate >= 0 && c.Business.DefaultTaxRate <= 100,
+		"DEFAULT_TAX_RATE must be between 0 and 100")
+	check(c.Business.ServiceChargePercent >= 0 && c.Business.ServiceChargePercent <= 100,
+		"SERVICE_CHARGE_PERCENT must be between 0 and 100")
+
+	if c.Auth.TOTPIssuer == "" {
+		c.Auth.TOTPIssuer = c.Business.Name
+	}
+	return errors.Join(errs...)
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
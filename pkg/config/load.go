@@ -0,0 +1,174 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// setting is one configuration value. Its key is the environment variable and file key; the flag
+// name is the key in lower case with dashes, such as -jwt-secret-key.
+type setting struct {
+	key   string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+func value[T any](key, usage string, parse func(string) (T, error), field func(c *Config) *T) setting {
+	return setting{key: key, usage: usage, set: func(c *Config, raw string) error {
+		parsed, err := parse(raw)
+		if err != nil {
+			return err
+		}
+		*field(c) = parsed
+		return nil
+	}}
+}
+
+func parseString(value string) (string, error) { return value, nil }
+
+func parseInt(value string) (int, error) { return strconv.Atoi(value) }
+
+func parseInt64(value string) (int64, error) { return strconv.ParseInt(value, 10, 64) }
+
+func parseBool(value string) (bool, error) { return strconv.ParseBool(value) }
+
+func parseFloat(value string) (float64, error) { return strconv.ParseFloat(value, 64) }
+
+func parseList(value string) ([]string, error) { return splitList(value), nil }
+
+func parseMinutes(value string) (time.Duration, error) {
+	minutes, err := strconv.Atoi(value)
+	return time.Duration(minutes) * time.Minute, err
+}
+
+var settings = []setting{
+	value("APP_ENV", "environment, production enforces secure settings", parseString, func(c *Config) *string { return &c.Environment }),
+
+	value("HTTP_ADDR", "address the HTTP server listens on", parseString, func(c *Config) *string { return &c.HTTP.Addr }),
+	value("CORS_ALLOWED_ORIGINS", "comma separated origins allowed by CORS", parseList, func(c *Config) *[]string { return &c.HTTP.CORSOrigins }),
+	value("TRUSTED_PROXIES", "comma separated IP addresses or CIDR ranges of reverse proxies whose X-Forwarded-For is trusted", parseList, func(c *Config) *[]string { return &c.HTTP.TrustedProxies }),
+	value("HTTP_WRITE_TIMEOUT", "how long a request may take to be handled and answered, such as 1m", time.ParseDuration, func(c *Config) *time.Duration { return &c.HTTP.WriteTimeout }),
+	value("IDEMPOTENCY_KEY_TTL", "how long responses to requests with an Idempotency-Key are replayed", time.ParseDuration, func(c *Config) *time.Duration { return &c.HTTP.IdempotencyKeyTTL }),
+
+	value("DATABASE_URL", "PostgreSQL connection string", parseString, func(c *Config) *string { return &c.Database.URL }),
+	value("DB_MAX_OPEN_CONNS", "maximum open database connections, 0 for no limit", parseInt, func(c *Config) *int { return &c.Database.MaxOpenConns }),
+	value("DB_MAX_IDLE_CONNS", "maximum idle database connections", parseInt, func(c *Config) *int { return &c.Database.MaxIdleConns }),
+	value("DB_CONN_MAX_LIFETIME", "maximum lifetime of a database connection, such as 30m", time.ParseDuration, func(c *Config) *time.Duration { return &c.Database.ConnMaxLifetime }),
+
+	value("JWT_SECRET_KEY", "secret signing the access tokens", parseString, func(c *Config) *string { return &c.Auth.JWTSecret }),
+	value("ACCESS_TOKEN_TTL", "lifetime of staff access tokens", time.ParseDuration, func(c *Config) *time.Duration { return &c.Auth.AccessTokenTTL }),
+	value("REFRESH_TOKEN_TTL", "lifetime of staff sessions", time.ParseDuration, func(c *Config) *time.Duration { return &c.Auth.RefreshTokenTTL }),
+	value("MFA_TOKEN_TTL", "time to give a two-factor code after the password", time.ParseDuration, func(c *Config) *time.Duration { return &c.Auth.MFATokenTTL }),
+	value("CLIENT_TOKEN_TTL", "lifetime of client tokens", time.ParseDuration, func(c *Config) *time.Duration { return &c.Auth.ClientTokenTTL }),
+	value("CLIENT_LOGIN_LINK_TTL", "lifetime of client login links", time.ParseDuration, func(c *Config) *time.Duration { return &c.Auth.ClientLoginLinkTTL }),
+	value("TOTP_ISSUER", "name shown in authenticator apps, defaults to BUSINESS_NAME", parseString, func(c *Config) *string { return &c.Auth.TOTPIssuer }),
+	value("ADMIN_USERNAME", "username of the first admin", parseString, func(c *Config) *string { return &c.Auth.AdminUsername }),
+	value("ADMIN_INITIAL_PASSWORD", "password of the first admin, to be changed at the first login", parseString, func(c *Config) *string { return &c.Auth.AdminInitialPassword }),
+
+	value("LOGIN_MAX_FAILURES", "failed logins before an account is locked", parseInt, func(c *Config) *int { return &c.Login.MaxFailures }),
+	value("LOGIN_IP_MAX_FAILURES", "failed logins before an IP address is locked", parseInt, func(c *Config) *int { return &c.Login.IPMaxFailures }),
+	value("LOGIN_LOCKOUT_MINUTES", "minutes a locked account or IP address stays locked", parseMinutes, func(c *Config) *time.Duration { return &c.Login.LockoutDuration }),
+	value("LOGIN_LIMITER_STORE", "memory, or postgres to share failed logins between instances", parseString, func(c *Config) *string { return &c.Login.LimiterStore }),
+
+	value("IMAGE_STORE", "where uploaded images are kept, local or s3", parseString, func(c *Config) *string { return &c.Uploads.Store }),
+	value("UPLOAD_DIR", "directory uploaded images are stored in by the local store", parseString, func(c *Config) *string { return &c.Uploads.Dir }),
+	value("UPLOAD_BASE_URL", "path the local store serves images under", parseString, func(c *Config) *string { return &c.Uploads.BaseURL }),
+	value("UPLOAD_MAX_BYTES", "maximum size of an uploaded image", parseInt64, func(c *Config) *int64 { return &c.Uploads.MaxBytes }),
+	value("S3_ENDPOINT", "host and port of the S3 API, such as localhost:9000", parseString, func(c *Config) *string { return &c.Uploads.S3.Endpoint }),
+	value("S3_REGION", "region of the bucket", parseString, func(c *Config) *string { return &c.Uploads.S3.Region }),
+	value("S3_BUCKET", "bucket images are stored in", parseString, func(c *Config) *string { return &c.Uploads.S3.Bucket }),
+	value("S3_ACCESS_KEY_ID", "access key of the S3 API", parseString, func(c *Config) *string { return &c.Uploads.S3.AccessKeyID }),
+	value("S3_SECRET_ACCESS_KEY", "secret key of the S3 API", parseString, func(c *Config) *string { return &c.Uploads.S3.SecretAccessKey }),
+	value("S3_USE_SSL", "connect to the S3 API with HTTPS", parseBool, func(c *Config) *bool { return &c.Uploads.S3.UseSSL }),
+	value("S3_PUBLIC_BASE_URL", "public address of the bucket, instead of presigned URLs", parseString, func(c *Config) *string { return &c.Uploads.S3.PublicBaseURL }),
+	value("S3_URL_EXPIRY", "lifetime of presigned image URLs", time.ParseDuration, func(c *Config) *time.Duration { return &c.Uploads.S3.URLExpiry }),
+
+	value("BUSINESS_NAME", "business name on receipts", parseString, func(c *Config) *string { return &c.Business.Name }),
+	value("BUSINESS_ADDRESS", "business address on receipts", parseString, func(c *Config) *string { return &c.Business.Address }),
+	value("BUSINESS_TAX_ID", "tax ID on receipts", parseString, func(c *Config) *string { return &c.Business.TaxID }),
+	value("BUSINESS_CURRENCY", "currency on receipts", parseString, func(c *Config) *string { return &c.Business.Currency }),
+	value("BUSINESS_TIMEZONE", "IANA timezone of the restaurant, such as Africa/Addis_Ababa", time.LoadLocation, func(c *Config) **time.Location { return &c.Business.Location }),
+	value("FISCAL_YEAR_START_MONTH", "month the fiscal year starts in, 1-12", parseInt, func(c *Config) *int { return &c.Business.FiscalYearStartMonth }),
+	value("DEFAULT_TAX_RATE", "tax rate in percent for items without a category rate", parseFloat, func(c *Config) *float64 { return &c.Business.DefaultTaxRate }),
+	value("SERVICE_CHARGE_PERCENT", "service charge in percent", parseFloat, func(c *Config) *float64 { return &c.Business.ServiceChargePercent }),
+}
+
+func flagName(key string) string {
+	return strings.ToLower(strings.ReplaceAll(key, "_", "-"))
+}
+
+// Load reads the configuration from the command line arguments (without the program name) and
+// the environment. The settings file is named by -config or CONFIG_FILE; without either, a .env
+// file in the working directory is read if there is one.
+func Load(args []string) (*Config, error) {
+	flags := flag.NewFlagSet("yom-kitchen", flag.ContinueOnError)
+	configFile := flags.String("config", os.Getenv("CONFIG_FILE"), "settings file with KEY=value lines")
+	flagValues := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		flagValues[flagName(s.key)] = flags.String(flagName(s.key), "", s.usage+" ("+s.key+")")
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	fileValues, file, err := readFile(*configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+	var errs []error
+	apply := func(source string, s setting, raw string) {
+		if err := s.set(cfg, raw); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s %q from %s: %w", s.key, raw, source, err))
+		}
+	}
+	// Empty values in the file and the environment count as unset.
+	for _, s := range settings {
+		if raw := fileValues[s.key]; raw != "" {
+			apply(file, s, raw)
+		}
+	}
+	for _, s := range settings {
+		if raw := os.Getenv(s.key); raw != "" {
+			apply("environment", s, raw)
+		}
+	}
+	flags.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if flagName(s.key) == f.Name {
+				apply("flag -"+f.Name, s, *flagValues[f.Name])
+			}
+		}
+	})
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// readFile returns the values of the settings file and its path.
+func readFile(path string) (map[string]string, string, error) {
+	if path == "" {
+		if _, err := os.Stat(".env"); err != nil {
+			return nil, "", nil
+		}
+		path = ".env"
+	}
+	values, err := godotenv.Read(path)
+	if err != nil {
+		return nil, path, fmt.Errorf("reading config file %s: %w", path, err)
+	}
+	return values, path, nil
+}
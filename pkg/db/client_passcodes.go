@@ -0,0 +1,52 @@
+package db
+
+import (
+	"log"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// HashClientPasscodes replaces the plain text passcodes of the clients table with bcrypt hashes
+// in passcode_hash and drops the passcode column. The legacy passcodes are too short to keep, so the
+// clients are flagged with passcode_rotation_required and cannot log in with them until they are
+// given a new one. It does nothing once the passcode column is gone, so it is safe to run on every
+// start.
+// It must run before CompleteLegacySchema, which expects passcode_hash to exist.
+func HashClientPasscodes(db *gorm.DB) error {
+	if !db.Migrator().HasTable("clients") || !db.Migrator().HasColumn("clients", "passcode") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`ALTER TABLE clients ADD COLUMN IF NOT EXISTS passcode_hash text NOT NULL DEFAULT ''`).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`ALTER TABLE clients ADD COLUMN IF NOT EXISTS passcode_rotation_required boolean NOT NULL DEFAULT false`).Error; err != nil {
+			return err
+		}
+
+		var clients []struct {
+			ID       uint
+			Passcode string
+		}
+		if err := tx.Table("clients").Select("id, passcode").Where("passcode_hash = ''").Scan(&clients).Error; err != nil {
+			return err
+		}
+		for _, client := range clients {
+			hash, err := bcrypt.GenerateFromPassword([]byte(client.Passcode), bcrypt.DefaultCost)
+			if err != nil {
+				return err
+			}
+			if err := tx.Table("clients").Where("id = ?", client.ID).Updates(map[string]any{"passcode_hash": string(hash), "passcode_rotation_required": true}).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Exec(`ALTER TABLE clients DROP COLUMN passcode`).Error; err != nil {
+			return err
+		}
+		log.Printf("Hashed the passcodes of %d clients", len(clients))
+		return nil
+	})
+}
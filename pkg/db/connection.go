@@ -1,27 +1,29 @@
 package db
 
 import (
-	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"log"
-	"os"
+	"yom-kitchen/pkg/config"
 )
 
-func InitializeDB() (*gorm.DB, error) {
-	err := godotenv.Load()
+func InitializeDB(cfg config.Database) (*gorm.DB, error) {
+	if cfg.URL == "" {
+		log.Println("DATABASE_URL not set, using default local connection.")
+	}
+
+	db, err := gorm.Open(postgres.Open(cfg.URL), &gorm.Config{})
 	if err != nil {
 		return nil, err
 	}
-	dsn := os.Getenv("DATABASE_URL")
-	if dsn == "" {
-		log.Println("DATABASE_URL environment variable not set, using default local connection.")
-	}
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	sqlDB, err := db.DB()
 	if err != nil {
 		return nil, err
 	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
 
 	log.Println("Successfully connected to PostgreSQL database.")
 	return db, nil
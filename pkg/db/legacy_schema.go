@@ -0,0 +1,20 @@
+package db
+
+import (
+	_ "embed"
+
+	"gorm.io/gorm"
+)
+
+//go:embed legacy_schema.sql
+var legacySchema string
+
+// CompleteLegacySchema adds the columns, tables and triggers of the baseline migration that are
+// missing from a schema created by AutoMigrate. The statements are fixed SQL rather than an
+// AutoMigrate of the current models, so the schema it leaves is the baseline whatever the models
+// have become since. It must run after ConvertMoneyColumns, HashClientPasscodes and MenuImageKeys.
+func CompleteLegacySchema(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		return tx.Exec(legacySchema).Error
+	})
+}
@@ -0,0 +1,24 @@
+package db
+
+import "gorm.io/gorm"
+
+// MenuImageKeys replaces the image_url column of menu_items, which held paths such as
+// /uploads/123-pizza.jpg, with image_key holding the key of the image in the image store, the
+// path below the upload directory. It does nothing once image_url is gone, so it is safe to run on
+// every start.
+func MenuImageKeys(db *gorm.DB) error {
+	if !db.Migrator().HasTable("menu_items") || !db.Migrator().HasColumn("menu_items", "image_url") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`ALTER TABLE menu_items ADD COLUMN IF NOT EXISTS image_key text NOT NULL DEFAULT ''`).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`UPDATE menu_items SET image_key = regexp_replace(image_url, '^/?uploads/', '')
+			WHERE image_url IS NOT NULL AND image_url <> ''`).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`ALTER TABLE menu_items DROP COLUMN image_url`).Error
+	})
+}
@@ -0,0 +1,45 @@
+package db
+
+import (
+	"fmt"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// moneyColumns lists the columns holding money.Amount values, stored in minor units.
+var moneyColumns = map[string][]string{
+	"menu_items":      {"price"},
+	"orders":          {"subtotal_amount", "discount_amount", "net_amount", "service_charge_amount", "tax_amount", "total_amount"},
+	"order_items":     {"item_price", "subtotal", "discount_amount", "tax_amount"},
+	"order_tax_lines": {"taxable_amount", "tax_amount"},
+}
+
+// ConvertMoneyColumns converts money columns created as decimal or floating point amounts in
+// major units to bigint minor units, multiplying existing values by 100. Columns that are
+// already bigint, or do not exist yet, are left alone, so it is safe to run on every start.
+func ConvertMoneyColumns(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for table, columns := range moneyColumns {
+			for _, column := range columns {
+				var dataType string
+				err := tx.Raw(`SELECT data_type FROM information_schema.columns
+					WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?`,
+					table, column).Scan(&dataType).Error
+				if err != nil {
+					return err
+				}
+				if dataType != "numeric" && dataType != "double precision" && dataType != "real" {
+					continue
+				}
+				statement := fmt.Sprintf(`ALTER TABLE %q ALTER COLUMN %q TYPE bigint USING ROUND(%q * 100)::bigint`,
+					table, column, column)
+				if err := tx.Exec(statement).Error; err != nil {
+					return err
+				}
+				log.Printf("Converted %s.%s to minor units.", table, column)
+			}
+		}
+		return nil
+	})
+}
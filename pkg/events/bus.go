@@ -0,0 +1,114 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	OrderCreated       = "order-created"
+	OrderStatusChanged = "order-status-changed"
+	OrderDeleted       = "order-deleted"
+	// Resync is sent to a subscriber whose Last-Event-ID can no longer be replayed, telling it to
+	// reload its state from the REST endpoints.
+	Resync = "resync"
+)
+
+const subscriberBufferSize = 64
+
+type Event struct {
+	ID   uint64    `json:"id"`
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data"`
+}
+
+// Bus is an in-process publish/subscribe hub that keeps the most recent events so that
+// subscribers reconnecting with a Last-Event-ID can catch up on what they missed.
+type Bus struct {
+	mu          sync.Mutex
+	lastID      uint64
+	history     []Event
+	historySize int
+	subscribers map[*Subscription]struct{}
+}
+
+// Subscription delivers published events on C. C is closed when the subscription is closed or
+// when the subscriber falls too far behind; in the latter case the client should reconnect with
+// the ID of the last event it received.
+type Subscription struct {
+	C      chan Event
+	bus    *Bus
+	closed bool
+}
+
+// NewBus creates a bus that retains up to historySize events for replay. Event IDs are seeded
+// from the current time so that IDs handed out by a previous process are always older than the
+// ones handed out by this one.
+func NewBus(historySize int) *Bus {
+	return &Bus{
+		lastID:      uint64(time.Now().UnixMicro()),
+		historySize: historySize,
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+func (b *Bus) Publish(eventType string, data any) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	event := Event{ID: b.lastID, Type: eventType, Time: time.Now(), Data: data}
+
+	b.history = append(b.history, event)
+	if len(b.history) > b.historySize {
+		b.history = b.history[len(b.history)-b.historySize:]
+	}
+
+	for sub := range b.subscribers {
+		select {
+		case sub.C <- event:
+		default:
+			b.closeLocked(sub)
+		}
+	}
+	return event
+}
+
+// Subscribe registers a new subscriber. When lastEventID is non-zero the events published after
+// it are returned as missed; complete is false if some of them are no longer retained.
+func (b *Bus) Subscribe(lastEventID uint64) (sub *Subscription, missed []Event, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub = &Subscription{C: make(chan Event, subscriberBufferSize), bus: b}
+	b.subscribers[sub] = struct{}{}
+
+	if lastEventID == 0 || lastEventID == b.lastID {
+		return sub, nil, true
+	}
+	if lastEventID > b.lastID || len(b.history) == 0 || lastEventID < b.history[0].ID-1 {
+		return sub, nil, false
+	}
+	for _, event := range b.history {
+		if event.ID > lastEventID {
+			missed = append(missed, event)
+		}
+	}
+	return sub, missed, true
+}
+
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.closeLocked(s)
+}
+
+func (b *Bus) closeLocked(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	delete(b.subscribers, sub)
+	close(sub.C)
+}
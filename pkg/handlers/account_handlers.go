@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"yom-kitchen/pkg/apierror"
+	"yom-kitchen/pkg/middlewares"
+	"yom-kitchen/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const minPasswordLength = 8
+
+// validateNewPassword checks a password chosen by a user for themselves.
+func validateNewPassword(password string) error {
+	if len(password) < minPasswordLength {
+		return errors.New("password must be at least 8 characters")
+	}
+	return nil
+}
+
+// ChangePassword sets a new password for the logged in user, which also clears
+// MustChangePassword. The user's other sessions are revoked.
+func ChangePassword(c *gin.Context) {
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	user := middlewares.GetUserFromContext(c)
+	if user == nil {
+		apierror.Unauthorized(c, "Authentication required")
+		return
+	}
+
+	var request struct {
+		CurrentPassword string `json:"current_password" binding:"required"`
+		NewPassword     string `json:"new_password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Bind(c, err)
+		return
+	}
+	if err := validateNewPassword(request.NewPassword); err != nil {
+		apierror.BadRequest(c, err.Error())
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(request.CurrentPassword)) != nil {
+		apierror.Unauthorized(c, "Invalid current password")
+		return
+	}
+	if request.NewPassword == request.CurrentPassword {
+		apierror.BadRequest(c, "New password must be different from the current password")
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		apierror.Internal(c, err, "Failed to hash new password")
+		return
+	}
+
+	before := *user
+	if err := db.Model(user).Updates(map[string]interface{}{
+		"password_hash":        string(hashedPassword),
+		"must_change_password": false,
+	}).Error; err != nil {
+		apierror.Internal(c, err, "Failed to update password")
+		return
+	}
+
+	var currentSessionID uint
+	if session := middlewares.GetSessionFromContext(c); session != nil {
+		currentSessionID = session.ID
+	}
+	if _, err := revokeUserSessions(db, user.ID, currentSessionID); err != nil {
+		apierror.Internal(c, err, "Failed to revoke sessions")
+		return
+	}
+
+	recordAudit(c, db, models.AuditActionUpdate, "user", user.ID, before, *user)
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"yom-kitchen/pkg/audit"
+	"yom-kitchen/pkg/middlewares"
+	"yom-kitchen/pkg/repository"
+	"yom-kitchen/pkg/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// recordAudit records a change made by the authenticated user with services.RecordAudit. before
+// is nil for creations and after is nil for deletions. Pass the transaction making the change when
+// there is one, so that the entry is only kept if the change is.
+func recordAudit(c *gin.Context, db *gorm.DB, action, entityType string, entityID any, before, after any) {
+	services.RecordAudit(c.Request.Context(), repository.NewPostgresAuditRepository(db), actorFromContext(c),
+		action, entityType, entityID, before, after)
+}
+
+// actorFromContext identifies the authenticated user or client making the request.
+func actorFromContext(c *gin.Context) audit.Actor {
+	actor := audit.Actor{IPAddress: c.ClientIP()}
+	if user := middlewares.GetUserFromContext(c); user != nil {
+		actor.UserID = &user.ID
+		actor.Username = user.Username
+	} else if client := middlewares.GetClientFromContext(c); client != nil {
+		actor.ClientID = &client.ID
+	}
+	return actor
+}
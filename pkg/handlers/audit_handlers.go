@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"yom-kitchen/pkg/apierror"
+	"yom-kitchen/pkg/middlewares"
+	"yom-kitchen/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func GetAuditLogAdmin(c *gin.Context) {
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	query, err := parseListQuery(c, []string{"id", "created_at"}, "-created_at")
+	if err != nil {
+		apierror.BadRequest(c, err.Error())
+		return
+	}
+	filter, err := auditFilter(c)
+	if err != nil {
+		apierror.BadRequest(c, err.Error())
+		return
+	}
+
+	var total int64
+	if err := db.Model(&models.AuditLog{}).Scopes(filter).Count(&total).Error; err != nil {
+		apierror.Internal(c, err, "Database error counting audit log entries")
+		return
+	}
+
+	entries := []models.AuditLog{}
+	if err := db.Scopes(filter, query.paginate).Find(&entries).Error; err != nil {
+		apierror.Internal(c, err, "Database error fetching audit log")
+		return
+	}
+
+	c.JSON(http.StatusOK, listResponse(c, query, total, entries))
+}
+
+// auditFilter builds the scope for the audit log filters: entity_type, entity_id, actor_id,
+// action, from and to.
+func auditFilter(c *gin.Context) (func(*gorm.DB) *gorm.DB, error) {
+	entityType := c.Query("entity_type")
+	entityID := c.Query("entity_id")
+	action := c.Query("action")
+	if action != "" && action != models.AuditActionCreate && action != models.AuditActionUpdate && action != models.AuditActionDelete {
+		return nil, errors.New("invalid action filter")
+	}
+
+	var actorID int
+	if actorStr := c.Query("actor_id"); actorStr != "" {
+		parsed, err := strconv.Atoi(actorStr)
+		if err != nil {
+			return nil, errors.New("invalid actor_id filter")
+		}
+		actorID = parsed
+	}
+
+	loc := middlewares.GetConfigFromContext(c).Business.Location
+	var from, to time.Time
+	if fromStr := c.Query("from"); fromStr != "" {
+		parsed, err := parseDateParam(fromStr, false, loc)
+		if err != nil {
+			return nil, errors.New("invalid from date")
+		}
+		from = parsed
+	}
+	if toStr := c.Query("to"); toStr != "" {
+		parsed, err := parseDateParam(toStr, true, loc)
+		if err != nil {
+			return nil, errors.New("invalid to date")
+		}
+		to = parsed
+	}
+
+	return func(tx *gorm.DB) *gorm.DB {
+		if entityType != "" {
+			tx = tx.Where("entity_type = ?", entityType)
+		}
+		if entityID != "" {
+			tx = tx.Where("entity_id = ?", entityID)
+		}
+		if action != "" {
+			tx = tx.Where("action = ?", action)
+		}
+		if actorID != 0 {
+			tx = tx.Where("actor_id = ?", actorID)
+		}
+		if !from.IsZero() {
+			tx = tx.Where("created_at >= ?", from)
+		}
+		if !to.IsZero() {
+			tx = tx.Where("created_at < ?", to)
+		}
+		return tx
+	}, nil
+}
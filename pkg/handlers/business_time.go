@@ -0,0 +1,11 @@
+package handlers
+
+import (
+	"time"
+)
+
+// startOfDay returns midnight of the day containing t, in t's location.
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"yom-kitchen/pkg/apierror"
+	"yom-kitchen/pkg/bruteforce"
+	"yom-kitchen/pkg/middlewares"
+	"yom-kitchen/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errInvalidClientCredentials     = errors.New("invalid client credentials")
+	errClientPasscodeRotationNeeded = errors.New("client passcode must be rotated")
+)
+
+// ClientLogin exchanges a client's email or ID and passcode, or a login link token, for a client
+// token. Clients whose passcode must be rotated can only log in with a login link.
+func ClientLogin(c *gin.Context) {
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	var loginRequest struct {
+		Email    string `json:"email"`
+		ClientID uint   `json:"client_id"`
+		Passcode string `json:"passcode"`
+		Token    string `json:"token"`
+	}
+	if err := c.ShouldBindJSON(&loginRequest); err != nil {
+		apierror.Bind(c, err)
+		return
+	}
+
+	var client models.Client
+	var err error
+	switch {
+	case loginRequest.Token != "":
+		if !loginAllowed(c, bruteforce.IPKey(c.ClientIP())) {
+			return
+		}
+		client, err = redeemClientLoginToken(db, loginRequest.Token)
+		if errors.Is(err, errInvalidClientCredentials) {
+			loginFailed(c, bruteforce.IPKey(c.ClientIP()))
+		}
+	case loginRequest.Passcode != "" && (loginRequest.Email != "" || loginRequest.ClientID != 0):
+		client, err = checkClientPasscode(c, db, loginRequest.Email, loginRequest.ClientID, loginRequest.Passcode)
+	default:
+		apierror.BadRequest(c, "Email or client ID and passcode, or a login token, are required")
+		return
+	}
+	if err != nil {
+		if !c.Writer.Written() {
+			if errors.Is(err, errInvalidClientCredentials) {
+				apierror.Unauthorized(c, "Invalid client credentials")
+			} else if errors.Is(err, errClientPasscodeRotationNeeded) {
+				apierror.Respond(c, http.StatusForbidden, apierror.CodePasscodeRotationRequired,
+					"Passcode has expired; ask for a new passcode or log in with a login link")
+			} else {
+				apierror.Internal(c, err, "Database error during login")
+			}
+		}
+		return
+	}
+
+	if !client.IsActive {
+		apierror.Forbidden(c, "Client account is inactive")
+		return
+	}
+
+	cfg := middlewares.GetConfigFromContext(c)
+	expirationTime := time.Now().Add(cfg.Auth.ClientTokenTTL)
+	claims := &middlewares.ClientClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    "samuelabebayehu",
+			Subject:   strconv.Itoa(int(client.ID)),
+			Audience:  jwt.ClaimStrings{middlewares.ClientTokenAudience},
+		},
+		TokenVersion: client.TokenVersion,
+	}
+	tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.Auth.JWTSecret))
+	if err != nil {
+		apierror.Internal(c, err, "Failed to generate JWT token")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Login successful",
+		"token":      tokenString,
+		"expires_at": expirationTime,
+		"client_id":  client.ID,
+		"name":       client.Name,
+	})
+}
+
+// dummyPasscodeHash is compared against when no client matches a login, so that unknown clients
+// take as long to reject as wrong passcodes.
+var dummyPasscodeHash = func() string {
+	var client models.Client
+	if err := client.SetNewPasscode(); err != nil {
+		panic(err)
+	}
+	return client.PasscodeHash
+}()
+
+// checkClientPasscode checks a passcode login against the login limiter. It responds itself when
+// the caller has to wait. A correct passcode that must be rotated is refused with
+// errClientPasscodeRotationNeeded.
+func checkClientPasscode(c *gin.Context, db *gorm.DB, email string, clientID uint, passcode string) (models.Client, error) {
+	var client models.Client
+	query := db.Model(&models.Client{})
+	if clientID != 0 {
+		query = query.Where("id = ?", clientID)
+	} else {
+		query = query.Where("email = ?", email)
+	}
+	err := query.First(&client).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return client, err
+	}
+
+	// Failures are counted per client, whether it is named by email or ID, and per unknown email.
+	accountKey := bruteforce.ClientKey(email)
+	if err == nil {
+		accountKey = clientLoginKey(client.ID)
+	} else if clientID != 0 {
+		accountKey = clientLoginKey(clientID)
+	}
+	keys := []bruteforce.Key{accountKey, bruteforce.IPKey(c.ClientIP())}
+	if !loginAllowed(c, keys...) {
+		return client, errInvalidClientCredentials
+	}
+
+	if err != nil {
+		(&models.Client{PasscodeHash: dummyPasscodeHash}).CheckPasscode(passcode)
+		loginFailed(c, keys...)
+		return client, errInvalidClientCredentials
+	}
+	if !client.CheckPasscode(passcode) {
+		loginFailed(c, keys...)
+		return client, errInvalidClientCredentials
+	}
+
+	loginSucceeded(c, accountKey)
+	if client.PasscodeRotationRequired {
+		return client, errClientPasscodeRotationNeeded
+	}
+	return client, nil
+}
+
+func clientLoginKey(clientID uint) bruteforce.Key {
+	return bruteforce.ClientKey("id:" + strconv.Itoa(int(clientID)))
+}
+
+// redeemClientLoginToken uses up a login link token and returns its client.
+func redeemClientLoginToken(db *gorm.DB, token string) (models.Client, error) {
+	var client models.Client
+	var loginToken models.ClientLoginToken
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&loginToken).
+			Clauses(clause.Returning{}).
+			Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", hashToken(token), time.Now()).
+			UpdateColumn("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInvalidClientCredentials
+		}
+		if err := tx.First(&client, loginToken.ClientID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errInvalidClientCredentials
+			}
+			return err
+		}
+		return nil
+	})
+	return client, err
+}
+
+// CreateClientLoginLinkAdmin issues a single use login token for a client, to be sent to them as
+// a magic link.
+func CreateClientLoginLinkAdmin(c *gin.Context) {
+	clientID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.BadRequest(c, "Invalid client ID format")
+		return
+	}
+
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	var client models.Client
+	if err := db.First(&client, clientID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.NotFound(c, "client not found")
+		} else {
+			apierror.Internal(c, err, "Database error")
+		}
+		return
+	}
+
+	token, err := newRandomToken()
+	if err != nil {
+		apierror.Internal(c, err, "Failed to generate login token")
+		return
+	}
+
+	loginToken := models.ClientLoginToken{
+		ClientID:  client.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(middlewares.GetConfigFromContext(c).Auth.ClientLoginLinkTTL),
+	}
+	if err := db.Create(&loginToken).Error; err != nil {
+		apierror.Internal(c, err, "Failed to create login token")
+		return
+	}
+
+	recordAudit(c, db, models.AuditActionCreate, "client_login_token", loginToken.ID, nil, loginToken)
+	c.JSON(http.StatusCreated, gin.H{"client_id": client.ID, "token": token, "expires_at": loginToken.ExpiresAt})
+}
+
+// RotateClientPasscodeAdmin gives a client a new passcode.
+func RotateClientPasscodeAdmin(c *gin.Context) {
+	clientID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.BadRequest(c, "Invalid client ID format")
+		return
+	}
+
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	var client models.Client
+	if err := db.First(&client, clientID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.NotFound(c, "client not found")
+		} else {
+			apierror.Internal(c, err, "Database error")
+		}
+		return
+	}
+
+	rotateClientPasscode(c, db, &client)
+}
+
+// ClientRotatePasscodeHandler lets an authenticated client replace their passcode. The token used
+// for the request is revoked with the others, so the client logs in again with the new passcode.
+func ClientRotatePasscodeHandler(c *gin.Context) {
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	client := middlewares.GetClientFromContext(c)
+	if client == nil {
+		apierror.Unauthorized(c, "Authentication required")
+		return
+	}
+
+	rotateClientPasscode(c, db, client)
+}
+
+// rotateClientPasscode gives client a new passcode and bumps their token version, which revokes
+// the client tokens issued before.
+func rotateClientPasscode(c *gin.Context, db *gorm.DB, client *models.Client) {
+	previousHash := client.PasscodeHash
+	if err := client.SetNewPasscode(); err != nil {
+		apierror.Internal(c, err, "Failed to generate passcode")
+		return
+	}
+
+	err := db.Model(client).UpdateColumns(map[string]any{
+		"passcode_hash":              client.PasscodeHash,
+		"passcode_rotation_required": false,
+		"token_version":              gorm.Expr("token_version + 1"),
+	}).Error
+	if err != nil {
+		apierror.Internal(c, err, "Failed to update passcode")
+		return
+	}
+
+	recordAudit(c, db, models.AuditActionUpdate, "client", client.ID,
+		map[string]any{"passcode_hash": previousHash}, map[string]any{"passcode_hash": client.PasscodeHash})
+	c.JSON(http.StatusOK, gin.H{"message": "Passcode rotated successfully", "client_id": client.ID, "passcode": client.Passcode})
+}
+
+// UnlockClientLoginAdmin lifts a client's login lockout and forgets their failed logins.
+func UnlockClientLoginAdmin(c *gin.Context) {
+	clientID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.BadRequest(c, "Invalid client ID format")
+		return
+	}
+
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	var client models.Client
+	if err := db.First(&client, clientID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.NotFound(c, "client not found")
+		} else {
+			apierror.Internal(c, err, "Database error")
+		}
+		return
+	}
+
+	failures, ok := unlockLogin(c, clientLoginKey(client.ID))
+	if !ok {
+		return
+	}
+	recordAudit(c, db, models.AuditActionUpdate, "client", client.ID,
+		map[string]any{"failed_logins": failures}, map[string]any{"failed_logins": 0})
+	c.JSON(http.StatusOK, gin.H{"message": "Client login unlocked successfully", "client_id": client.ID, "failed_logins": failures})
+}
@@ -3,155 +3,132 @@ package handlers
 import (
 	"errors"
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
-	"log"
 	"net/http"
 	"strconv"
+	"yom-kitchen/pkg/apierror"
 	"yom-kitchen/pkg/middlewares"
 	"yom-kitchen/pkg/models"
+	"yom-kitchen/pkg/repository"
 )
 
 func GetAllClientsAdmin(c *gin.Context) {
-	var clients []models.Client
-	db := middlewares.GetDBFromContext(c)
-	if db == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Database connection not available"})
+	svc := middlewares.GetServicesFromContext(c)
+	if svc == nil {
+		apierror.Internal(c, nil, "Services not available")
 		return
 	}
 
-	result := db.Find(&clients)
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Database error: "})
+	query, err := parseListQuery(c, []string{"id", "name", "email", "created_at"}, "name")
+	if err != nil {
+		apierror.BadRequest(c, err.Error())
+		return
+	}
+	filter, err := clientFilter(c)
+	if err != nil {
+		apierror.BadRequest(c, err.Error())
 		return
 	}
-	c.JSON(http.StatusOK, clients)
+
+	clients, total, err := svc.Clients.List(c.Request.Context(), filter, query.page())
+	if err != nil {
+		apierror.Internal(c, err, "Database error")
+		return
+	}
+	c.JSON(http.StatusOK, listResponse(c, query, total, clients))
+}
+
+// clientFilter reads the client list filter: active.
+func clientFilter(c *gin.Context) (repository.ClientFilter, error) {
+	var filter repository.ClientFilter
+	if activeStr := c.Query("active"); activeStr != "" {
+		parsed, err := strconv.ParseBool(activeStr)
+		if err != nil {
+			return filter, errors.New("invalid active filter")
+		}
+		filter.Active = &parsed
+	}
+	return filter, nil
 }
 
 func CreateClientAdmin(context *gin.Context) {
 	var newClient models.Client
 	if err := context.ShouldBindJSON(&newClient); err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"message": "error"})
-	}
-	db := middlewares.GetDBFromContext(context)
-	if db == nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Database connection not available"})
+		apierror.Bind(context, err)
 		return
 	}
-	var existingClient models.Client
-	result := db.Where("name = ? OR email = ?", newClient.Name, newClient.Email).First(&existingClient)
-	if result.RowsAffected > 0 {
-		print(result.RowsAffected)
-		context.JSON(http.StatusBadRequest, gin.H{"message": "Client or email already exists"})
+	svc := middlewares.GetServicesFromContext(context)
+	if svc == nil {
+		apierror.Internal(context, nil, "Services not available")
 		return
 	}
 
-	tx := db.Create(&newClient)
-	if tx.Error != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Database error: "})
+	if err := svc.Clients.Create(context.Request.Context(), actorFromContext(context), &newClient); err != nil {
+		respondServiceError(context, err, "Database error")
+		return
 	}
 	context.JSON(http.StatusCreated, newClient)
-
 }
 
 func UpdateClient(context *gin.Context) {
-
 	clientId, err := strconv.Atoi(context.Param("id"))
-	db := middlewares.GetDBFromContext(context)
-
-	if db == nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Database connection not available"})
-		return
-	}
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"message": "error"})
+		apierror.BadRequest(context, "Invalid client ID format")
 		return
 	}
 	if clientId == 0 {
-		context.JSON(http.StatusBadRequest, gin.H{"message": "Client id is required"})
+		apierror.BadRequest(context, "Client id is required")
 		return
 	}
-	var updatedData models.Client
-	if err := context.ShouldBindJSON(&updatedData); err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"message": "error"})
-	}
-	log.Println(updatedData)
-	var client models.Client
-	if result := db.First(&client, clientId); result.Error != nil {
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			context.JSON(http.StatusNotFound, gin.H{"message": "client not found"})
-		} else {
-			context.JSON(http.StatusInternalServerError, gin.H{"message": "Database error: "})
-		}
+	svc := middlewares.GetServicesFromContext(context)
+	if svc == nil {
+		apierror.Internal(context, nil, "Services not available")
 		return
 	}
 
-	result := db.Model(&client).Updates(updatedData)
-	if result.Error != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update client: "})
+	var updatedData models.Client
+	if err := context.ShouldBindJSON(&updatedData); err != nil {
+		apierror.Bind(context, err)
 		return
 	}
 
-	if result.RowsAffected == 0 {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update client (no rows affected)"})
+	updatedClient, err := svc.Clients.Update(context.Request.Context(), actorFromContext(context), uint(clientId), updatedData)
+	if err != nil {
+		respondServiceError(context, err, "Failed to update client")
 		return
 	}
 
-	var updatedClient models.Client
-	db.First(&updatedClient, clientId)
-
 	context.JSON(http.StatusOK, gin.H{"message": "Client updated successfully", "client": updatedClient})
-
 }
 
 func DeleteClientAdmin(context *gin.Context) {
-	clientIdStr := context.Param("id")
-	clientId, err := strconv.Atoi(clientIdStr)
+	clientId, err := strconv.Atoi(context.Param("id"))
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid client ID format"})
+		apierror.BadRequest(context, "Invalid client ID format")
 		return
 	}
 
-	db := middlewares.GetDBFromContext(context)
-	if db == nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Database connection not available"})
-		return
-	}
-
-	var client models.Client
-	result := db.First(&client, clientId)
-	if result.Error != nil {
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			context.JSON(http.StatusNotFound, gin.H{"message": "Client not found"})
-		} else {
-			context.JSON(http.StatusInternalServerError, gin.H{"message": "Database error: "})
-		}
+	svc := middlewares.GetServicesFromContext(context)
+	if svc == nil {
+		apierror.Internal(context, nil, "Services not available")
 		return
 	}
 
-	deleteResult := db.Delete(&client)
-	if deleteResult.Error != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to delete client: "})
+	if err := svc.Clients.Delete(context.Request.Context(), actorFromContext(context), uint(clientId)); err != nil {
+		respondServiceError(context, err, "Failed to delete client")
 		return
 	}
-
-	if deleteResult.RowsAffected == 0 {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to delete client (no rows affected)"})
-		return
-	}
-
 	context.JSON(http.StatusOK, gin.H{"message": "Client deleted successfully", "client_id": clientId})
 }
 
 func UpdateClientStatusAdmin(context *gin.Context) {
-	clientIdStr := context.Param("id")
-	clientId, err := strconv.Atoi(clientIdStr)
+	clientId, err := strconv.Atoi(context.Param("id"))
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid Client ID format: "})
+		apierror.BadRequest(context, "Invalid Client ID format")
 		return
 	}
-	db := middlewares.GetDBFromContext(context)
-	if db == nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Database connection not available"})
+	svc := middlewares.GetServicesFromContext(context)
+	if svc == nil {
+		apierror.Internal(context, nil, "Services not available")
 		return
 	}
 
@@ -160,58 +137,34 @@ func UpdateClientStatusAdmin(context *gin.Context) {
 	}
 	var clientStatus ClientStatus
 	if err := context.ShouldBindJSON(&clientStatus); err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body: "})
+		apierror.Bind(context, err)
 		return
 	}
 
-	var client models.Client
-	result := db.First(&client, clientId)
-	if result.Error != nil {
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			context.JSON(http.StatusNotFound, gin.H{"message": "Client item not found"})
-		} else {
-			context.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to find client: "})
-		}
-		return
-	}
-
-	updateResult := db.Model(&client).UpdateColumn("available", clientStatus.IsActive)
-	if updateResult.Error != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update client availability: "})
-		return
-	}
-
-	if updateResult.RowsAffected == 0 {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to client item availability (no rows affected)"})
+	updatedClient, err := svc.Clients.SetActive(context.Request.Context(), actorFromContext(context), uint(clientId), clientStatus.IsActive)
+	if err != nil {
+		respondServiceError(context, err, "Failed to update client availability")
 		return
 	}
 
-	var updatedClient models.Client
-	db.First(&updatedClient, clientId)
-
 	context.JSON(http.StatusOK, gin.H{"message": "Client availability updated successfully", "client": updatedClient})
 }
 
 func GetClientByIdAdmin(context *gin.Context) {
-	clientIdStr := context.Param("id")
-	clientId, err := strconv.Atoi(clientIdStr)
+	clientId, err := strconv.Atoi(context.Param("id"))
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid client ID format: "})
+		apierror.BadRequest(context, "Invalid client ID format")
 		return
 	}
-	db := middlewares.GetDBFromContext(context)
-	if db == nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Database connection not available"})
+	svc := middlewares.GetServicesFromContext(context)
+	if svc == nil {
+		apierror.Internal(context, nil, "Services not available")
 		return
 	}
-	var client models.Client
-	result := db.First(&client, clientId)
-	if result.Error != nil {
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			context.JSON(http.StatusNotFound, gin.H{"message": "Client not found"})
-			return
-		}
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to find client: "})
+
+	client, err := svc.Clients.Get(context.Request.Context(), uint(clientId))
+	if err != nil {
+		respondServiceError(context, err, "Failed to find client")
 		return
 	}
 	context.JSON(http.StatusOK, client)
@@ -0,0 +1,491 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"yom-kitchen/pkg/apierror"
+	"yom-kitchen/pkg/middlewares"
+	"yom-kitchen/pkg/models"
+	"yom-kitchen/pkg/repository"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func GetAllIngredientsAdmin(c *gin.Context) {
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	query, err := parseListQuery(c, []string{"id", "name", "stock_quantity", "created_at"}, "name")
+	if err != nil {
+		apierror.BadRequest(c, err.Error())
+		return
+	}
+
+	var total int64
+	if err := db.Model(&models.Ingredient{}).Count(&total).Error; err != nil {
+		apierror.Internal(c, err, "Database error counting ingredients")
+		return
+	}
+
+	ingredients := []models.Ingredient{}
+	if err := db.Scopes(query.paginate).Find(&ingredients).Error; err != nil {
+		apierror.Internal(c, err, "Database error fetching ingredients")
+		return
+	}
+
+	c.JSON(http.StatusOK, listResponse(c, query, total, ingredients))
+}
+
+func CreateIngredientAdmin(c *gin.Context) {
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	var ingredientRequest struct {
+		Name              string  `json:"name" binding:"required"`
+		Unit              string  `json:"unit" binding:"required"`
+		StockQuantity     float64 `json:"stock_quantity" binding:"min=0"`
+		LowStockThreshold float64 `json:"low_stock_threshold" binding:"min=0"`
+	}
+	if err := c.ShouldBindJSON(&ingredientRequest); err != nil {
+		apierror.Bind(c, err)
+		return
+	}
+
+	var existing models.Ingredient
+	if err := db.Unscoped().Where("name = ?", ingredientRequest.Name).First(&existing).Error; err == nil {
+		apierror.Conflict(c, "Ingredient already exists")
+		return
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		apierror.Internal(c, err, "Database error checking ingredient")
+		return
+	}
+
+	ingredient := models.Ingredient{
+		Name:              ingredientRequest.Name,
+		Unit:              ingredientRequest.Unit,
+		StockQuantity:     ingredientRequest.StockQuantity,
+		LowStockThreshold: ingredientRequest.LowStockThreshold,
+	}
+	if err := db.Create(&ingredient).Error; err != nil {
+		apierror.Internal(c, err, "Failed to create ingredient")
+		return
+	}
+
+	recordAudit(c, db, models.AuditActionCreate, "ingredient", ingredient.ID, nil, ingredient)
+	c.JSON(http.StatusCreated, ingredient)
+}
+
+// UpdateIngredientAdmin changes an ingredient's name, unit or low stock threshold. Stock levels
+// are only changed through adjustments and deliveries so that every change is recorded.
+func UpdateIngredientAdmin(c *gin.Context) {
+	ingredientID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.BadRequest(c, "Invalid ingredient ID format")
+		return
+	}
+
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	var ingredientRequest struct {
+		Name              *string  `json:"name,omitempty"`
+		Unit              *string  `json:"unit,omitempty"`
+		LowStockThreshold *float64 `json:"low_stock_threshold,omitempty" binding:"omitempty,min=0"`
+	}
+	if err := c.ShouldBindJSON(&ingredientRequest); err != nil {
+		apierror.Bind(c, err)
+		return
+	}
+
+	var ingredient models.Ingredient
+	if err := db.First(&ingredient, ingredientID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.NotFound(c, "Ingredient not found")
+		} else {
+			apierror.Internal(c, err, "Database error fetching ingredient")
+		}
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if ingredientRequest.Name != nil {
+		var existing models.Ingredient
+		if err := db.Unscoped().Where("name = ? AND id <> ?", *ingredientRequest.Name, ingredient.ID).First(&existing).Error; err == nil {
+			apierror.Conflict(c, "Ingredient already exists")
+			return
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Internal(c, err, "Database error checking ingredient")
+			return
+		}
+		updates["name"] = *ingredientRequest.Name
+	}
+	if ingredientRequest.Unit != nil {
+		updates["unit"] = *ingredientRequest.Unit
+	}
+	if ingredientRequest.LowStockThreshold != nil {
+		updates["low_stock_threshold"] = *ingredientRequest.LowStockThreshold
+	}
+
+	before := ingredient
+	if len(updates) > 0 {
+		if err := db.Model(&ingredient).Updates(updates).Error; err != nil {
+			apierror.Internal(c, err, "Failed to update ingredient")
+			return
+		}
+	}
+
+	db.First(&ingredient, ingredientID)
+	recordAudit(c, db, models.AuditActionUpdate, "ingredient", ingredient.ID, before, ingredient)
+	c.JSON(http.StatusOK, gin.H{"message": "Ingredient updated successfully", "ingredient": ingredient})
+}
+
+func DeleteIngredientAdmin(c *gin.Context) {
+	ingredientID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.BadRequest(c, "Invalid ingredient ID format")
+		return
+	}
+
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	var recipeCount int64
+	if err := db.Model(&models.RecipeItem{}).Where("ingredient_id = ?", ingredientID).Count(&recipeCount).Error; err != nil {
+		apierror.Internal(c, err, "Database error checking recipes")
+		return
+	}
+	if recipeCount > 0 {
+		apierror.Conflict(c, "Ingredient is used in menu item recipes")
+		return
+	}
+
+	var ingredient models.Ingredient
+	if err := db.First(&ingredient, ingredientID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.NotFound(c, "Ingredient not found")
+		} else {
+			apierror.Internal(c, err, "Database error fetching ingredient")
+		}
+		return
+	}
+
+	if err := db.Delete(&ingredient).Error; err != nil {
+		apierror.Internal(c, err, "Failed to delete ingredient")
+		return
+	}
+
+	recordAudit(c, db, models.AuditActionDelete, "ingredient", ingredient.ID, ingredient, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Ingredient deleted successfully", "ingredient_id": ingredientID})
+}
+
+// AdjustIngredientStockAdmin applies a signed correction to an ingredient's stock, for example
+// after a stock count or for waste.
+func AdjustIngredientStockAdmin(c *gin.Context) {
+	var adjustmentRequest struct {
+		Quantity float64 `json:"quantity" binding:"required"`
+		Note     string  `json:"note" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&adjustmentRequest); err != nil {
+		apierror.Bind(c, err)
+		return
+	}
+	changeIngredientStock(c, adjustmentRequest.Quantity, models.StockMovementAdjustment, adjustmentRequest.Note)
+}
+
+// RecordIngredientDeliveryAdmin adds a supplier delivery to an ingredient's stock.
+func RecordIngredientDeliveryAdmin(c *gin.Context) {
+	var deliveryRequest struct {
+		Quantity float64 `json:"quantity" binding:"required,gt=0"`
+		Note     string  `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&deliveryRequest); err != nil {
+		apierror.Bind(c, err)
+		return
+	}
+	changeIngredientStock(c, deliveryRequest.Quantity, models.StockMovementDelivery, deliveryRequest.Note)
+}
+
+func changeIngredientStock(c *gin.Context, change float64, reason, note string) {
+	ingredientID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.BadRequest(c, "Invalid ingredient ID format")
+		return
+	}
+
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	var ingredient models.Ingredient
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ingredient, ingredientID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				apierror.NotFound(c, "Ingredient not found")
+			} else {
+				apierror.Internal(c, err, "Database error fetching ingredient")
+			}
+			return err
+		}
+
+		before := ingredient
+		if err := tx.Model(&ingredient).UpdateColumn("stock_quantity", gorm.Expr("stock_quantity + ?", change)).Error; err != nil {
+			apierror.Internal(c, err, "Failed to update stock")
+			return err
+		}
+
+		movement := models.StockMovement{IngredientID: ingredient.ID, Change: change, Reason: reason, Note: note}
+		if user := middlewares.GetUserFromContext(c); user != nil {
+			movement.UserID = &user.ID
+		}
+		if err := tx.Create(&movement).Error; err != nil {
+			apierror.Internal(c, err, "Failed to record stock movement")
+			return err
+		}
+
+		if err := repository.RefreshMenuAvailability(tx, []uint{ingredient.ID}); err != nil {
+			apierror.Internal(c, err, "Failed to update menu availability")
+			return err
+		}
+		if err := tx.First(&ingredient, ingredient.ID).Error; err != nil {
+			apierror.Internal(c, err, "Database error fetching ingredient")
+			return err
+		}
+		recordAudit(c, tx, models.AuditActionUpdate, "ingredient", ingredient.ID, before, ingredient)
+		return nil
+	}); err != nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Stock updated successfully", "ingredient": ingredient})
+}
+
+func GetIngredientMovementsAdmin(c *gin.Context) {
+	ingredientID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.BadRequest(c, "Invalid ingredient ID format")
+		return
+	}
+
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	query, err := parseListQuery(c, []string{"id", "created_at"}, "-created_at")
+	if err != nil {
+		apierror.BadRequest(c, err.Error())
+		return
+	}
+
+	byIngredient := func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("ingredient_id = ?", ingredientID)
+	}
+
+	var total int64
+	if err := db.Model(&models.StockMovement{}).Scopes(byIngredient).Count(&total).Error; err != nil {
+		apierror.Internal(c, err, "Database error counting stock movements")
+		return
+	}
+
+	movements := []models.StockMovement{}
+	if err := db.Scopes(byIngredient, query.paginate).Find(&movements).Error; err != nil {
+		apierror.Internal(c, err, "Database error fetching stock movements")
+		return
+	}
+
+	c.JSON(http.StatusOK, listResponse(c, query, total, movements))
+}
+
+// GetLowStockAdmin lists the ingredients at or below their low stock threshold, with the menu
+// items that use them.
+func GetLowStockAdmin(c *gin.Context) {
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	ingredients := []models.Ingredient{}
+	if err := db.Where("stock_quantity <= low_stock_threshold").Order("name").Find(&ingredients).Error; err != nil {
+		apierror.Internal(c, err, "Database error fetching low stock ingredients")
+		return
+	}
+
+	type lowStockEntry struct {
+		models.Ingredient
+		MenuItems []string `json:"menu_items"`
+	}
+	report := make([]lowStockEntry, 0, len(ingredients))
+	for _, ingredient := range ingredients {
+		menuItems := []string{}
+		if err := db.Table("recipe_items").
+			Joins("JOIN menu_items ON menu_items.id = recipe_items.menu_item_id AND menu_items.deleted_at IS NULL").
+			Where("recipe_items.ingredient_id = ?", ingredient.ID).
+			Order("menu_items.name").
+			Pluck("menu_items.name", &menuItems).Error; err != nil {
+			apierror.Internal(c, err, "Database error fetching recipes")
+			return
+		}
+		report = append(report, lowStockEntry{Ingredient: ingredient, MenuItems: menuItems})
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func GetMenuRecipeAdmin(c *gin.Context) {
+	menuID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.BadRequest(c, "Invalid menu ID format")
+		return
+	}
+
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	var menuItem models.MenuItem
+	if err := db.First(&menuItem, menuID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.NotFound(c, "Menu item not found")
+		} else {
+			apierror.Internal(c, err, "Database error fetching menu item")
+		}
+		return
+	}
+
+	recipe := []models.RecipeItem{}
+	if err := db.Preload("Ingredient").Where("menu_item_id = ?", menuItem.ID).Find(&recipe).Error; err != nil {
+		apierror.Internal(c, err, "Database error fetching recipe")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"menu_item_id": menuItem.ID, "recipe": recipe})
+}
+
+// SetMenuRecipeAdmin replaces the recipe of a menu item.
+func SetMenuRecipeAdmin(c *gin.Context) {
+	menuID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.BadRequest(c, "Invalid menu ID format")
+		return
+	}
+
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	var recipeRequest struct {
+		Items []struct {
+			IngredientID       uint    `json:"ingredient_id" binding:"required"`
+			QuantityPerPortion float64 `json:"quantity_per_portion" binding:"required,gt=0"`
+		} `json:"items" binding:"dive"`
+	}
+	if err := c.ShouldBindJSON(&recipeRequest); err != nil {
+		apierror.Bind(c, err)
+		return
+	}
+
+	recipe := []models.RecipeItem{}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		var menuItem models.MenuItem
+		if err := tx.First(&menuItem, menuID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				apierror.NotFound(c, "Menu item not found")
+			} else {
+				apierror.Internal(c, err, "Database error fetching menu item")
+			}
+			return err
+		}
+
+		var previousRecipe []models.RecipeItem
+		if err := tx.Where("menu_item_id = ?", menuItem.ID).Find(&previousRecipe).Error; err != nil {
+			apierror.Internal(c, err, "Database error fetching recipe")
+			return err
+		}
+
+		if err := tx.Where("menu_item_id = ?", menuItem.ID).Delete(&models.RecipeItem{}).Error; err != nil {
+			apierror.Internal(c, err, "Failed to update recipe")
+			return err
+		}
+
+		seen := make(map[uint]bool)
+		for _, item := range recipeRequest.Items {
+			if seen[item.IngredientID] {
+				apierror.BadRequest(c, "Duplicate ingredient in recipe")
+				return errDuplicateIngredient
+			}
+			seen[item.IngredientID] = true
+
+			var ingredient models.Ingredient
+			if err := tx.First(&ingredient, item.IngredientID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					apierror.BadRequest(c, "Invalid ingredient ID")
+				} else {
+					apierror.Internal(c, err, "Database error fetching ingredient")
+				}
+				return err
+			}
+			recipe = append(recipe, models.RecipeItem{
+				MenuItemID:         menuItem.ID,
+				IngredientID:       ingredient.ID,
+				Ingredient:         ingredient,
+				QuantityPerPortion: item.QuantityPerPortion,
+			})
+		}
+
+		if len(recipe) > 0 {
+			if err := tx.Omit("Ingredient").Create(&recipe).Error; err != nil {
+				apierror.Internal(c, err, "Failed to update recipe")
+				return err
+			}
+		}
+
+		if err := repository.RefreshMenuItemAvailability(tx, menuItem.ID); err != nil {
+			apierror.Internal(c, err, "Failed to update menu availability")
+			return err
+		}
+
+		recordAudit(c, tx, models.AuditActionUpdate, "recipe", menuItem.ID, recipeAuditState(previousRecipe), recipeAuditState(recipe))
+		return nil
+	}); err != nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Recipe updated successfully", "menu_item_id": menuID, "recipe": recipe})
+}
+
+var errDuplicateIngredient = errors.New("duplicate ingredient in recipe")
+
+// recipeAuditState describes a recipe for the audit log as the quantity per portion of each
+// ingredient, so that the diff shows the ingredients that changed.
+func recipeAuditState(recipe []models.RecipeItem) map[string]float64 {
+	state := make(map[string]float64, len(recipe))
+	for _, item := range recipe {
+		state["ingredient_"+strconv.Itoa(int(item.IngredientID))] = item.QuantityPerPortion
+	}
+	return state
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"yom-kitchen/pkg/repository"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// listQuery holds the pagination and sorting parameters shared by the admin list endpoints:
+// ?page=2&page_size=50&sort=-created_at. Sorting is restricted to a whitelist of columns per
+// endpoint and a leading "-" sorts descending.
+type listQuery struct {
+	Page       int
+	PageSize   int
+	SortColumn string
+	SortDesc   bool
+}
+
+func parseListQuery(c *gin.Context, sortable []string, defaultSort string) (listQuery, error) {
+	query := listQuery{Page: 1, PageSize: defaultPageSize}
+
+	if pageStr := c.Query("page"); pageStr != "" {
+		page, err := strconv.Atoi(pageStr)
+		if err != nil || page < 1 {
+			return query, errors.New("page must be a positive integer")
+		}
+		query.Page = page
+	}
+
+	if pageSizeStr := c.Query("page_size"); pageSizeStr != "" {
+		pageSize, err := strconv.Atoi(pageSizeStr)
+		if err != nil || pageSize < 1 || pageSize > maxPageSize {
+			return query, fmt.Errorf("page_size must be between 1 and %d", maxPageSize)
+		}
+		query.PageSize = pageSize
+	}
+
+	sort := c.DefaultQuery("sort", defaultSort)
+	query.SortDesc = strings.HasPrefix(sort, "-")
+	query.SortColumn = strings.TrimPrefix(sort, "-")
+	isSortable := false
+	for _, column := range sortable {
+		if query.SortColumn == column {
+			isSortable = true
+			break
+		}
+	}
+	if !isSortable {
+		return query, fmt.Errorf("sort must be one of: %s", strings.Join(sortable, ", "))
+	}
+
+	return query, nil
+}
+
+// page is the page of the list the query selects.
+func (q listQuery) page() repository.Page {
+	return repository.Page{Number: q.Page, Size: q.PageSize, SortColumn: q.SortColumn, SortDesc: q.SortDesc}
+}
+
+// paginate is a gorm scope applying the sort order and the page window.
+func (q listQuery) paginate(tx *gorm.DB) *gorm.DB {
+	return q.page().Scope(tx)
+}
+
+// listResponse wraps one page of results together with the total count and links to the
+// neighbouring pages.
+func listResponse(c *gin.Context, q listQuery, total int64, items any) gin.H {
+	response := gin.H{
+		"items":     items,
+		"total":     total,
+		"page":      q.Page,
+		"page_size": q.PageSize,
+		"next":      nil,
+		"prev":      nil,
+	}
+	if int64(q.Page*q.PageSize) < total {
+		response["next"] = pageLink(c, q.Page+1)
+	}
+	if q.Page > 1 {
+		response["prev"] = pageLink(c, q.Page-1)
+	}
+	return response
+}
+
+func pageLink(c *gin.Context, page int) string {
+	values := c.Request.URL.Query()
+	values.Set("page", strconv.Itoa(page))
+	return c.Request.URL.Path + "?" + values.Encode()
+}
+
+// parseDateParam accepts either an RFC 3339 timestamp or a plain date. A plain date is taken as
+// the start of that day in loc, the business timezone, or as the start of the following day when it
+// is the end of a range, so that ?from=2025-01-01&to=2025-01-31 covers the whole of January.
+func parseDateParam(value string, endOfRange bool, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfRange {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
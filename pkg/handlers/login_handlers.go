@@ -1,23 +1,46 @@
 package handlers
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"net/http"
-	"os"
 	"strconv"
 	"time"
+	"yom-kitchen/pkg/apierror"
+	"yom-kitchen/pkg/bruteforce"
+	"yom-kitchen/pkg/config"
 	"yom-kitchen/pkg/middlewares"
 	"yom-kitchen/pkg/models"
 )
 
+var (
+	errInvalidRefreshToken    = errors.New("invalid refresh token")
+	errRefreshTokenReused     = errors.New("rotated refresh token used again")
+	errTOTPEnrollmentRequired = errors.New("two-factor authentication enrollment required")
+)
+
+// dummyPasswordHash is compared against when no user has the username of a login, so that unknown
+// usernames take as long to reject as wrong passwords.
+var dummyPasswordHash = func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+}()
+
 func Login(c *gin.Context) {
 	db := middlewares.GetDBFromContext(c)
 	if db == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Database connection not available"})
+		apierror.Internal(c, nil, "Database connection not available")
 		return
 	}
 
@@ -27,7 +50,12 @@ func Login(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&loginRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body: " + err.Error()})
+		apierror.Bind(c, err)
+		return
+	}
+
+	accountKey := bruteforce.UserKey(loginRequest.Username)
+	if !loginAllowed(c, accountKey, bruteforce.IPKey(c.ClientIP())) {
 		return
 	}
 
@@ -35,42 +63,226 @@ func Login(c *gin.Context) {
 	result := db.Where("username = ?", loginRequest.Username).First(&user)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid username or password"})
+			bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(loginRequest.Password))
+			loginFailed(c, accountKey, bruteforce.IPKey(c.ClientIP()))
+			apierror.Unauthorized(c, "Invalid username or password")
 			return
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "Database error during login: " + result.Error.Error()})
+			apierror.Internal(c, result.Error, "Database error during login")
 			return
 		}
 	}
 
 	err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(loginRequest.Password))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid username or password"})
+		loginFailed(c, accountKey, bruteforce.IPKey(c.ClientIP()))
+		apierror.Unauthorized(c, "Invalid username or password")
+		return
+	}
+
+	// With two-factor authentication the failures are only reset once the code is given too, so
+	// that knowing the password does not allow more guesses at the code.
+	required, err := totpRequired(db, user)
+	if err != nil {
+		apierror.Internal(c, err, "Database error during login")
+		return
+	}
+	if user.TOTPEnabled || required {
+		respondWithMFAChallenge(c, user)
+		return
+	}
+	loginSucceeded(c, accountKey)
+
+	session, refreshToken, ok := createSession(c, db, user)
+	if !ok {
+		return
+	}
+	respondWithTokens(c, user, session, refreshToken, "Login successful")
+}
+
+// createSession starts a session for the user, returning it with its refresh token.
+func createSession(c *gin.Context, db *gorm.DB, user models.User) (models.Session, string, bool) {
+	refreshToken, err := newRandomToken()
+	if err != nil {
+		apierror.Internal(c, err, "Failed to generate refresh token")
+		return models.Session{}, "", false
+	}
+	now := time.Now()
+	session := models.Session{
+		UserID:           user.ID,
+		RefreshTokenHash: hashToken(refreshToken),
+		ExpiresAt:        now.Add(middlewares.GetConfigFromContext(c).Auth.RefreshTokenTTL),
+		LastUsedAt:       now,
+		UserAgent:        c.Request.UserAgent(),
+		IPAddress:        c.ClientIP(),
+	}
+	if err := db.Create(&session).Error; err != nil {
+		apierror.Internal(c, err, "Failed to create session")
+		return models.Session{}, "", false
+	}
+	return session, refreshToken, true
+}
+
+// RefreshToken exchanges a refresh token for a new access token and a new refresh token. The old
+// refresh token stops working; presenting it again revokes the whole session, since it means the
+// token was stolen.
+func RefreshToken(c *gin.Context) {
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	var refreshRequest struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&refreshRequest); err != nil {
+		apierror.Bind(c, err)
+		return
+	}
+
+	newToken, err := newRandomToken()
+	if err != nil {
+		apierror.Internal(c, err, "Failed to generate refresh token")
+		return
+	}
+
+	tokenHash := hashToken(refreshRequest.RefreshToken)
+	var session models.Session
+	var user models.User
+	now := time.Now()
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("refresh_token_hash = ?", tokenHash).First(&session).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errRefreshTokenReused
+			}
+			return err
+		}
+		if !session.IsActive(now) {
+			return errInvalidRefreshToken
+		}
+		if err := tx.First(&user, session.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errInvalidRefreshToken
+			}
+			return err
+		}
+		// A role that started requiring TOTP after the login applies from the next refresh.
+		if !user.TOTPEnabled {
+			required, err := totpRequired(tx, user)
+			if err != nil {
+				return err
+			}
+			if required {
+				return errTOTPEnrollmentRequired
+			}
+		}
+
+		return tx.Model(&session).UpdateColumns(map[string]interface{}{
+			"previous_refresh_token_hash": tokenHash,
+			"refresh_token_hash":          hashToken(newToken),
+			"last_used_at":                now,
+			"updated_at":                  now,
+		}).Error
+	})
+	if errors.Is(err, errRefreshTokenReused) {
+		// A rotated token that is used again means someone else holds the session. The revocation
+		// is made outside the transaction, which is rolled back by the error.
+		err = db.Model(&models.Session{}).
+			Where("previous_refresh_token_hash = ? AND revoked_at IS NULL", tokenHash).
+			UpdateColumn("revoked_at", now).Error
+		if err == nil {
+			err = errInvalidRefreshToken
+		}
+	}
+	if err != nil {
+		if errors.Is(err, errInvalidRefreshToken) {
+			apierror.Unauthorized(c, "Invalid or expired refresh token")
+		} else if errors.Is(err, errTOTPEnrollmentRequired) {
+			apierror.Respond(c, http.StatusUnauthorized, apierror.CodeTOTPEnrollmentRequired, "Two-factor authentication is required, log in again to enroll")
+		} else {
+			apierror.Internal(c, err, "Database error refreshing token")
+		}
 		return
 	}
 
-	expirationTime := time.Now().Add(time.Hour * 1)
+	respondWithTokens(c, user, session, newToken, "Token refreshed")
+}
+
+// Logout revokes the session of the access token used for the request.
+func Logout(c *gin.Context) {
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	session := middlewares.GetSessionFromContext(c)
+	if session == nil {
+		apierror.Unauthorized(c, "Authentication required")
+		return
+	}
+
+	if err := db.Model(session).UpdateColumn("revoked_at", time.Now()).Error; err != nil {
+		apierror.Internal(c, err, "Failed to revoke session")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+func respondWithTokens(c *gin.Context, user models.User, session models.Session, refreshToken, message string) {
+	response, err := tokenResponse(middlewares.GetConfigFromContext(c), user, session, refreshToken, message)
+	if err != nil {
+		apierror.Internal(c, err, "Failed to generate JWT token")
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// tokenResponse returns the body of a successful login, with a new access token for the session.
+func tokenResponse(cfg *config.Config, user models.User, session models.Session, refreshToken, message string) (gin.H, error) {
+	expirationTime := time.Now().Add(cfg.Auth.AccessTokenTTL)
 
 	claims := &jwt.RegisteredClaims{
 		ExpiresAt: jwt.NewNumericDate(expirationTime),
 		IssuedAt:  jwt.NewNumericDate(time.Now()),
 		Issuer:    "samuelabebayehu",
 		Subject:   strconv.Itoa(int(user.ID)),
+		ID:        strconv.Itoa(int(session.ID)),
 	}
 
-	secretKey := []byte(os.Getenv("JWT_SECRET_KEY"))
-	if len(secretKey) == 0 {
-		secretKey = []byte("samuelabebayehu")
-		println("WARNING: JWT_SECRET_KEY environment variable not set. Using insecure default key!")
-	}
+	secretKey := []byte(cfg.Auth.JWTSecret)
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	tokenString, err := token.SignedString(secretKey)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to generate JWT token: " + err.Error()})
-		return
+		return nil, err
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Login successful", "token": tokenString, "username": loginRequest.Username})
+	return gin.H{
+		"message":                  message,
+		"token":                    tokenString,
+		"expires_at":               expirationTime,
+		"refresh_token":            refreshToken,
+		"refresh_token_expires_at": session.ExpiresAt,
+		"username":                 user.Username,
+		"must_change_password":     user.MustChangePassword,
+	}, nil
+}
+
+// newRandomToken returns an unguessable token for refresh tokens and login links.
+func newRandomToken() (string, error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(tokenBytes), nil
+}
+
+// hashToken returns the hash under which a token from newRandomToken is stored.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+	"yom-kitchen/pkg/apierror"
+	"yom-kitchen/pkg/bruteforce"
+	"yom-kitchen/pkg/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loginAllowed checks the login limiter for the keys, responding with 429 and a Retry-After
+// header when the caller has to wait.
+func loginAllowed(c *gin.Context, keys ...bruteforce.Key) bool {
+	limiter := middlewares.GetLoginLimiterFromContext(c)
+	if limiter == nil {
+		return true
+	}
+
+	wait, err := limiter.Check(c.Request.Context(), time.Now(), keys...)
+	if err != nil {
+		apierror.Internal(c, err, "Login is temporarily unavailable")
+		return false
+	}
+	if wait > 0 {
+		seconds := int(math.Ceil(wait.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(seconds))
+		apierror.Abort(c, http.StatusTooManyRequests, apierror.Error{
+			Code:    apierror.CodeTooManyRequests,
+			Message: "Too many failed login attempts, try again later",
+			Details: map[string]any{"retry_after": seconds},
+		})
+		return false
+	}
+	return true
+}
+
+func loginFailed(c *gin.Context, keys ...bruteforce.Key) {
+	if limiter := middlewares.GetLoginLimiterFromContext(c); limiter != nil {
+		if err := limiter.Failure(c.Request.Context(), time.Now(), keys...); err != nil {
+			log.Printf("Error recording failed login: %v", err)
+		}
+	}
+}
+
+// loginSucceeded forgets the failures of the account. The failures of the IP address are kept,
+// so that logging in to one account does not allow more guesses at others.
+func loginSucceeded(c *gin.Context, accountKey bruteforce.Key) {
+	if limiter := middlewares.GetLoginLimiterFromContext(c); limiter != nil {
+		if err := limiter.Reset(c.Request.Context(), accountKey); err != nil {
+			log.Printf("Error resetting login failures: %v", err)
+		}
+	}
+}
+
+// unlockLogin resets the failures of an account for the admin unlock endpoints, returning the
+// number of failures it had.
+func unlockLogin(c *gin.Context, accountKey bruteforce.Key) (int, bool) {
+	limiter := middlewares.GetLoginLimiterFromContext(c)
+	if limiter == nil {
+		return 0, true
+	}
+
+	counter, err := limiter.Counter(c.Request.Context(), accountKey)
+	if err == nil {
+		err = limiter.Reset(c.Request.Context(), accountKey)
+	}
+	if err != nil {
+		apierror.Internal(c, err, "Failed to unlock login")
+		return 0, false
+	}
+	return counter.Failures, true
+}
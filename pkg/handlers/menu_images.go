@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"yom-kitchen/pkg/apierror"
+	"yom-kitchen/pkg/imaging"
+	"yom-kitchen/pkg/middlewares"
+	"yom-kitchen/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// menuFormOverhead is allowed on top of the maximum image size for the other fields of a menu item
+// form and the multipart framing.
+const menuFormOverhead = 1 << 20
+
+// limitMenuForm caps the request body at the maximum image size plus menuFormOverhead, so that a
+// larger upload is refused while it is read rather than spooled to disk first.
+func limitMenuForm(c *gin.Context) {
+	maxBytes := middlewares.GetConfigFromContext(c).Uploads.MaxBytes + menuFormOverhead
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+}
+
+// parseMenuForm limits the request body with limitMenuForm and parses the multipart form of a menu
+// item. It responds with an error itself when it fails.
+func parseMenuForm(c *gin.Context) bool {
+	limitMenuForm(c)
+	err := c.Request.ParseMultipartForm(32 << 20)
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		apierror.PayloadTooLarge(c, fmt.Sprintf("Request too large, the maximum image size is %d bytes",
+			middlewares.GetConfigFromContext(c).Uploads.MaxBytes))
+		return false
+	}
+	if err != nil {
+		apierror.BadRequest(c, "Request body must be a valid multipart form")
+		return false
+	}
+	return true
+}
+
+// saveMenuImage stores the variants of the image uploaded in the "image" form field and sets the
+// image keys of item, leaving them alone when no image was uploaded. It responds with an error
+// itself when it fails.
+func saveMenuImage(c *gin.Context, item *models.MenuItem) bool {
+	file, err := c.FormFile("image")
+	if errors.Is(err, http.ErrMissingFile) {
+		return true
+	}
+	if err != nil {
+		apierror.BadRequest(c, "Invalid image upload")
+		return false
+	}
+
+	uploads := middlewares.GetConfigFromContext(c).Uploads
+	if file.Size > uploads.MaxBytes {
+		apierror.PayloadTooLarge(c, fmt.Sprintf("Image too large, the maximum is %d bytes", uploads.MaxBytes))
+		return false
+	}
+	svc := middlewares.GetServicesFromContext(c)
+	if svc == nil {
+		apierror.Internal(c, nil, "Services not available")
+		return false
+	}
+
+	body, err := file.Open()
+	if err != nil {
+		apierror.Internal(c, err, "Failed to read image")
+		return false
+	}
+	defer body.Close()
+	images, err := imaging.Process(io.LimitReader(body, uploads.MaxBytes))
+	if errors.Is(err, imaging.ErrTooManyPixels) {
+		apierror.PayloadTooLarge(c, err.Error())
+		return false
+	}
+	if err != nil {
+		apierror.BadRequest(c, "Invalid image: "+err.Error())
+		return false
+	}
+
+	if err := svc.Menu.SaveImage(c.Request.Context(), item, images); err != nil {
+		apierror.Internal(c, err, "Failed to save image")
+		return false
+	}
+	return true
+}
+
+// setMenuImageURL fills in the image URLs of a menu item from its keys.
+func setMenuImageURL(c *gin.Context, item *models.MenuItem) {
+	if svc := middlewares.GetServicesFromContext(c); svc != nil {
+		svc.Menu.SetImageURLs(c.Request.Context(), item)
+	}
+}
+
+func setMenuImageURLs(c *gin.Context, items []models.MenuItem) {
+	for i := range items {
+		setMenuImageURL(c, &items[i])
+	}
+}
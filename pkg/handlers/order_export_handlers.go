@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"yom-kitchen/pkg/apierror"
+	"yom-kitchen/pkg/middlewares"
+	"yom-kitchen/pkg/money"
+	"yom-kitchen/pkg/xlsx"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportFlushInterval is the number of rows written between flushes of the response.
+const exportFlushInterval = 500
+
+var orderExportHeader = []string{
+	"order_id", "client_name", "order_date", "status", "notes",
+	"item_name", "item_price", "quantity", "subtotal", "discount", "tax_rate", "tax",
+}
+
+type orderExportRow struct {
+	OrderID    uint
+	ClientName sql.NullString
+	OrderDate  time.Time
+	Status     string
+	Notes      sql.NullString
+	ItemName   string
+	ItemPrice  money.Amount
+	Quantity   int
+	Subtotal   money.Amount
+	Discount   money.Amount
+	TaxRate    float64
+	Tax        money.Amount
+}
+
+// ExportOrdersAdmin streams one row per order item, repeating the order header fields, as CSV
+// or XLSX. It accepts the same filters as GetAllOrdersAdmin and reads rows from the database
+// one at a time so that large ranges are never held in memory. The export is not bound by the
+// server write timeout.
+func ExportOrdersAdmin(c *gin.Context) {
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "xlsx" {
+		apierror.BadRequest(c, "format must be csv or xlsx")
+		return
+	}
+
+	filter, err := orderFilter(c)
+	if err != nil {
+		apierror.BadRequest(c, err.Error())
+		return
+	}
+
+	clearWriteDeadline(c)
+	rows, err := db.Table("order_items").
+		Select("orders.id, clients.name, orders.order_date, orders.status, orders.notes, " +
+			"order_items.item_name, order_items.item_price, order_items.quantity, order_items.subtotal, " +
+			"order_items.discount_amount, order_items.tax_rate, order_items.tax_amount").
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Joins("LEFT JOIN clients ON clients.id = orders.client_id").
+		Where("order_items.deleted_at IS NULL AND orders.deleted_at IS NULL").
+		Scopes(filter.Scope).
+		Order("orders.order_date, orders.id, order_items.id").
+		Rows()
+	if err != nil {
+		apierror.Internal(c, err, "Database error exporting orders")
+		return
+	}
+	defer rows.Close()
+
+	loc := middlewares.GetConfigFromContext(c).Business.Location
+	filename := fmt.Sprintf("orders-%s.%s", time.Now().In(loc).Format("20060102-150405"), format)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	var writeRow func(row orderExportRow) error
+	var finish func() error
+	var flush func()
+	if format == "xlsx" {
+		c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		sheet, err := xlsx.NewStreamWriter(c.Writer, "Orders")
+		if err != nil {
+			log.Printf("Error starting XLSX export: %v", err)
+			return
+		}
+		header := make([]any, len(orderExportHeader))
+		for i, name := range orderExportHeader {
+			header[i] = name
+		}
+		if err := sheet.WriteRow(header...); err != nil {
+			log.Printf("Error writing XLSX export: %v", err)
+			return
+		}
+		writeRow = func(row orderExportRow) error {
+			return sheet.WriteRow(row.OrderID, row.ClientName.String, row.OrderDate.In(loc),
+				row.Status, row.Notes.String, row.ItemName, row.ItemPrice.Float64(), row.Quantity, row.Subtotal.Float64(),
+				row.Discount.Float64(), row.TaxRate, row.Tax.Float64())
+		}
+		flush = func() {
+			if err := sheet.Flush(); err == nil {
+				c.Writer.Flush()
+			}
+		}
+		finish = sheet.Close
+	} else {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		writer := csv.NewWriter(c.Writer)
+		if err := writer.Write(orderExportHeader); err != nil {
+			log.Printf("Error writing CSV export: %v", err)
+			return
+		}
+		writeRow = func(row orderExportRow) error {
+			return writer.Write([]string{
+				strconv.FormatUint(uint64(row.OrderID), 10),
+				xlsx.SanitizeCell(row.ClientName.String),
+				row.OrderDate.In(loc).Format("2006-01-02 15:04:05"),
+				row.Status,
+				xlsx.SanitizeCell(row.Notes.String),
+				xlsx.SanitizeCell(row.ItemName),
+				row.ItemPrice.String(),
+				strconv.Itoa(row.Quantity),
+				row.Subtotal.String(),
+				row.Discount.String(),
+				strconv.FormatFloat(row.TaxRate, 'f', -1, 64),
+				row.Tax.String(),
+			})
+		}
+		flush = func() {
+			writer.Flush()
+			c.Writer.Flush()
+		}
+		finish = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+	}
+
+	count := 0
+	for rows.Next() {
+		var row orderExportRow
+		if err := rows.Scan(&row.OrderID, &row.ClientName, &row.OrderDate, &row.Status, &row.Notes,
+			&row.ItemName, &row.ItemPrice, &row.Quantity, &row.Subtotal,
+			&row.Discount, &row.TaxRate, &row.Tax); err != nil {
+			log.Printf("Error reading order export row: %v", err)
+			return
+		}
+		if err := writeRow(row); err != nil {
+			log.Printf("Error writing order export row: %v", err)
+			return
+		}
+		count++
+		if count%exportFlushInterval == 0 {
+			flush()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Error iterating order export rows: %v", err)
+		return
+	}
+	if err := finish(); err != nil {
+		log.Printf("Error finishing order export: %v", err)
+	}
+}
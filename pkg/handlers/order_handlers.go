@@ -2,122 +2,81 @@ package handlers
 
 import (
 	"errors"
-	"log"
 	"net/http"
 	"strconv"
-	"time"
 
+	"yom-kitchen/pkg/apierror"
 	"yom-kitchen/pkg/middlewares"
 	"yom-kitchen/pkg/models"
+	"yom-kitchen/pkg/money"
+	"yom-kitchen/pkg/pricing"
+	"yom-kitchen/pkg/repository"
+	"yom-kitchen/pkg/services"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 func CreateOrderAdmin(c *gin.Context) {
-	db := middlewares.GetDBFromContext(c)
-	if db == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Database connection not available"})
+	svc := middlewares.GetServicesFromContext(c)
+	if svc == nil {
+		apierror.Internal(c, nil, "Services not available")
 		return
 	}
 
 	var orderRequest struct {
-		ClientID   int `json:"client_id" binding:"required"`
+		ClientID   uint `json:"client_id" binding:"required"`
 		OrderItems []struct {
-			MenuItemID int `json:"menu_item_id" binding:"required"`
-			Quantity   int `json:"quantity" binding:"required,min=1"`
+			MenuItemID      uint         `json:"menu_item_id" binding:"required"`
+			Quantity        int          `json:"quantity" binding:"required,min=1"`
+			DiscountPercent float64      `json:"discount_percent,omitempty" binding:"min=0,max=100"`
+			DiscountAmount  money.Amount `json:"discount_amount,omitempty" binding:"min=0"`
 		} `json:"order_items" binding:"required,min=1,dive"`
-		Notes string `json:"notes,omitempty"`
+		DiscountPercent float64      `json:"discount_percent,omitempty" binding:"min=0,max=100"`
+		DiscountAmount  money.Amount `json:"discount_amount,omitempty" binding:"min=0"`
+		Notes           string       `json:"notes,omitempty"`
 	}
 
 	if err := c.ShouldBindJSON(&orderRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body: "})
+		apierror.Bind(c, err)
 		return
 	}
 
-	var orderItemsForDB []models.OrderItem
-	totalAmount := 0.0
-
-	if err := db.Transaction(func(tx *gorm.DB) error {
-		var client models.Client
-		if err := tx.First(&client, orderRequest.ClientID).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid Client ID"})
-			} else {
-				c.JSON(http.StatusInternalServerError, gin.H{"message": "Database error checking Client: "})
-			}
-			return err
-		}
-
-		for _, itemRequest := range orderRequest.OrderItems {
-			var menuItem models.MenuItem
-			if err := tx.First(&menuItem, itemRequest.MenuItemID).Error; err != nil {
-				if errors.Is(err, gorm.ErrRecordNotFound) {
-					c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid MenuItem ID: "})
-				} else {
-					c.JSON(http.StatusInternalServerError, gin.H{"message": "Database error checking MenuItem: "})
-				}
-				return err
-			}
-
-			if !menuItem.Available {
-				c.JSON(http.StatusBadRequest, gin.H{"message": "MenuItem not available: "})
-				return nil
-			}
-
-			orderItem := models.OrderItem{
-				MenuItemID: itemRequest.MenuItemID,
-				ItemName:   menuItem.Name,
-				ItemPrice:  menuItem.Price,
-				Quantity:   itemRequest.Quantity,
-				Subtotal:   menuItem.Price * float64(itemRequest.Quantity),
-			}
-			orderItemsForDB = append(orderItemsForDB, orderItem)
-			totalAmount += orderItem.Subtotal
-		}
+	newOrder := services.NewOrder{
+		ClientID: orderRequest.ClientID,
+		Discount: pricing.Discount{Percent: orderRequest.DiscountPercent, Amount: orderRequest.DiscountAmount},
+		Notes:    orderRequest.Notes,
+	}
+	for _, itemRequest := range orderRequest.OrderItems {
+		newOrder.Lines = append(newOrder.Lines, services.OrderLine{
+			MenuItemID: itemRequest.MenuItemID,
+			Quantity:   itemRequest.Quantity,
+			Discount:   pricing.Discount{Percent: itemRequest.DiscountPercent, Amount: itemRequest.DiscountAmount},
+		})
+	}
 
-		order := models.Order{
-			ClientID:    orderRequest.ClientID,
-			OrderDate:   time.Now(),
-			OrderItems:  orderItemsForDB,
-			TotalAmount: totalAmount,
-			Status:      "Pending",
-			Notes:       orderRequest.Notes,
-		}
-		if createResult := tx.Create(&order); createResult.Error != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create order: "})
-			return createResult.Error
-		}
-		return nil
-	}); err != nil {
+	if _, err := svc.Orders.Create(c.Request.Context(), actorFromContext(c), newOrder); err != nil {
+		respondServiceError(c, err, "Failed to create order")
 		return
 	}
-
 	c.JSON(http.StatusCreated, gin.H{"message": "Order created successfully"})
 }
 
 func GetOrderAdmin(c *gin.Context) {
-	orderIDStr := c.Param("id")
-	orderID, err := strconv.Atoi(orderIDStr)
+	orderID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid order ID format"})
+		apierror.BadRequest(c, "Invalid order ID format")
 		return
 	}
 
-	db := middlewares.GetDBFromContext(c)
-	if db == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Database connection not available"})
+	svc := middlewares.GetServicesFromContext(c)
+	if svc == nil {
+		apierror.Internal(c, nil, "Services not available")
 		return
 	}
 
-	var order models.Order
-	result := db.Preload("Client").Preload("OrderItems").First(&order, orderID)
-	if result.Error != nil {
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"message": "Order not found"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "Database error fetching order: "})
-		}
+	order, err := svc.Orders.Get(c.Request.Context(), uint(orderID))
+	if err != nil {
+		respondServiceError(c, err, "Database error fetching order")
 		return
 	}
 
@@ -125,50 +84,82 @@ func GetOrderAdmin(c *gin.Context) {
 }
 
 func GetAllOrdersAdmin(c *gin.Context) {
-	db := middlewares.GetDBFromContext(c)
-	if db == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Database connection not available"})
+	svc := middlewares.GetServicesFromContext(c)
+	if svc == nil {
+		apierror.Internal(c, nil, "Services not available")
 		return
 	}
 
-	var orders []models.Order
-	result := db.Preload("Client").Preload("OrderItems").Find(&orders)
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Database error fetching orders: "})
+	query, err := parseListQuery(c, []string{"id", "order_date", "created_at", "total_amount", "status"}, "-order_date")
+	if err != nil {
+		apierror.BadRequest(c, err.Error())
 		return
 	}
-
-	c.JSON(http.StatusOK, orders)
-}
-
-func DeleteOrderAdmin(c *gin.Context) {
-	orderIDStr := c.Param("id")
-	orderID, err := strconv.Atoi(orderIDStr)
+	filter, err := orderFilter(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid order ID format"})
+		apierror.BadRequest(c, err.Error())
 		return
 	}
 
-	db := middlewares.GetDBFromContext(c)
-	if db == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Database connection not available"})
+	orders, total, err := svc.Orders.List(c.Request.Context(), filter, query.page())
+	if err != nil {
+		apierror.Internal(c, err, "Database error fetching orders")
 		return
 	}
 
-	var order models.Order
-	result := db.First(&order, orderID)
-	if result.Error != nil {
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"message": "Order not found"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "Database error checking order: "})
+	c.JSON(http.StatusOK, listResponse(c, query, total, orders))
+}
+
+// orderFilter reads the order list filters: status, client_id, and a from/to range on the order
+// date where to is exclusive.
+func orderFilter(c *gin.Context) (repository.OrderFilter, error) {
+	filter := repository.OrderFilter{Status: c.Query("status")}
+	if filter.Status != "" && !models.IsValidOrderStatus(filter.Status) {
+		return filter, errors.New("invalid status filter")
+	}
+
+	if clientIDStr := c.Query("client_id"); clientIDStr != "" {
+		parsed, err := strconv.ParseUint(clientIDStr, 10, 0)
+		if err != nil {
+			return filter, errors.New("invalid client_id filter")
+		}
+		filter.ClientID = uint(parsed)
+	}
+
+	loc := middlewares.GetConfigFromContext(c).Business.Location
+	if fromStr := c.Query("from"); fromStr != "" {
+		parsed, err := parseDateParam(fromStr, false, loc)
+		if err != nil {
+			return filter, errors.New("invalid from date")
+		}
+		filter.From = parsed
+	}
+	if toStr := c.Query("to"); toStr != "" {
+		parsed, err := parseDateParam(toStr, true, loc)
+		if err != nil {
+			return filter, errors.New("invalid to date")
 		}
+		filter.To = parsed
+	}
+
+	return filter, nil
+}
+
+func DeleteOrderAdmin(c *gin.Context) {
+	orderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.BadRequest(c, "Invalid order ID format")
+		return
+	}
+
+	svc := middlewares.GetServicesFromContext(c)
+	if svc == nil {
+		apierror.Internal(c, nil, "Services not available")
 		return
 	}
 
-	deleteResult := db.Delete(&order)
-	if deleteResult.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to delete order: "})
+	if err := svc.Orders.Delete(c.Request.Context(), actorFromContext(c), uint(orderID)); err != nil {
+		respondServiceError(c, err, "Failed to delete order")
 		return
 	}
 
@@ -176,185 +167,122 @@ func DeleteOrderAdmin(c *gin.Context) {
 }
 
 func UpdateOrderStatusAdmin(c *gin.Context) {
-	orderIDStr := c.Param("id")
-	orderID, err := strconv.Atoi(orderIDStr)
+	orderID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid order ID format"})
+		apierror.BadRequest(c, "Invalid order ID format")
 		return
 	}
 
-	db := middlewares.GetDBFromContext(c)
-	if db == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Database connection not available"})
-		return
-	}
-
-	var order models.Order
-	result := db.First(&order, orderID)
-	if result.Error != nil {
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"message": "Order not found"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "Database error fetching order: "})
-		}
+	svc := middlewares.GetServicesFromContext(c)
+	if svc == nil {
+		apierror.Internal(c, nil, "Services not available")
 		return
 	}
 
-	type OrderStatusUpdateRequest struct {
+	var updateRequest struct {
 		Status string `json:"status" binding:"required"`
+		Reason string `json:"reason,omitempty"`
 	}
-	var updateRequest OrderStatusUpdateRequest
 	if err := c.ShouldBindJSON(&updateRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body: "})
+		apierror.Bind(c, err)
 		return
 	}
 
-	allowedStatuses := []string{"Pending", "Accepted", "Cancelled", "Ready", "Delivered"}
-	isValidStatus := false
-	for _, status := range allowedStatuses {
-		if updateRequest.Status == status {
-			isValidStatus = true
-			break
-		}
-	}
-	if !isValidStatus {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid order status. Allowed statuses: "})
+	order, err := svc.Orders.UpdateStatus(c.Request.Context(), actorFromContext(c), uint(orderID), updateRequest.Status, updateRequest.Reason)
+	if err != nil {
+		respondServiceError(c, err, "Failed to update order status")
 		return
 	}
 
-	result = db.Model(&order).UpdateColumn("status", updateRequest.Status)
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update order status: "})
+	c.JSON(http.StatusOK, gin.H{"message": "Order status updated successfully", "order": order})
+}
+
+func GetOrderStatusHistoryAdmin(c *gin.Context) {
+	orderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.BadRequest(c, "Invalid order ID format")
 		return
 	}
 
-	if result.RowsAffected == 0 {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update order status (no rows affected)"})
+	svc := middlewares.GetServicesFromContext(c)
+	if svc == nil {
+		apierror.Internal(c, nil, "Services not available")
 		return
 	}
 
-	var updatedOrder models.Order
-	db.Preload("Client").Preload("OrderItems").First(&updatedOrder, orderID)
+	order, history, err := svc.Orders.StatusHistory(c.Request.Context(), uint(orderID))
+	if err != nil {
+		respondServiceError(c, err, "Database error fetching order history")
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Order status updated successfully", "order": updatedOrder})
+	c.JSON(http.StatusOK, gin.H{
+		"order_id":            order.ID,
+		"status":              order.Status,
+		"allowed_transitions": models.AllowedOrderStatusTransitions(order.Status),
+		"history":             history,
+	})
 }
 
 func ClientCreateOrderHandler(c *gin.Context) {
-	db := middlewares.GetDBFromContext(c)
-	if db == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Database connection not available"})
+	svc := middlewares.GetServicesFromContext(c)
+	if svc == nil {
+		apierror.Internal(c, nil, "Services not available")
+		return
+	}
+
+	client := middlewares.GetClientFromContext(c)
+	if client == nil {
+		apierror.Unauthorized(c, "Authentication required")
 		return
 	}
 
 	var orderRequest struct {
-		ClientPassword string `json:"passcode" binding:"required"`
-		OrderItems     []struct {
-			MenuItemID int `json:"menu_item_id" binding:"required"`
-			Quantity   int `json:"quantity" binding:"required,min=1"`
+		OrderItems []struct {
+			MenuItemID uint `json:"menu_item_id" binding:"required"`
+			Quantity   int  `json:"quantity" binding:"required,min=1"`
 		} `json:"order_items" binding:"required,min=1,dive"`
-		Notes string `json:"notes,omitempty"`
+		Notes     string `json:"notes,omitempty"`
+		PromoCode string `json:"promo_code,omitempty"`
 	}
 
 	if err := c.ShouldBindJSON(&orderRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body: "})
+		apierror.Bind(c, err)
 		return
 	}
 
-	var client models.Client
-	if err := db.Where("passcode=?", orderRequest.ClientPassword).First(&client).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid Request"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "Database error checking Client: "})
-		}
-		return
+	newOrder := services.NewOrder{
+		ClientID:  client.ID,
+		PromoCode: orderRequest.PromoCode,
+		Notes:     orderRequest.Notes,
 	}
-
-	if client.Passcode != orderRequest.ClientPassword {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid Client Password"})
-		return
+	for _, itemRequest := range orderRequest.OrderItems {
+		newOrder.Lines = append(newOrder.Lines, services.OrderLine{MenuItemID: itemRequest.MenuItemID, Quantity: itemRequest.Quantity})
 	}
 
-	var orderItemsForDB []models.OrderItem
-	totalAmount := 0.0
-	var resolvedClientId = client.ID
-	if err := db.Transaction(func(tx *gorm.DB) error {
-		for _, itemRequest := range orderRequest.OrderItems {
-			var menuItem models.MenuItem
-			if err := tx.First(&menuItem, itemRequest.MenuItemID).Error; err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid MenuItem ID: "})
-				return err
-			}
-
-			if !menuItem.Available {
-				c.JSON(http.StatusBadRequest, gin.H{"message": "MenuItem not available: "})
-				return nil
-			}
-
-			orderItem := models.OrderItem{
-				MenuItemID: itemRequest.MenuItemID,
-				ItemName:   menuItem.Name,
-				ItemPrice:  menuItem.Price,
-				Quantity:   itemRequest.Quantity,
-				Subtotal:   menuItem.Price * float64(itemRequest.Quantity),
-			}
-			orderItemsForDB = append(orderItemsForDB, orderItem)
-			totalAmount += orderItem.Subtotal
-		}
-
-		order := models.Order{
-			ClientID:    int(resolvedClientId),
-			OrderDate:   time.Now(),
-			OrderItems:  orderItemsForDB,
-			TotalAmount: totalAmount,
-			Status:      "Pending",
-			Notes:       orderRequest.Notes,
-		}
-		if createResult := tx.Create(&order); createResult.Error != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create order: "})
-			return createResult.Error
-		}
-		return nil
-	}); err != nil {
+	if _, err := svc.Orders.Create(c.Request.Context(), actorFromContext(c), newOrder); err != nil {
+		respondServiceError(c, err, "Failed to create order")
 		return
 	}
-
 	c.JSON(http.StatusCreated, gin.H{"message": "Order created successfully"})
 }
 
 func ClientGetOrdersHandler(c *gin.Context) {
-	clientPassword := c.Query("client_password")
-
-	if clientPassword == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "Client Password is required"})
-		return
-	}
-
-	db := middlewares.GetDBFromContext(c)
-	if db == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Database connection not available"})
+	svc := middlewares.GetServicesFromContext(c)
+	if svc == nil {
+		apierror.Internal(c, nil, "Services not available")
 		return
 	}
 
-	var client models.Client
-	if err := db.Where("passcode=?", clientPassword).First(&client).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid Request"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "Database error checking Client: "})
-		}
+	client := middlewares.GetClientFromContext(c)
+	if client == nil {
+		apierror.Unauthorized(c, "Authentication required")
 		return
 	}
 
-	if client.Passcode != clientPassword {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid Client Password"})
-		return
-	}
-	log.Printf("fetching orders for client ID: %d", client.ID)
-	var orders []models.Order
-	result := db.Preload("Client").Preload("OrderItems").Where("client_id = ?", client.ID).Find(&orders)
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Database error fetching orders: "})
+	orders, err := svc.Orders.ListForClient(c.Request.Context(), client.ID)
+	if err != nil {
+		apierror.Internal(c, err, "Database error fetching orders")
 		return
 	}
 
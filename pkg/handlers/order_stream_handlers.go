@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"yom-kitchen/pkg/apierror"
+	"yom-kitchen/pkg/events"
+	"yom-kitchen/pkg/middlewares"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+const streamHeartbeatInterval = 15 * time.Second
+
+// StreamOrdersAdmin pushes order events to kitchen displays as Server-Sent Events. A client that
+// reconnects with a Last-Event-ID header (or last_event_id query parameter) receives the events
+// it missed, or a resync event when they are no longer available.
+func StreamOrdersAdmin(c *gin.Context) {
+	bus := middlewares.GetEventBusFromContext(c)
+	if bus == nil {
+		apierror.Internal(c, nil, "Event stream not available")
+		return
+	}
+
+	lastEventIDStr := c.GetHeader("Last-Event-ID")
+	if lastEventIDStr == "" {
+		lastEventIDStr = c.Query("last_event_id")
+	}
+	var lastEventID uint64
+	if lastEventIDStr != "" {
+		parsed, err := strconv.ParseUint(lastEventIDStr, 10, 64)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid Last-Event-ID")
+			return
+		}
+		lastEventID = parsed
+	}
+
+	subscription, missed, complete := bus.Subscribe(lastEventID)
+	defer subscription.Close()
+
+	clearWriteDeadline(c)
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	if !complete {
+		writeStreamEvent(c, events.Event{Type: events.Resync, Time: time.Now()})
+	}
+	for _, event := range missed {
+		writeStreamEvent(c, event)
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-subscription.C:
+			if !ok {
+				// The subscriber fell behind and was dropped; the client reconnects and resumes.
+				return
+			}
+			writeStreamEvent(c, event)
+			c.Writer.Flush()
+		case <-heartbeat.C:
+			if _, err := c.Writer.WriteString(": heartbeat\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// clearWriteDeadline lifts the server write timeout for a response that is streamed for as long as
+// it takes, such as an event stream or a large export.
+func clearWriteDeadline(c *gin.Context) {
+	// Writers that have no deadline, such as test recorders, do not support it.
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+}
+
+func writeStreamEvent(c *gin.Context, event events.Event) {
+	sseEvent := sse.Event{Event: event.Type, Data: event}
+	if event.ID != 0 {
+		sseEvent.Id = strconv.FormatUint(event.ID, 10)
+	}
+	c.Render(-1, sseEvent)
+}
@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"yom-kitchen/pkg/apierror"
+	"yom-kitchen/pkg/middlewares"
+	"yom-kitchen/pkg/models"
+	"yom-kitchen/pkg/money"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type promoRequest struct {
+	Code           string       `json:"code" binding:"required"`
+	Description    string       `json:"description"`
+	DiscountType   string       `json:"discount_type" binding:"required,oneof=percentage fixed"`
+	Percent        float64      `json:"percent" binding:"min=0,max=100"`
+	Amount         money.Amount `json:"amount" binding:"min=0"`
+	MinOrderAmount money.Amount `json:"min_order_amount" binding:"min=0"`
+	StartsAt       *time.Time   `json:"starts_at"`
+	ExpiresAt      *time.Time   `json:"expires_at"`
+	MaxRedemptions int          `json:"max_redemptions" binding:"min=0"`
+	MaxPerClient   int          `json:"max_per_client" binding:"min=0"`
+	IsActive       *bool        `json:"is_active"`
+	MenuItemIDs    []uint       `json:"menu_item_ids"`
+	Categories     []string     `json:"categories"`
+}
+
+// validate checks the rules the binding tags cannot express and returns a message for the client.
+func (r *promoRequest) validate() string {
+	if r.DiscountType == models.PromoTypePercentage && (r.Percent <= 0 || r.Amount != 0) {
+		return "A percentage promo needs a percent between 0 and 100 and no amount"
+	}
+	if r.DiscountType == models.PromoTypeFixed && (r.Amount <= 0 || r.Percent != 0) {
+		return "A fixed promo needs a positive amount and no percent"
+	}
+	if r.StartsAt != nil && r.ExpiresAt != nil && !r.StartsAt.Before(*r.ExpiresAt) {
+		return "starts_at must be before expires_at"
+	}
+	return ""
+}
+
+// apply copies the request onto promo and loads the menu items it is restricted to.
+func (r *promoRequest) apply(tx *gorm.DB, promo *models.Promo) error {
+	promo.Code = models.NormalizePromoCode(r.Code)
+	promo.Description = r.Description
+	promo.DiscountType = r.DiscountType
+	promo.Percent = r.Percent
+	promo.Amount = r.Amount
+	promo.MinOrderAmount = r.MinOrderAmount
+	promo.StartsAt = r.StartsAt
+	promo.ExpiresAt = r.ExpiresAt
+	promo.MaxRedemptions = r.MaxRedemptions
+	promo.MaxPerClient = r.MaxPerClient
+	promo.IsActive = r.IsActive == nil || *r.IsActive
+
+	promo.MenuItems = nil
+	if len(r.MenuItemIDs) > 0 {
+		if err := tx.Find(&promo.MenuItems, r.MenuItemIDs).Error; err != nil {
+			return err
+		}
+		if len(promo.MenuItems) != len(r.MenuItemIDs) {
+			return errInvalidPromoMenuItems
+		}
+	}
+	promo.Categories = nil
+	for _, category := range r.Categories {
+		promo.Categories = append(promo.Categories, models.PromoCategory{Category: category})
+	}
+	return nil
+}
+
+func CreatePromoAdmin(c *gin.Context) {
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	var request promoRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Bind(c, err)
+		return
+	}
+	if message := request.validate(); message != "" {
+		apierror.BadRequest(c, message)
+		return
+	}
+
+	var promo models.Promo
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		var existing models.Promo
+		if err := tx.Unscoped().Where("code = ?", models.NormalizePromoCode(request.Code)).First(&existing).Error; err == nil {
+			apierror.Conflict(c, "Promo code already exists")
+			return errPromoCodeTaken
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Internal(c, err, "Database error checking promo code")
+			return err
+		}
+
+		if err := request.apply(tx, &promo); err != nil {
+			respondPromoRequestError(c, err)
+			return err
+		}
+		if err := tx.Create(&promo).Error; err != nil {
+			apierror.Internal(c, err, "Failed to create promo")
+			return err
+		}
+		recordAudit(c, tx, models.AuditActionCreate, "promo", promo.ID, nil, promo)
+		return nil
+	}); err != nil {
+		return
+	}
+
+	setMenuImageURLs(c, promo.MenuItems)
+	c.JSON(http.StatusCreated, promo)
+}
+
+func GetAllPromosAdmin(c *gin.Context) {
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	query, err := parseListQuery(c, []string{"id", "code", "created_at", "expires_at"}, "-created_at")
+	if err != nil {
+		apierror.BadRequest(c, err.Error())
+		return
+	}
+
+	var total int64
+	if err := db.Model(&models.Promo{}).Count(&total).Error; err != nil {
+		apierror.Internal(c, err, "Database error counting promos")
+		return
+	}
+
+	promos := []models.Promo{}
+	if err := db.Scopes(query.paginate).Preload("MenuItems").Preload("Categories").Find(&promos).Error; err != nil {
+		apierror.Internal(c, err, "Database error fetching promos")
+		return
+	}
+
+	for i := range promos {
+		setMenuImageURLs(c, promos[i].MenuItems)
+	}
+	c.JSON(http.StatusOK, listResponse(c, query, total, promos))
+}
+
+func GetPromoAdmin(c *gin.Context) {
+	promoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.BadRequest(c, "Invalid promo ID format")
+		return
+	}
+
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	var promo models.Promo
+	if err := db.Preload("MenuItems").Preload("Categories").First(&promo, promoID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.NotFound(c, "Promo not found")
+		} else {
+			apierror.Internal(c, err, "Database error fetching promo")
+		}
+		return
+	}
+
+	redemptions := []models.PromoRedemption{}
+	if err := db.Where("promo_id = ?", promo.ID).Order("created_at DESC").Find(&redemptions).Error; err != nil {
+		apierror.Internal(c, err, "Database error fetching promo redemptions")
+		return
+	}
+
+	setMenuImageURLs(c, promo.MenuItems)
+	c.JSON(http.StatusOK, gin.H{"promo": promo, "redemptions": redemptions})
+}
+
+func UpdatePromoAdmin(c *gin.Context) {
+	promoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.BadRequest(c, "Invalid promo ID format")
+		return
+	}
+
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	var request promoRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Bind(c, err)
+		return
+	}
+	if message := request.validate(); message != "" {
+		apierror.BadRequest(c, message)
+		return
+	}
+
+	var promo models.Promo
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&promo, promoID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				apierror.NotFound(c, "Promo not found")
+			} else {
+				apierror.Internal(c, err, "Database error fetching promo")
+			}
+			return err
+		}
+
+		var before models.Promo
+		if err := tx.Preload("MenuItems").Preload("Categories").First(&before, promo.ID).Error; err != nil {
+			apierror.Internal(c, err, "Database error fetching promo")
+			return err
+		}
+
+		var existing models.Promo
+		err := tx.Unscoped().Where("code = ? AND id <> ?", models.NormalizePromoCode(request.Code), promo.ID).First(&existing).Error
+		if err == nil {
+			apierror.Conflict(c, "Promo code already exists")
+			return errPromoCodeTaken
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Internal(c, err, "Database error checking promo code")
+			return err
+		}
+
+		if err := request.apply(tx, &promo); err != nil {
+			respondPromoRequestError(c, err)
+			return err
+		}
+		if err := tx.Omit(clause.Associations).Save(&promo).Error; err != nil {
+			apierror.Internal(c, err, "Failed to update promo")
+			return err
+		}
+		if err := tx.Model(&promo).Association("MenuItems").Replace(promo.MenuItems); err != nil {
+			apierror.Internal(c, err, "Failed to update promo menu items")
+			return err
+		}
+		if err := tx.Where("promo_id = ?", promo.ID).Delete(&models.PromoCategory{}).Error; err != nil {
+			apierror.Internal(c, err, "Failed to update promo categories")
+			return err
+		}
+		for i := range promo.Categories {
+			promo.Categories[i].PromoID = promo.ID
+		}
+		if len(promo.Categories) > 0 {
+			if err := tx.Create(&promo.Categories).Error; err != nil {
+				apierror.Internal(c, err, "Failed to update promo categories")
+				return err
+			}
+		}
+		recordAudit(c, tx, models.AuditActionUpdate, "promo", promo.ID, before, promo)
+		return nil
+	}); err != nil {
+		return
+	}
+
+	setMenuImageURLs(c, promo.MenuItems)
+	c.JSON(http.StatusOK, gin.H{"message": "Promo updated successfully", "promo": promo})
+}
+
+func DeletePromoAdmin(c *gin.Context) {
+	promoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.BadRequest(c, "Invalid promo ID format")
+		return
+	}
+
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	var promo models.Promo
+	if err := db.Preload("MenuItems").Preload("Categories").First(&promo, promoID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.NotFound(c, "Promo not found")
+		} else {
+			apierror.Internal(c, err, "Database error fetching promo")
+		}
+		return
+	}
+
+	if err := db.Delete(&promo).Error; err != nil {
+		apierror.Internal(c, err, "Failed to delete promo")
+		return
+	}
+
+	recordAudit(c, db, models.AuditActionDelete, "promo", promo.ID, promo, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Promo deleted successfully", "promo_id": promoID})
+}
+
+var (
+	errPromoCodeTaken        = errors.New("promo code already exists")
+	errInvalidPromoMenuItems = errors.New("unknown menu item in promo restriction")
+)
+
+func respondPromoRequestError(c *gin.Context, err error) {
+	if errors.Is(err, errInvalidPromoMenuItems) {
+		apierror.BadRequest(c, "Invalid menu_item_ids")
+		return
+	}
+	apierror.Internal(c, err, "Database error loading promo menu items")
+}
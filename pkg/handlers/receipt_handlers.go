@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"yom-kitchen/pkg/apierror"
+	"yom-kitchen/pkg/middlewares"
+	"yom-kitchen/pkg/models"
+	"yom-kitchen/pkg/receipt"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func GetOrderReceiptAdmin(c *gin.Context) {
+	orderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.BadRequest(c, "Invalid order ID format")
+		return
+	}
+
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	var order models.Order
+	if err := db.Preload("Client").Preload("OrderItems").Preload("TaxLines").First(&order, orderID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.NotFound(c, "Order not found")
+		} else {
+			apierror.Internal(c, err, "Database error fetching order")
+		}
+		return
+	}
+
+	renderOrderReceipt(c, db, order)
+}
+
+// ClientGetOrderReceiptHandler serves the receipt of one of the client's own orders, authorized
+// by the client token like ClientGetOrdersHandler.
+func ClientGetOrderReceiptHandler(c *gin.Context) {
+	orderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.BadRequest(c, "Invalid order ID format")
+		return
+	}
+
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	client := middlewares.GetClientFromContext(c)
+	if client == nil {
+		apierror.Unauthorized(c, "Authentication required")
+		return
+	}
+
+	var order models.Order
+	result := db.Preload("Client").Preload("OrderItems").Preload("TaxLines").
+		Where("client_id = ?", client.ID).
+		First(&order, orderID)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			apierror.NotFound(c, "Order not found")
+		} else {
+			apierror.Internal(c, result.Error, "Database error fetching order")
+		}
+		return
+	}
+
+	renderOrderReceipt(c, db, order)
+}
+
+func renderOrderReceipt(c *gin.Context, db *gorm.DB, order models.Order) {
+	business := middlewares.GetConfigFromContext(c).Business
+	loc := business.Location
+	doc := receipt.Receipt{
+		BusinessName:    business.Name,
+		BusinessAddress: business.Address,
+		BusinessTaxID:   business.TaxID,
+		Currency:        business.Currency,
+		OrderID:         order.ID,
+		OrderDate:       order.OrderDate.In(loc),
+		Status:          order.Status,
+		ClientName:      order.Client.Name,
+		ClientAddress:   order.Client.Address,
+		ClientPhone:     order.Client.Phone,
+		Notes:           order.Notes,
+	}
+
+	var invoice models.Invoice
+	if err := db.Where("order_id = ?", order.ID).First(&invoice).Error; err == nil {
+		doc.InvoiceNumber = invoice.InvoiceNumber
+		doc.IssuedAt = invoice.IssuedAt.In(loc)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		apierror.Internal(c, err, "Database error fetching invoice")
+		return
+	}
+
+	for _, item := range order.OrderItems {
+		doc.Lines = append(doc.Lines, receipt.Line{
+			Name:      item.ItemName,
+			Quantity:  item.Quantity,
+			UnitPrice: item.ItemPrice,
+			Amount:    item.Subtotal,
+		})
+	}
+	doc.Totals = append(doc.Totals, receipt.Total{Label: "Subtotal", Amount: order.SubtotalAmount})
+	if order.DiscountAmount != 0 {
+		doc.Totals = append(doc.Totals,
+			receipt.Total{Label: "Discount", Amount: -order.DiscountAmount},
+			receipt.Total{Label: "Net amount", Amount: order.NetAmount})
+	}
+	if order.ServiceChargeAmount != 0 {
+		doc.Totals = append(doc.Totals, receipt.Total{Label: "Service charge", Amount: order.ServiceChargeAmount})
+	}
+	for _, taxLine := range order.TaxLines {
+		doc.Totals = append(doc.Totals, receipt.Total{
+			Label:  fmt.Sprintf("VAT %s%% on %s", strconv.FormatFloat(taxLine.Rate, 'f', -1, 64), taxLine.TaxableAmount),
+			Amount: taxLine.TaxAmount,
+		})
+	}
+	doc.Totals = append(doc.Totals, receipt.Total{Label: "Total", Amount: order.TotalAmount, Bold: true})
+
+	var buf bytes.Buffer
+	if err := receipt.Render(&buf, doc); err != nil {
+		apierror.Internal(c, err, "Failed to render receipt")
+		return
+	}
+
+	filename := fmt.Sprintf("receipt-%d.pdf", order.ID)
+	if doc.InvoiceNumber != "" {
+		filename = "invoice-" + doc.InvoiceNumber + ".pdf"
+	}
+	c.Header("Content-Disposition", `inline; filename="`+filename+`"`)
+	c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
@@ -0,0 +1,361 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"yom-kitchen/pkg/apierror"
+	"yom-kitchen/pkg/middlewares"
+	"yom-kitchen/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type roleResponse struct {
+	ID          uint     `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	IsSystem    bool     `json:"is_system"`
+	RequireTOTP bool     `json:"require_totp"`
+	Permissions []string `json:"permissions"`
+}
+
+func newRoleResponse(role models.Role) roleResponse {
+	return roleResponse{
+		ID:          role.ID,
+		Name:        role.Name,
+		Description: role.Description,
+		IsSystem:    role.IsSystem,
+		RequireTOTP: role.RequireTOTP,
+		Permissions: role.PermissionNames(),
+	}
+}
+
+type roleRequest struct {
+	Name        *string  `json:"name"`
+	Description *string  `json:"description"`
+	RequireTOTP *bool    `json:"require_totp"`
+	Permissions []string `json:"permissions"`
+}
+
+func (r roleRequest) validate() error {
+	if r.Name != nil && *r.Name == "" {
+		return errors.New("name cannot be empty")
+	}
+	for _, permission := range r.Permissions {
+		if !models.IsValidPermission(permission) {
+			return errors.New("unknown permission: " + permission)
+		}
+	}
+	return nil
+}
+
+func GetPermissionsAdmin(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"permissions": append([]string{models.PermissionAll}, models.Permissions...)})
+}
+
+func GetAllRolesAdmin(c *gin.Context) {
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	var roles []models.Role
+	if err := db.Preload("Permissions").Order("name").Find(&roles).Error; err != nil {
+		apierror.Internal(c, err, "Database error fetching roles")
+		return
+	}
+
+	response := make([]roleResponse, 0, len(roles))
+	for _, role := range roles {
+		response = append(response, newRoleResponse(role))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func GetRoleAdmin(c *gin.Context) {
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	role, ok := findRole(c, db)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, newRoleResponse(role))
+}
+
+func CreateRoleAdmin(c *gin.Context) {
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	var request roleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Bind(c, err)
+		return
+	}
+	if request.Name == nil {
+		apierror.BadRequest(c, "Invalid request body: name is required")
+		return
+	}
+	if err := request.validate(); err != nil {
+		apierror.BadRequest(c, err.Error())
+		return
+	}
+
+	if roleNameTaken(c, db, *request.Name, 0) {
+		return
+	}
+
+	role := models.Role{Name: *request.Name}
+	if request.Description != nil {
+		role.Description = *request.Description
+	}
+	if request.RequireTOTP != nil {
+		role.RequireTOTP = *request.RequireTOTP
+	}
+	for _, permission := range uniquePermissions(request.Permissions) {
+		role.Permissions = append(role.Permissions, models.RolePermission{Permission: permission})
+	}
+	if err := db.Create(&role).Error; err != nil {
+		apierror.Internal(c, err, "Failed to create role")
+		return
+	}
+	recordAudit(c, db, models.AuditActionCreate, "role", role.ID, nil, newRoleResponse(role))
+
+	c.JSON(http.StatusCreated, newRoleResponse(role))
+}
+
+// UpdateRoleAdmin changes a role. Permissions, when given, replace the role's permissions.
+func UpdateRoleAdmin(c *gin.Context) {
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	var request roleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Bind(c, err)
+		return
+	}
+	if err := request.validate(); err != nil {
+		apierror.BadRequest(c, err.Error())
+		return
+	}
+
+	role, ok := findRole(c, db)
+	if !ok {
+		return
+	}
+
+	if role.IsSystem && request.Name != nil && *request.Name != role.Name {
+		apierror.Conflict(c, "System roles cannot be renamed")
+		return
+	}
+	if role.Name == models.RoleOwner && request.Permissions != nil {
+		apierror.Conflict(c, "The permissions of the owner role cannot be changed")
+		return
+	}
+	if request.Name != nil && roleNameTaken(c, db, *request.Name, role.ID) {
+		return
+	}
+
+	before := newRoleResponse(role)
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		updates := make(map[string]interface{})
+		if request.Name != nil {
+			updates["name"] = *request.Name
+		}
+		if request.Description != nil {
+			updates["description"] = *request.Description
+		}
+		if request.RequireTOTP != nil {
+			updates["require_totp"] = *request.RequireTOTP
+		}
+		if len(updates) > 0 {
+			if err := tx.Model(&role).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+
+		if request.Permissions != nil {
+			if err := tx.Where("role_id = ?", role.ID).Delete(&models.RolePermission{}).Error; err != nil {
+				return err
+			}
+			var permissions []models.RolePermission
+			for _, permission := range uniquePermissions(request.Permissions) {
+				permissions = append(permissions, models.RolePermission{RoleID: role.ID, Permission: permission})
+			}
+			if len(permissions) > 0 {
+				if err := tx.Create(&permissions).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}); err != nil {
+		apierror.Internal(c, err, "Failed to update role")
+		return
+	}
+
+	db.Preload("Permissions").First(&role, role.ID)
+	recordAudit(c, db, models.AuditActionUpdate, "role", role.ID, before, newRoleResponse(role))
+	c.JSON(http.StatusOK, gin.H{"message": "Role updated successfully", "role": newRoleResponse(role)})
+}
+
+func DeleteRoleAdmin(c *gin.Context) {
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	role, ok := findRole(c, db)
+	if !ok {
+		return
+	}
+	if role.IsSystem {
+		apierror.Conflict(c, "System roles cannot be deleted")
+		return
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&role).Association("Users").Clear(); err != nil {
+			return err
+		}
+		return tx.Unscoped().Select("Permissions").Delete(&role).Error
+	}); err != nil {
+		apierror.Internal(c, err, "Failed to delete role")
+		return
+	}
+
+	recordAudit(c, db, models.AuditActionDelete, "role", role.ID, newRoleResponse(role), nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully", "role_id": role.ID})
+}
+
+// SetUserRolesAdmin replaces the roles of a user.
+func SetUserRolesAdmin(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.BadRequest(c, "Invalid user ID format")
+		return
+	}
+
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	var request struct {
+		RoleIDs []uint `json:"role_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Bind(c, err)
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.NotFound(c, "User not found")
+		} else {
+			apierror.Internal(c, err, "Database error fetching user")
+		}
+		return
+	}
+
+	roles, ok := findRolesByID(c, db, request.RoleIDs)
+	if !ok {
+		return
+	}
+
+	var previousRoles []models.Role
+	if err := db.Model(&user).Association("Roles").Find(&previousRoles); err != nil {
+		apierror.Internal(c, err, "Database error fetching user roles")
+		return
+	}
+
+	if err := db.Model(&user).Association("Roles").Replace(roles); err != nil {
+		apierror.Internal(c, err, "Failed to update user roles")
+		return
+	}
+
+	recordAudit(c, db, models.AuditActionUpdate, "user", user.ID,
+		map[string]any{"roles": roleNames(previousRoles)}, map[string]any{"roles": roleNames(roles)})
+	c.JSON(http.StatusOK, gin.H{"message": "User roles updated successfully", "user_id": user.ID, "roles": roleNames(roles)})
+}
+
+func findRole(c *gin.Context, db *gorm.DB) (models.Role, bool) {
+	var role models.Role
+	roleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.BadRequest(c, "Invalid role ID format")
+		return role, false
+	}
+	if err := db.Preload("Permissions").First(&role, roleID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.NotFound(c, "Role not found")
+		} else {
+			apierror.Internal(c, err, "Database error fetching role")
+		}
+		return role, false
+	}
+	return role, true
+}
+
+// findRolesByID loads the roles with the given IDs, responding with an error if one is missing.
+func findRolesByID(c *gin.Context, db *gorm.DB, roleIDs []uint) ([]models.Role, bool) {
+	roles := []models.Role{}
+	if len(roleIDs) == 0 {
+		return roles, true
+	}
+	if err := db.Where("id IN ?", roleIDs).Find(&roles).Error; err != nil {
+		apierror.Internal(c, err, "Database error fetching roles")
+		return nil, false
+	}
+	for _, roleID := range roleIDs {
+		if !slices.ContainsFunc(roles, func(role models.Role) bool { return role.ID == roleID }) {
+			apierror.BadRequest(c, "Invalid role ID: "+strconv.Itoa(int(roleID)))
+			return nil, false
+		}
+	}
+	return roles, true
+}
+
+func roleNameTaken(c *gin.Context, db *gorm.DB, name string, exceptRoleID uint) bool {
+	var existing models.Role
+	err := db.Where("name = ? AND id <> ?", name, exceptRoleID).First(&existing).Error
+	if err == nil {
+		apierror.Conflict(c, "Role name already exists")
+		return true
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		apierror.Internal(c, err, "Database error checking role name")
+		return true
+	}
+	return false
+}
+
+func uniquePermissions(permissions []string) []string {
+	unique := slices.Clone(permissions)
+	slices.Sort(unique)
+	return slices.Compact(unique)
+}
+
+func roleNames(roles []models.Role) []string {
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		names = append(names, role.Name)
+	}
+	return names
+}
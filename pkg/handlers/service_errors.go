@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"yom-kitchen/pkg/apierror"
+	"yom-kitchen/pkg/pricing"
+	"yom-kitchen/pkg/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondServiceError responds with the error returned by a service. Errors the service does not
+// know about are internal errors, answered with message.
+func respondServiceError(c *gin.Context, err error, message string) {
+	var transition *services.StatusTransitionError
+	var invalidPromo *services.PromoError
+	var permission *services.PermissionError
+	var invalidRole *services.InvalidRoleError
+	switch {
+	case errors.Is(err, services.ErrOrderNotFound):
+		apierror.NotFound(c, "Order not found")
+	case errors.Is(err, services.ErrMenuItemNotFound):
+		apierror.NotFound(c, "Menu item not found")
+	case errors.Is(err, services.ErrClientNotFound):
+		apierror.NotFound(c, "Client not found")
+	case errors.Is(err, services.ErrUserNotFound):
+		apierror.NotFound(c, "User not found")
+	case errors.Is(err, services.ErrMenuItemExists):
+		apierror.Conflict(c, "Menu item already exists")
+	case errors.Is(err, services.ErrClientExists):
+		apierror.Conflict(c, "Client or email already exists")
+	case errors.Is(err, services.ErrUsernameTaken):
+		apierror.Conflict(c, "Username already exists")
+	case errors.Is(err, services.ErrInvalidClient):
+		apierror.BadRequest(c, "Invalid Client ID")
+	case errors.Is(err, services.ErrInvalidMenuItem):
+		apierror.BadRequest(c, "Invalid MenuItem ID")
+	case errors.Is(err, services.ErrMenuItemUnavailable):
+		apierror.BadRequest(c, "MenuItem not available")
+	case errors.Is(err, services.ErrInvalidOrderStatus):
+		apierror.BadRequest(c, "Invalid order status")
+	case errors.Is(err, services.ErrDiscountWithPromo):
+		apierror.BadRequest(c, "An order cannot have both a discount and a promo code")
+	case errors.Is(err, pricing.ErrInvalidDiscount), errors.Is(err, pricing.ErrDiscountTooHigh):
+		apierror.BadRequest(c, err.Error())
+	case errors.As(err, &transition):
+		apierror.Abort(c, http.StatusConflict, apierror.Error{
+			Code:    apierror.CodeInvalidStatusTransition,
+			Message: "Cannot change order status from " + transition.From + " to " + transition.To,
+			Details: map[string]any{
+				"current_status":      transition.From,
+				"allowed_transitions": transition.Allowed,
+			},
+		})
+	case errors.As(err, &invalidPromo):
+		apierror.BadRequest(c, invalidPromo.Message)
+	case errors.As(err, &permission):
+		apierror.Forbidden(c, "Forbidden - "+permission.Permission+" permission required")
+	case errors.As(err, &invalidRole):
+		apierror.BadRequest(c, "Invalid role ID: "+strconv.Itoa(int(invalidRole.RoleID)))
+	default:
+		apierror.Internal(c, err, message)
+	}
+}
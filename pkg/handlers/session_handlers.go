@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"yom-kitchen/pkg/apierror"
+	"yom-kitchen/pkg/middlewares"
+	"yom-kitchen/pkg/models"
+	"yom-kitchen/pkg/repository"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetUserSessionsAdmin lists a user's sessions, most recently used first. With active=true only
+// the sessions that are neither revoked nor expired are listed.
+func GetUserSessionsAdmin(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.BadRequest(c, "Invalid user ID format")
+		return
+	}
+
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	if !userExists(c, db, userID) {
+		return
+	}
+
+	query := db.Where("user_id = ?", userID)
+	if activeStr := c.Query("active"); activeStr != "" {
+		active, err := strconv.ParseBool(activeStr)
+		if err != nil {
+			apierror.BadRequest(c, "invalid active filter")
+			return
+		}
+		if active {
+			query = query.Where("revoked_at IS NULL AND expires_at > ?", time.Now())
+		} else {
+			query = query.Where("revoked_at IS NOT NULL OR expires_at <= ?", time.Now())
+		}
+	}
+
+	sessions := []models.Session{}
+	if err := query.Order("last_used_at DESC").Find(&sessions).Error; err != nil {
+		apierror.Internal(c, err, "Database error fetching sessions")
+		return
+	}
+
+	var current uint
+	if session := middlewares.GetSessionFromContext(c); session != nil {
+		current = session.ID
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions, "current_session_id": current})
+}
+
+func RevokeUserSessionAdmin(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.BadRequest(c, "Invalid user ID format")
+		return
+	}
+	sessionID, err := strconv.Atoi(c.Param("sessionId"))
+	if err != nil {
+		apierror.BadRequest(c, "Invalid session ID format")
+		return
+	}
+
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	var session models.Session
+	if err := db.Where("user_id = ?", userID).First(&session, sessionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.NotFound(c, "Session not found")
+		} else {
+			apierror.Internal(c, err, "Database error fetching session")
+		}
+		return
+	}
+
+	if session.RevokedAt == nil {
+		before := session
+		if err := db.Model(&session).UpdateColumn("revoked_at", time.Now()).Error; err != nil {
+			apierror.Internal(c, err, "Failed to revoke session")
+			return
+		}
+		recordAudit(c, db, models.AuditActionUpdate, "session", session.ID, before, session)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully", "session_id": session.ID})
+}
+
+// RevokeUserSessionsAdmin revokes all of a user's sessions, signing them out everywhere.
+func RevokeUserSessionsAdmin(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.BadRequest(c, "Invalid user ID format")
+		return
+	}
+
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	if !userExists(c, db, userID) {
+		return
+	}
+
+	revoked, err := revokeUserSessions(db, uint(userID), 0)
+	if err != nil {
+		apierror.Internal(c, err, "Failed to revoke sessions")
+		return
+	}
+
+	recordAudit(c, db, models.AuditActionUpdate, "user", userID,
+		map[string]any{"active_sessions": revoked}, map[string]any{"active_sessions": 0})
+	c.JSON(http.StatusOK, gin.H{"message": "Sessions revoked successfully", "revoked": revoked})
+}
+
+// revokeUserSessions revokes the active sessions of a user except exceptSessionID, if not zero.
+func revokeUserSessions(db *gorm.DB, userID uint, exceptSessionID uint) (int64, error) {
+	return repository.NewPostgresUserRepository(db).RevokeSessions(db.Statement.Context, userID, exceptSessionID)
+}
+
+func userExists(c *gin.Context, db *gorm.DB, userID int) bool {
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.NotFound(c, "User not found")
+		} else {
+			apierror.Internal(c, err, "Database error fetching user")
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"sync"
+	"yom-kitchen/pkg/apierror"
+	"yom-kitchen/pkg/bruteforce"
+	"yom-kitchen/pkg/middlewares"
+	"yom-kitchen/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Setup returns the handler that creates the first admin account with the one-time setup token
+// printed at startup. It stops working once an admin exists.
+func Setup(setupToken string) gin.HandlerFunc {
+	tokenHash := hashToken(setupToken)
+	var mu sync.Mutex
+
+	return func(c *gin.Context) {
+		db := middlewares.GetDBFromContext(c)
+		if db == nil {
+			apierror.Internal(c, nil, "Database connection not available")
+			return
+		}
+
+		var request struct {
+			SetupToken string `json:"setup_token" binding:"required"`
+			Username   string `json:"username" binding:"required"`
+			Password   string `json:"password" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			apierror.Bind(c, err)
+			return
+		}
+
+		ipKey := bruteforce.IPKey(c.ClientIP())
+		if !loginAllowed(c, ipKey) {
+			return
+		}
+		if setupToken == "" || subtle.ConstantTimeCompare([]byte(hashToken(request.SetupToken)), []byte(tokenHash)) != 1 {
+			loginFailed(c, ipKey)
+			apierror.Unauthorized(c, "Invalid setup token")
+			return
+		}
+		if err := validateNewPassword(request.Password); err != nil {
+			apierror.BadRequest(c, err.Error())
+			return
+		}
+
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+		if err != nil {
+			apierror.Internal(c, err, "Failed to hash password")
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		var existingAdmin models.User
+		err = db.Where("is_admin = ?", true).First(&existingAdmin).Error
+		if err == nil {
+			apierror.Conflict(c, "Setup has already been completed")
+			return
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Internal(c, err, "Database error checking admin users")
+			return
+		}
+		var existingUser models.User
+		err = db.Unscoped().Where("username = ?", request.Username).First(&existingUser).Error
+		if err == nil {
+			apierror.Conflict(c, "Username already exists")
+			return
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Internal(c, err, "Database error checking username")
+			return
+		}
+
+		admin := models.User{
+			Username:     request.Username,
+			PasswordHash: string(hashedPassword),
+			IsAdmin:      true,
+		}
+		if err := db.Create(&admin).Error; err != nil {
+			apierror.Internal(c, err, "Failed to create admin user")
+			return
+		}
+
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), middlewares.UserContextKey, &admin))
+		recordAudit(c, db, models.AuditActionCreate, "user", admin.ID, nil, admin)
+		c.JSON(http.StatusCreated, gin.H{"message": "Admin user created successfully", "user_id": admin.ID, "username": admin.Username})
+	}
+}
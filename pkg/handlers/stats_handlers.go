@@ -2,19 +2,24 @@ package handlers
 
 import (
 	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"yom-kitchen/pkg/apierror"
 	"yom-kitchen/pkg/middlewares"
 	"yom-kitchen/pkg/models"
+	"yom-kitchen/pkg/money"
 )
 
 // GetStatsAdmin retrieves dashboard statistics for the admin panel.
 func GetStatsAdmin(c *gin.Context) {
 	db := middlewares.GetDBFromContext(c)
 	if db == nil {
-		c.String(http.StatusInternalServerError, "Database connection not available")
+		apierror.Internal(c, nil, "Database connection not available")
 		return
 	}
 
@@ -31,11 +36,12 @@ func GetStatsAdmin(c *gin.Context) {
 	db.Model(&models.Client{}).Count(&clientCount)
 	totalClients := int(clientCount)
 
-	var todayRevenue float64
-	today := time.Now().Format("2006-01-02")
+	var todayRevenue money.Amount
+	today := startOfDay(time.Now().In(middlewares.GetConfigFromContext(c).Business.Location))
 	resultRevenue := db.Model(&models.Order{}).
-		Where("DATE(created_at) = ?", today).
-		Select("COALESCE(SUM(total_amount), 0)").
+		Where("order_date >= ? AND order_date < ?", today, today.AddDate(0, 0, 1)).
+		Where("status <> ?", models.OrderStatusCancelled).
+		Select("COALESCE(SUM(total_amount), 0)::bigint").
 		Scan(&todayRevenue)
 	if resultRevenue.Error != nil {
 		todayRevenue = 0
@@ -45,7 +51,7 @@ func GetStatsAdmin(c *gin.Context) {
 
 	var pendingOrderCount int64
 	db.Model(&models.Order{}).
-		Where("status = ?", "pending").
+		Where("status = ?", models.OrderStatusPending).
 		Count(&pendingOrderCount)
 	pendingOrders := int(pendingOrderCount)
 
@@ -79,3 +85,210 @@ func GetStatsAdmin(c *gin.Context) {
 
 	c.JSON(http.StatusOK, stats)
 }
+
+const (
+	defaultSalesRangeDays = 30
+	defaultTopItems       = 10
+	maxTopItems           = 100
+	// maxSalesPeriods bounds the number of day, week or month buckets a sales report may span.
+	maxSalesPeriods = 400
+)
+
+type salesBucket struct {
+	Period        string       `json:"period"`
+	Revenue       money.Amount `json:"revenue"`
+	OrderCount    int64        `json:"order_count"`
+	AverageTicket money.Amount `json:"average_ticket"`
+}
+
+type salesItem struct {
+	ItemName string       `json:"item_name"`
+	Quantity int64        `json:"quantity"`
+	Revenue  money.Amount `json:"revenue"`
+}
+
+type salesClient struct {
+	ClientID   int          `json:"client_id"`
+	ClientName string       `json:"client_name"`
+	OrderCount int64        `json:"order_count"`
+	Revenue    money.Amount `json:"revenue"`
+}
+
+// GetSalesStatsAdmin reports revenue, order count and average ticket per day, week or month over
+// a date range, along with the best selling menu items and a per-client breakdown. Cancelled
+// orders are excluded and periods are computed in the business timezone.
+func GetSalesStatsAdmin(c *gin.Context) {
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	loc := middlewares.GetConfigFromContext(c).Business.Location
+	to := startOfDay(time.Now().In(loc)).AddDate(0, 0, 1)
+	if toStr := c.Query("to"); toStr != "" {
+		parsed, err := parseDateParam(toStr, true, loc)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid to date")
+			return
+		}
+		to = parsed
+	}
+	from := to.AddDate(0, 0, -defaultSalesRangeDays)
+	if fromStr := c.Query("from"); fromStr != "" {
+		parsed, err := parseDateParam(fromStr, false, loc)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid from date")
+			return
+		}
+		from = parsed
+	}
+	if !from.Before(to) {
+		apierror.BadRequest(c, "from must be before to")
+		return
+	}
+
+	granularity := c.DefaultQuery("granularity", "day")
+	if granularity != "day" && granularity != "week" && granularity != "month" {
+		apierror.BadRequest(c, "granularity must be one of: day, week, month")
+		return
+	}
+	periods := 0
+	for period := truncateToPeriod(from.In(loc), granularity); period.Before(to) && periods <= maxSalesPeriods; period = nextPeriod(period, granularity) {
+		periods++
+	}
+	if periods > maxSalesPeriods {
+		apierror.BadRequest(c, "Date range spans more than "+strconv.Itoa(maxSalesPeriods)+" periods; use a shorter range or a coarser granularity")
+		return
+	}
+
+	top := defaultTopItems
+	if topStr := c.Query("top"); topStr != "" {
+		parsed, err := strconv.Atoi(topStr)
+		if err != nil || parsed < 1 || parsed > maxTopItems {
+			apierror.BadRequest(c, "top must be between 1 and "+strconv.Itoa(maxTopItems))
+			return
+		}
+		top = parsed
+	}
+
+	salesOrders := func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("orders.deleted_at IS NULL").
+			Where("orders.order_date >= ? AND orders.order_date < ?", from, to).
+			Where("orders.status <> ?", models.OrderStatusCancelled)
+	}
+
+	var bucketRows []salesBucket
+	if err := db.Table("orders").Scopes(salesOrders).
+		Select("to_char(date_trunc(?, orders.order_date AT TIME ZONE ?), 'YYYY-MM-DD') AS period, "+
+			"COALESCE(SUM(orders.total_amount), 0)::bigint AS revenue, COUNT(*) AS order_count", granularity, loc.String()).
+		Group("period").
+		Scan(&bucketRows).Error; err != nil {
+		apierror.Internal(c, err, "Database error calculating sales")
+		return
+	}
+
+	bucketsByPeriod := make(map[string]salesBucket, len(bucketRows))
+	for _, bucket := range bucketRows {
+		bucketsByPeriod[bucket.Period] = bucket
+	}
+	buckets := []salesBucket{}
+	totals := salesBucket{Period: "total"}
+	for period := truncateToPeriod(from.In(loc), granularity); period.Before(to); period = nextPeriod(period, granularity) {
+		key := period.Format("2006-01-02")
+		bucket, ok := bucketsByPeriod[key]
+		if !ok {
+			bucket = salesBucket{Period: key}
+		}
+		if bucket.OrderCount > 0 {
+			bucket.AverageTicket = averageAmount(bucket.Revenue, bucket.OrderCount)
+		}
+		totals.Revenue += bucket.Revenue
+		totals.OrderCount += bucket.OrderCount
+		buckets = append(buckets, bucket)
+	}
+	if totals.OrderCount > 0 {
+		totals.AverageTicket = averageAmount(totals.Revenue, totals.OrderCount)
+	}
+
+	topItems := func(orderBy string) ([]salesItem, error) {
+		items := []salesItem{}
+		err := db.Table("order_items").
+			Joins("JOIN orders ON orders.id = order_items.order_id").
+			Scopes(salesOrders).
+			Where("order_items.deleted_at IS NULL").
+			Select("order_items.item_name AS item_name, SUM(order_items.quantity) AS quantity, " +
+				"COALESCE(SUM(order_items.subtotal), 0)::bigint AS revenue").
+			Group("order_items.item_name").
+			Order(orderBy + " DESC, item_name").
+			Limit(top).
+			Scan(&items).Error
+		return items, err
+	}
+	topByQuantity, err := topItems("quantity")
+	if err != nil {
+		apierror.Internal(c, err, "Database error calculating top items")
+		return
+	}
+	topByRevenue, err := topItems("revenue")
+	if err != nil {
+		apierror.Internal(c, err, "Database error calculating top items")
+		return
+	}
+
+	clients := []salesClient{}
+	if err := db.Table("orders").
+		Joins("JOIN clients ON clients.id = orders.client_id").
+		Scopes(salesOrders).
+		Select("orders.client_id AS client_id, clients.name AS client_name, COUNT(*) AS order_count, " +
+			"COALESCE(SUM(orders.total_amount), 0)::bigint AS revenue").
+		Group("orders.client_id, clients.name").
+		Order("revenue DESC, client_name").
+		Scan(&clients).Error; err != nil {
+		apierror.Internal(c, err, "Database error calculating sales by client")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":                  from,
+		"to":                    to,
+		"granularity":           granularity,
+		"timezone":              loc.String(),
+		"buckets":               buckets,
+		"totals":                totals,
+		"top_items_by_quantity": topByQuantity,
+		"top_items_by_revenue":  topByRevenue,
+		"clients":               clients,
+	})
+}
+
+// truncateToPeriod returns the start of the day, ISO week (starting on Monday, as Postgres'
+// date_trunc does) or month containing t.
+func truncateToPeriod(t time.Time, granularity string) time.Time {
+	day := startOfDay(t)
+	switch granularity {
+	case "week":
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case "month":
+		return day.AddDate(0, 0, 1-day.Day())
+	default:
+		return day
+	}
+}
+
+func nextPeriod(t time.Time, granularity string) time.Time {
+	switch granularity {
+	case "week":
+		return t.AddDate(0, 0, 7)
+	case "month":
+		return t.AddDate(0, 1, 0)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+// averageAmount divides a total by a count, rounding to the nearest minor unit.
+func averageAmount(total money.Amount, count int64) money.Amount {
+	return money.Amount(math.Round(float64(total) / float64(count)))
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"yom-kitchen/pkg/apierror"
+	"yom-kitchen/pkg/middlewares"
+	"yom-kitchen/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func GetAllTaxRatesAdmin(c *gin.Context) {
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	rates := []models.CategoryTaxRate{}
+	if err := db.Order("category").Find(&rates).Error; err != nil {
+		apierror.Internal(c, err, "Database error fetching tax rates")
+		return
+	}
+
+	business := middlewares.GetConfigFromContext(c).Business
+	c.JSON(http.StatusOK, gin.H{
+		"default_rate":           business.DefaultTaxRate,
+		"service_charge_percent": business.ServiceChargePercent,
+		"categories":             rates,
+	})
+}
+
+// SetCategoryTaxRateAdmin creates or replaces the tax rate of a menu category.
+func SetCategoryTaxRateAdmin(c *gin.Context) {
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	category := c.Param("category")
+	var rateRequest struct {
+		Rate *float64 `json:"rate" binding:"required,min=0,max=100"`
+	}
+	if err := c.ShouldBindJSON(&rateRequest); err != nil {
+		apierror.Bind(c, err)
+		return
+	}
+
+	var rate models.CategoryTaxRate
+	result := db.Unscoped().Where("category = ?", category).First(&rate)
+	if result.Error != nil && !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		apierror.Internal(c, result.Error, "Database error fetching tax rate")
+		return
+	}
+	action := models.AuditActionUpdate
+	var before any = rate
+	if result.Error != nil || rate.DeletedAt.Valid {
+		action = models.AuditActionCreate
+		before = nil
+	}
+	rate.Category = category
+	rate.Rate = *rateRequest.Rate
+	rate.DeletedAt = gorm.DeletedAt{}
+	if err := db.Unscoped().Save(&rate).Error; err != nil {
+		apierror.Internal(c, err, "Failed to save tax rate")
+		return
+	}
+
+	recordAudit(c, db, action, "tax_rate", category, before, rate)
+	c.JSON(http.StatusOK, gin.H{"message": "Tax rate saved successfully", "tax_rate": rate})
+}
+
+func DeleteCategoryTaxRateAdmin(c *gin.Context) {
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	category := c.Param("category")
+	var rate models.CategoryTaxRate
+	if err := db.Where("category = ?", category).First(&rate).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.NotFound(c, "Tax rate not found")
+		} else {
+			apierror.Internal(c, err, "Database error fetching tax rate")
+		}
+		return
+	}
+
+	if err := db.Delete(&rate).Error; err != nil {
+		apierror.Internal(c, err, "Failed to delete tax rate")
+		return
+	}
+
+	recordAudit(c, db, models.AuditActionDelete, "tax_rate", category, rate, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tax rate deleted successfully", "category": category})
+}
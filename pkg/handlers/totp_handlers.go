@@ -0,0 +1,580 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"yom-kitchen/pkg/apierror"
+	"yom-kitchen/pkg/bruteforce"
+	"yom-kitchen/pkg/middlewares"
+	"yom-kitchen/pkg/models"
+	"yom-kitchen/pkg/totp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const recoveryCodeCount = 10
+
+// respondWithMFAChallenge answers a login with a correct password when the user has to give a
+// TOTP code, or first enroll because a role requires it. The returned token is exchanged at
+// /login/totp, or at /login/totp/enroll while enrolling.
+func respondWithMFAChallenge(c *gin.Context, user models.User) {
+	cfg := middlewares.GetConfigFromContext(c)
+	expirationTime := time.Now().Add(cfg.Auth.MFATokenTTL)
+	claims := &jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(expirationTime),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		Issuer:    "samuelabebayehu",
+		Subject:   strconv.Itoa(int(user.ID)),
+		Audience:  jwt.ClaimStrings{middlewares.MFATokenAudience},
+	}
+	tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.Auth.JWTSecret))
+	if err != nil {
+		apierror.Internal(c, err, "Failed to generate JWT token")
+		return
+	}
+
+	message := "Two-factor authentication code required"
+	if !user.TOTPEnabled {
+		message = "Two-factor authentication enrollment required"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":                  message,
+		"mfa_required":             true,
+		"totp_enrollment_required": !user.TOTPEnabled,
+		"mfa_token":                tokenString,
+		"mfa_token_expires_at":     expirationTime,
+		"username":                 user.Username,
+	})
+}
+
+// LoginTOTP completes a login with the token from the password step and a TOTP code or an unused
+// recovery code.
+func LoginTOTP(c *gin.Context) {
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	var request struct {
+		MFAToken     string `json:"mfa_token" binding:"required"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Bind(c, err)
+		return
+	}
+	if (request.Code == "") == (request.RecoveryCode == "") {
+		apierror.BadRequest(c, "Either code or recovery_code is required")
+		return
+	}
+
+	user, ok := mfaTokenUser(c, db, request.MFAToken)
+	if !ok {
+		return
+	}
+	if !user.TOTPEnabled {
+		apierror.Conflict(c, "Two-factor authentication is not enabled, enroll first")
+		return
+	}
+
+	accountKey := bruteforce.UserKey(user.Username)
+	if !loginAllowed(c, accountKey, bruteforce.IPKey(c.ClientIP())) {
+		return
+	}
+
+	var valid bool
+	var err error
+	if request.Code != "" {
+		valid, err = verifyTOTPCode(db, user, request.Code)
+	} else {
+		valid, err = useRecoveryCode(db, user, request.RecoveryCode)
+	}
+	if err != nil {
+		apierror.Internal(c, err, "Database error during login")
+		return
+	}
+	if !valid {
+		loginFailed(c, accountKey, bruteforce.IPKey(c.ClientIP()))
+		apierror.Unauthorized(c, "Invalid two-factor authentication code")
+		return
+	}
+	loginSucceeded(c, accountKey)
+
+	session, refreshToken, ok := createSession(c, db, user)
+	if !ok {
+		return
+	}
+	respondWithTokens(c, user, session, refreshToken, "Login successful")
+}
+
+// BeginLoginTOTPEnrollment starts TOTP enrollment during login, for users whose role requires it.
+func BeginLoginTOTPEnrollment(c *gin.Context) {
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	var request struct {
+		MFAToken string `json:"mfa_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Bind(c, err)
+		return
+	}
+
+	user, ok := mfaTokenUser(c, db, request.MFAToken)
+	if !ok {
+		return
+	}
+	beginTOTPEnrollment(c, db, user)
+}
+
+// ConfirmLoginTOTPEnrollment enables TOTP with a code from the authenticator and completes the
+// login, returning the recovery codes with the tokens.
+func ConfirmLoginTOTPEnrollment(c *gin.Context) {
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	var request struct {
+		MFAToken string `json:"mfa_token" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Bind(c, err)
+		return
+	}
+
+	user, ok := mfaTokenUser(c, db, request.MFAToken)
+	if !ok {
+		return
+	}
+
+	accountKey := bruteforce.UserKey(user.Username)
+	if !loginAllowed(c, accountKey, bruteforce.IPKey(c.ClientIP())) {
+		return
+	}
+	recoveryCodes, ok := confirmTOTPEnrollment(c, db, &user, request.Code)
+	if !ok {
+		return
+	}
+	loginSucceeded(c, accountKey)
+
+	session, refreshToken, ok := createSession(c, db, user)
+	if !ok {
+		return
+	}
+	response, err := tokenResponse(middlewares.GetConfigFromContext(c), user, session, refreshToken, "Two-factor authentication enabled, login successful")
+	if err != nil {
+		apierror.Internal(c, err, "Failed to generate JWT token")
+		return
+	}
+	response["recovery_codes"] = recoveryCodes
+	c.JSON(http.StatusOK, response)
+}
+
+// BeginTOTPEnrollment starts TOTP enrollment for the logged in user. Enrollment only takes effect
+// once a code is confirmed with ConfirmTOTPEnrollment.
+func BeginTOTPEnrollment(c *gin.Context) {
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	user := middlewares.GetUserFromContext(c)
+	if user == nil {
+		apierror.Unauthorized(c, "Authentication required")
+		return
+	}
+	beginTOTPEnrollment(c, db, *user)
+}
+
+func ConfirmTOTPEnrollment(c *gin.Context) {
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	user := middlewares.GetUserFromContext(c)
+	if user == nil {
+		apierror.Unauthorized(c, "Authentication required")
+		return
+	}
+
+	var request struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Bind(c, err)
+		return
+	}
+
+	recoveryCodes, ok := confirmTOTPEnrollment(c, db, user, request.Code)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication enabled", "recovery_codes": recoveryCodes})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the logged in user, which needs a
+// current TOTP code.
+func RegenerateRecoveryCodes(c *gin.Context) {
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	user := middlewares.GetUserFromContext(c)
+	if user == nil {
+		apierror.Unauthorized(c, "Authentication required")
+		return
+	}
+	if !user.TOTPEnabled {
+		apierror.Conflict(c, "Two-factor authentication is not enabled")
+		return
+	}
+
+	var request struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Bind(c, err)
+		return
+	}
+
+	valid, err := verifyTOTPCode(db, *user, request.Code)
+	if err != nil {
+		apierror.Internal(c, err, "Database error verifying code")
+		return
+	}
+	if !valid {
+		apierror.Unauthorized(c, "Invalid two-factor authentication code")
+		return
+	}
+
+	var recoveryCodes []string
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		recoveryCodes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	}); err != nil {
+		apierror.Internal(c, err, "Failed to generate recovery codes")
+		return
+	}
+	recordAudit(c, db, models.AuditActionUpdate, "user", user.ID,
+		map[string]any{"recovery_codes": "previous"}, map[string]any{"recovery_codes": "regenerated"})
+	c.JSON(http.StatusOK, gin.H{"message": "Recovery codes regenerated", "recovery_codes": recoveryCodes})
+}
+
+// DisableTOTP turns off two-factor authentication for the logged in user, which needs the
+// password and a TOTP or recovery code. It is refused while one of the user's roles requires it.
+func DisableTOTP(c *gin.Context) {
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	user := middlewares.GetUserFromContext(c)
+	if user == nil {
+		apierror.Unauthorized(c, "Authentication required")
+		return
+	}
+	if !user.TOTPEnabled {
+		apierror.Conflict(c, "Two-factor authentication is not enabled")
+		return
+	}
+
+	var request struct {
+		Password     string `json:"password" binding:"required"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Bind(c, err)
+		return
+	}
+	if (request.Code == "") == (request.RecoveryCode == "") {
+		apierror.BadRequest(c, "Either code or recovery_code is required")
+		return
+	}
+
+	required, err := totpRequired(db, *user)
+	if err != nil {
+		apierror.Internal(c, err, "Database error fetching user roles")
+		return
+	}
+	if required {
+		apierror.Conflict(c, "Two-factor authentication is required by your role")
+		return
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(request.Password)) != nil {
+		apierror.Unauthorized(c, "Invalid password")
+		return
+	}
+	var valid bool
+	if request.Code != "" {
+		valid, err = verifyTOTPCode(db, *user, request.Code)
+	} else {
+		valid, err = useRecoveryCode(db, *user, request.RecoveryCode)
+	}
+	if err != nil {
+		apierror.Internal(c, err, "Database error verifying code")
+		return
+	}
+	if !valid {
+		apierror.Unauthorized(c, "Invalid two-factor authentication code")
+		return
+	}
+
+	if err := clearTOTP(db, user.ID); err != nil {
+		apierror.Internal(c, err, "Failed to disable two-factor authentication")
+		return
+	}
+	recordAudit(c, db, models.AuditActionUpdate, "user", user.ID,
+		map[string]any{"totp_enabled": true}, map[string]any{"totp_enabled": false})
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// ResetUserTOTPAdmin removes the TOTP enrollment of a user who lost their authenticator and
+// recovery codes. If a role requires TOTP, the user enrolls again at the next login.
+func ResetUserTOTPAdmin(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.BadRequest(c, "Invalid user ID format")
+		return
+	}
+
+	db := middlewares.GetDBFromContext(c)
+	if db == nil {
+		apierror.Internal(c, nil, "Database connection not available")
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.NotFound(c, "User not found")
+		} else {
+			apierror.Internal(c, err, "Database error fetching user")
+		}
+		return
+	}
+
+	if err := clearTOTP(db, user.ID); err != nil {
+		apierror.Internal(c, err, "Failed to reset two-factor authentication")
+		return
+	}
+	recordAudit(c, db, models.AuditActionUpdate, "user", user.ID,
+		map[string]any{"totp_enabled": user.TOTPEnabled}, map[string]any{"totp_enabled": false})
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset successfully", "user_id": user.ID})
+}
+
+// mfaTokenUser loads the user of a token from respondWithMFAChallenge. The user is put in the
+// request context, so that audit entries name them.
+func mfaTokenUser(c *gin.Context, db *gorm.DB, tokenString string) (models.User, bool) {
+	var user models.User
+	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("invalid signing method")
+		}
+		return []byte(middlewares.GetConfigFromContext(c).Auth.JWTSecret), nil
+	}, jwt.WithAudience(middlewares.MFATokenAudience))
+	if err != nil || !token.Valid {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid or expired MFA token, log in again")
+		return user, false
+	}
+	claims, ok := token.Claims.(*jwt.RegisteredClaims)
+	if !ok {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid or expired MFA token, log in again")
+		return user, false
+	}
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid or expired MFA token, log in again")
+		return user, false
+	}
+
+	if err := db.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Respond(c, http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid or expired MFA token, log in again")
+		} else {
+			apierror.Internal(c, err, "Database error fetching user")
+		}
+		return user, false
+	}
+
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), middlewares.UserContextKey, &user))
+	return user, true
+}
+
+// beginTOTPEnrollment gives the user a new TOTP secret and responds with it and its provisioning
+// URI, which the client shows as a QR code. A user who already has TOTP enabled has to disable it
+// first, so that a stolen password cannot replace the authenticator.
+func beginTOTPEnrollment(c *gin.Context, db *gorm.DB, user models.User) {
+	if user.TOTPEnabled {
+		apierror.Conflict(c, "Two-factor authentication is already enabled")
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		apierror.Internal(c, err, "Failed to generate TOTP secret")
+		return
+	}
+	if err := db.Model(&models.User{}).Where("id = ?", user.ID).UpdateColumns(map[string]interface{}{
+		"totp_secret":    secret,
+		"totp_last_step": 0,
+	}).Error; err != nil {
+		apierror.Internal(c, err, "Failed to start enrollment")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Scan the provisioning URI with an authenticator app and confirm a code",
+		"secret":           secret,
+		"provisioning_uri": totp.ProvisioningURI(middlewares.GetConfigFromContext(c).Auth.TOTPIssuer, user.Username, secret),
+	})
+}
+
+// confirmTOTPEnrollment enables TOTP for the user once a code from the new secret is verified,
+// returning the new recovery codes. Wrong codes count as failed logins.
+func confirmTOTPEnrollment(c *gin.Context, db *gorm.DB, user *models.User, code string) ([]string, bool) {
+	if user.TOTPEnabled {
+		apierror.Conflict(c, "Two-factor authentication is already enabled")
+		return nil, false
+	}
+	if user.TOTPSecret == "" {
+		apierror.Conflict(c, "Two-factor authentication enrollment has not been started")
+		return nil, false
+	}
+
+	valid, err := verifyTOTPCode(db, *user, code)
+	if err != nil {
+		apierror.Internal(c, err, "Database error verifying code")
+		return nil, false
+	}
+	if !valid {
+		loginFailed(c, bruteforce.UserKey(user.Username), bruteforce.IPKey(c.ClientIP()))
+		apierror.Unauthorized(c, "Invalid two-factor authentication code")
+		return nil, false
+	}
+
+	var recoveryCodes []string
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).UpdateColumn("totp_enabled", true).Error; err != nil {
+			return err
+		}
+		recoveryCodes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	}); err != nil {
+		apierror.Internal(c, err, "Failed to enable two-factor authentication")
+		return nil, false
+	}
+	user.TOTPEnabled = true
+
+	recordAudit(c, db, models.AuditActionUpdate, "user", user.ID,
+		map[string]any{"totp_enabled": false}, map[string]any{"totp_enabled": true})
+	return recoveryCodes, true
+}
+
+// verifyTOTPCode checks a code against the user's secret. The step of the code is stored, so the
+// same code cannot be used again, also not by a concurrent request.
+func verifyTOTPCode(db *gorm.DB, user models.User, code string) (bool, error) {
+	if user.TOTPSecret == "" {
+		return false, nil
+	}
+	step, ok := totp.Validate(user.TOTPSecret, code, time.Now(), user.TOTPLastStep)
+	if !ok {
+		return false, nil
+	}
+	result := db.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", user.ID, step).
+		UpdateColumn("totp_last_step", step)
+	return result.RowsAffected == 1, result.Error
+}
+
+// useRecoveryCode marks an unused recovery code of the user as used.
+func useRecoveryCode(db *gorm.DB, user models.User, code string) (bool, error) {
+	result := db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashToken(normalizeRecoveryCode(code))).
+		UpdateColumn("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+// replaceRecoveryCodes deletes the recovery codes of the user and returns new ones.
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]models.RecoveryCode, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		records = append(records, models.RecoveryCode{UserID: userID, CodeHash: hashToken(normalizeRecoveryCode(code))})
+	}
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// clearTOTP turns off TOTP for the user and removes the secret and recovery codes.
+func clearTOTP(db *gorm.DB, userID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).UpdateColumns(map[string]interface{}{
+			"totp_enabled":   false,
+			"totp_secret":    "",
+			"totp_last_step": 0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// totpRequired reports whether one of the user's roles requires TOTP.
+func totpRequired(db *gorm.DB, user models.User) (bool, error) {
+	var count int64
+	err := db.Table("roles").
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ? AND roles.require_totp AND roles.deleted_at IS NULL", user.ID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// newRecoveryCode returns a code like 3f9a-07c2-be41.
+func newRecoveryCode() (string, error) {
+	codeBytes := make([]byte, 6)
+	if _, err := rand.Read(codeBytes); err != nil {
+		return "", err
+	}
+	code := hex.EncodeToString(codeBytes)
+	return code[0:4] + "-" + code[4:8] + "-" + code[8:12], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
@@ -6,19 +6,44 @@ import (
 	"strconv"
 	"time"
 
+	"yom-kitchen/pkg/apierror"
+	"yom-kitchen/pkg/bruteforce"
 	"yom-kitchen/pkg/middlewares"
 	"yom-kitchen/pkg/models"
+	"yom-kitchen/pkg/services"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
+type userResponse struct {
+	ID                 uint      `json:"id"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+	Username           string    `json:"username"`
+	IsAdmin            bool      `json:"is_admin"`
+	TOTPEnabled        bool      `json:"totp_enabled"`
+	MustChangePassword bool      `json:"must_change_password"`
+	Roles              []string  `json:"roles"`
+}
+
+func newUserResponse(user models.User) userResponse {
+	return userResponse{
+		ID:                 user.ID,
+		CreatedAt:          user.CreatedAt,
+		UpdatedAt:          user.UpdatedAt,
+		Username:           user.Username,
+		IsAdmin:            user.IsAdmin,
+		TOTPEnabled:        user.TOTPEnabled,
+		MustChangePassword: user.MustChangePassword,
+		Roles:              roleNames(user.Roles),
+	}
+}
+
 func CreateUserAdmin(c *gin.Context) {
-	db := middlewares.GetDBFromContext(c)
-	if db == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Database connection not available"})
+	svc := middlewares.GetServicesFromContext(c)
+	if svc == nil {
+		apierror.Internal(c, nil, "Services not available")
 		return
 	}
 
@@ -26,44 +51,22 @@ func CreateUserAdmin(c *gin.Context) {
 		Username string `json:"username" binding:"required"`
 		Password string `json:"password" binding:"required"`
 		IsAdmin  bool   `json:"is_admin"`
+		RoleIDs  []uint `json:"role_ids"`
 	}
 
 	if err := c.ShouldBindJSON(&userRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": "Invalid request body: " + err.Error()})
+		apierror.Bind(c, err)
 		return
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(userRequest.Password), bcrypt.DefaultCost)
+	newUser, err := svc.Users.Create(c.Request.Context(), actorFromContext(c), services.NewUser{
+		Username: userRequest.Username,
+		Password: userRequest.Password,
+		IsAdmin:  userRequest.IsAdmin,
+		RoleIDs:  userRequest.RoleIDs,
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Failed to hash password: " + err.Error()})
-		return
-	}
-
-	var existingUser models.User
-	result := db.Unscoped().Where("username = ?", userRequest.Username).First(&existingUser)
-	if result.Error == nil {
-		c.JSON(http.StatusConflict, gin.H{
-			"message": "Username already exists"})
-		return
-	}
-	if !errors.Is(result.Error, gorm.ErrRecordNotFound) {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Database error checking username: " + result.Error.Error()})
-		return
-	}
-
-	newUser := models.User{
-		Username:     userRequest.Username,
-		PasswordHash: string(hashedPassword),
-		IsAdmin:      userRequest.IsAdmin,
-	}
-
-	createResult := db.Create(&newUser)
-	if createResult.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Failed to create user: " + createResult.Error.Error()})
+		respondServiceError(c, err, "Failed to create user")
 		return
 	}
 
@@ -71,113 +74,64 @@ func CreateUserAdmin(c *gin.Context) {
 }
 
 func GetUserAdmin(c *gin.Context) {
-	userIDStr := c.Param("id")
-	userID, err := strconv.Atoi(userIDStr)
+	userID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": "Invalid user ID format"})
+		apierror.BadRequest(c, "Invalid user ID format")
 		return
 	}
 
-	db := middlewares.GetDBFromContext(c)
-	if db == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Database connection not available"})
+	svc := middlewares.GetServicesFromContext(c)
+	if svc == nil {
+		apierror.Internal(c, nil, "Services not available")
 		return
 	}
 
-	var user models.User
-	result := db.First(&user, userID)
-	if result.Error != nil {
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": "User not found"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"message": "Database error fetching user: " + result.Error.Error()})
-		}
+	user, err := svc.Users.Get(c.Request.Context(), uint(userID))
+	if err != nil {
+		respondServiceError(c, err, "Database error fetching user")
 		return
 	}
 
-	userResponse := struct {
-		ID        uint      `json:"id"`
-		CreatedAt time.Time `json:"created_at"`
-		UpdatedAt time.Time `json:"updated_at"`
-		Username  string    `json:"username"`
-		IsAdmin   bool      `json:"is_admin"`
-	}{
-		ID:        user.ID,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
-		Username:  user.Username,
-		IsAdmin:   user.IsAdmin,
-	}
-
-	c.JSON(http.StatusOK, userResponse)
+	c.JSON(http.StatusOK, newUserResponse(user))
 }
 
 func GetAllUsersAdmin(c *gin.Context) {
-	db := middlewares.GetDBFromContext(c)
-	if db == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Database connection not available"})
+	svc := middlewares.GetServicesFromContext(c)
+	if svc == nil {
+		apierror.Internal(c, nil, "Services not available")
 		return
 	}
 
-	var users []models.User
-	result := db.Find(&users)
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Database error fetching users: " + result.Error.Error()})
+	query, err := parseListQuery(c, []string{"id", "username", "created_at"}, "username")
+	if err != nil {
+		apierror.BadRequest(c, err.Error())
 		return
 	}
 
-	var usersResponse []interface{}
+	users, total, err := svc.Users.List(c.Request.Context(), query.page())
+	if err != nil {
+		apierror.Internal(c, err, "Database error fetching users")
+		return
+	}
+
+	usersResponse := make([]userResponse, 0, len(users))
 	for _, user := range users {
-		usersResponse = append(usersResponse, struct {
-			ID        uint      `json:"id"`
-			CreatedAt time.Time `json:"created_at"`
-			UpdatedAt time.Time `json:"updated_at"`
-			Username  string    `json:"username"`
-			IsAdmin   bool      `json:"is_admin"`
-		}{
-			ID:        user.ID,
-			CreatedAt: user.CreatedAt,
-			UpdatedAt: user.UpdatedAt,
-			Username:  user.Username,
-			IsAdmin:   user.IsAdmin,
-		})
+		usersResponse = append(usersResponse, newUserResponse(user))
 	}
 
-	c.JSON(http.StatusOK, usersResponse)
+	c.JSON(http.StatusOK, listResponse(c, query, total, usersResponse))
 }
 
 func UpdateUserAdmin(c *gin.Context) {
-	userIDStr := c.Param("id")
-	userID, err := strconv.Atoi(userIDStr)
+	userID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": "Invalid user ID format"})
+		apierror.BadRequest(c, "Invalid user ID format")
 		return
 	}
 
-	db := middlewares.GetDBFromContext(c)
-	if db == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Database connection not available"})
-		return
-	}
-
-	var user models.User
-	result := db.First(&user, userID)
-	if result.Error != nil {
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": "User not found"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"message": "Database error fetching user: " + result.Error.Error()})
-		}
+	svc := middlewares.GetServicesFromContext(c)
+	if svc == nil {
+		apierror.Internal(c, nil, "Services not available")
 		return
 	}
 
@@ -188,115 +142,79 @@ func UpdateUserAdmin(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&userRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": "Invalid request body: " + err.Error(),
-		})
+		apierror.Bind(c, err)
 		return
 	}
 
-	updates := make(map[string]interface{})
-	// TODO implement username already in use
-	if userRequest.Username != nil {
-		var existingUser models.User
-		usernameCheckResult := db.Where("username = ? AND id != ?", *userRequest.Username, userID).First(&existingUser)
-		if usernameCheckResult.RowsAffected > 0 {
-			c.JSON(http.StatusConflict, gin.H{
-				"message": "Username already exists",
-			})
-			return
-		}
-		if !errors.Is(usernameCheckResult.Error, gorm.ErrRecordNotFound) {
+	// A new password signs the user out everywhere except from the session making the change.
+	var currentSessionID uint
+	if session := middlewares.GetSessionFromContext(c); session != nil && session.UserID == uint(userID) {
+		currentSessionID = session.ID
+	}
 
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"message": "Database error checking username: " + usernameCheckResult.Error.Error()})
-			return
-		}
-		updates["username"] = *userRequest.Username
+	updatedUser, err := svc.Users.Update(c.Request.Context(), actorFromContext(c), uint(userID), services.UserChanges{
+		Username: userRequest.Username,
+		Password: userRequest.Password,
+		IsAdmin:  userRequest.IsAdmin,
+	}, currentSessionID)
+	if err != nil {
+		respondServiceError(c, err, "Failed to update user")
+		return
 	}
 
-	if userRequest.Password != nil {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(*userRequest.Password), bcrypt.DefaultCost)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"message": "Failed to hash new password: " + err.Error()})
+	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully", "user": newUserResponse(updatedUser)})
+}
 
-			return
-		}
-		updates["password_hash"] = string(hashedPassword)
+func DeleteUserAdmin(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.BadRequest(c, "Invalid user ID format")
+		return
 	}
 
-	if userRequest.IsAdmin != nil {
-		updates["is_admin"] = *userRequest.IsAdmin
+	svc := middlewares.GetServicesFromContext(c)
+	if svc == nil {
+		apierror.Internal(c, nil, "Services not available")
+		return
 	}
 
-	if len(updates) > 0 {
-		updateResult := db.Model(&user).Updates(updates)
-		if updateResult.Error != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"message": "Failed to update user: " + updateResult.Error.Error()})
-			return
-		}
-		if updateResult.RowsAffected == 0 {
-			c.JSON(http.StatusOK, gin.H{
-				"message": "User updated successfully (no changes applied)"})
-			return
-		}
+	if err := svc.Users.Delete(c.Request.Context(), actorFromContext(c), uint(userID)); err != nil {
+		respondServiceError(c, err, "Failed to delete user")
+		return
 	}
 
-	var updatedUser models.User
-	db.First(&updatedUser, userID)
-
-	userResponse := struct {
-		ID        uint      `json:"id"`
-		CreatedAt time.Time `json:"created_at"`
-		UpdatedAt time.Time `json:"updated_at"`
-		Username  string    `json:"username"`
-		IsAdmin   bool      `json:"is_admin"`
-	}{
-		ID:        updatedUser.ID,
-		CreatedAt: updatedUser.CreatedAt,
-		UpdatedAt: updatedUser.UpdatedAt,
-		Username:  updatedUser.Username,
-		IsAdmin:   updatedUser.IsAdmin,
-	}
-	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully", "user": userResponse})
+	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully", "user_id": userID})
 }
 
-func DeleteUserAdmin(c *gin.Context) {
-	userIDStr := c.Param("id")
-	userID, err := strconv.Atoi(userIDStr)
+// UnlockUserLoginAdmin lifts a user's login lockout and forgets their failed logins.
+func UnlockUserLoginAdmin(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": "Invalid user ID format"})
+		apierror.BadRequest(c, "Invalid user ID format")
 		return
 	}
 
 	db := middlewares.GetDBFromContext(c)
 	if db == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Database connection not available"})
+		apierror.Internal(c, nil, "Database connection not available")
 		return
 	}
 
 	var user models.User
-	result := db.Unscoped().First(&user, userID)
-	if result.Error != nil {
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": "User not found"})
+	if err := db.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.NotFound(c, "User not found")
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"message": "Database error fetching user: " + result.Error.Error()})
+			apierror.Internal(c, err, "Database error fetching user")
 		}
 		return
 	}
 
-	deleteResult := db.Unscoped().Delete(&user)
-	if deleteResult.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Failed to delete user: " + deleteResult.Error.Error()})
+	failures, ok := unlockLogin(c, bruteforce.UserKey(user.Username))
+	if !ok {
 		return
 	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully", "user_id": userID})
+	recordAudit(c, db, models.AuditActionUpdate, "user", user.ID,
+		map[string]any{"failed_logins": failures}, map[string]any{"failed_logins": 0})
+	c.JSON(http.StatusOK, gin.H{"message": "User login unlocked successfully", "user_id": user.ID, "failed_logins": failures})
 }
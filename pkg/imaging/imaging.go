@@ -0,0 +1,148 @@
+// Package imaging turns uploaded photos into the resized variants served to clients. The format is
+// sniffed from the bytes, and every variant is re-encoded from the decoded pixels, so metadata such
+// as EXIF location data is never copied over. The EXIF orientation of JPEG photos is applied to the
+// pixels first so that the variants display the right way up.
+//
+// Variants are encoded as JPEG, or as PNG when the image has transparency. WebP uploads are
+// accepted, but neither the standard library nor golang.org/x/image can encode WebP.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format, allowed formats: jpeg, png, gif, webp")
+	ErrTooManyPixels     = errors.New("image dimensions are too large")
+)
+
+// MaxPixels bounds the width times height of an upload, since a small compressed file can decode
+// to a huge image.
+const MaxPixels = 40_000_000
+
+const jpegQuality = 85
+
+// Variant is a size images are resized to, fitting within MaxSize by MaxSize pixels. Images
+// smaller than that are not enlarged.
+type Variant struct {
+	Name    string
+	MaxSize int
+}
+
+// Variants are the sizes generated for each upload, from smallest to largest.
+var Variants = []Variant{
+	{Name: "thumbnail", MaxSize: 200},
+	{Name: "medium", MaxSize: 640},
+	{Name: "large", MaxSize: 1600},
+}
+
+// Image is an encoded variant.
+type Image struct {
+	Variant     string
+	Data        []byte
+	ContentType string
+	Extension   string
+	Width       int
+	Height      int
+}
+
+var decodableTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// Process decodes the image read from r and returns one encoded image for each of Variants. Only
+// the first frame of an animated GIF is kept.
+func Process(r io.Reader) ([]Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	contentType := http.DetectContentType(data)
+	if !decodableTypes[contentType] {
+		return nil, ErrUnsupportedFormat
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width <= 0 || config.Height <= 0 || int64(config.Width)*int64(config.Height) > MaxPixels {
+		return nil, ErrTooManyPixels
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	orientation := 1
+	if contentType == "image/jpeg" {
+		orientation = jpegOrientation(data)
+	}
+
+	isOpaque := opaque(src)
+	images := make([]Image, 0, len(Variants))
+	for _, variant := range Variants {
+		resized := orient(resize(src, variant.MaxSize), orientation)
+		encoded, err := encode(resized, isOpaque)
+		if err != nil {
+			return nil, err
+		}
+		encoded.Variant = variant.Name
+		images = append(images, encoded)
+	}
+	return images, nil
+}
+
+// resize scales img to fit within maxSize by maxSize pixels, keeping its aspect ratio. Fitting a
+// square keeps the result the same whichever way the image is later rotated.
+func resize(img image.Image, maxSize int) *image.NRGBA {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > maxSize || height > maxSize {
+		if width >= height {
+			height = max(1, height*maxSize/width)
+			width = maxSize
+		} else {
+			width = max(1, width*maxSize/height)
+			height = maxSize
+		}
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+func opaque(img image.Image) bool {
+	o, ok := img.(interface{ Opaque() bool })
+	return ok && o.Opaque()
+}
+
+func encode(img image.Image, opaque bool) (Image, error) {
+	var buf bytes.Buffer
+	encoded := Image{Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}
+	if opaque {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return Image{}, err
+		}
+		encoded.ContentType, encoded.Extension = "image/jpeg", ".jpg"
+	} else {
+		if err := png.Encode(&buf, img); err != nil {
+			return Image{}, err
+		}
+		encoded.ContentType, encoded.Extension = "image/png", ".png"
+	}
+	encoded.Data = buf.Bytes()
+	return encoded, nil
+}
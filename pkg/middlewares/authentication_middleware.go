@@ -5,8 +5,10 @@ import (
 	"errors"
 	"github.com/golang-jwt/jwt/v5"
 	"net/http"
-	"os"
+	"slices"
 	"strconv"
+	"time"
+	"yom-kitchen/pkg/apierror"
 	"yom-kitchen/pkg/models"
 
 	"github.com/gin-gonic/gin"
@@ -16,27 +18,33 @@ import (
 
 const UserContextKey = "user"
 
+const SessionContextKey = "session"
+
+// ClientTokenAudience is the audience of the tokens issued to clients, which only give access to
+// the /client routes.
+const ClientTokenAudience = "client"
+
+// MFATokenAudience is the audience of the short lived tokens issued after a correct password when
+// the user still has to give a TOTP code. They only work for the /login/totp routes.
+const MFATokenAudience = "mfa"
+
 func AuthenticationMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tokenString := c.GetHeader("Authorization")
 
 		if tokenString == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			apierror.Unauthorized(c, "Authentication required")
 			return
 		}
 
 		parts := strings.Split(tokenString, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token format"})
+			apierror.Respond(c, http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid token format")
 			return
 		}
 		tokenString = parts[1]
 
-		secretKey := []byte(os.Getenv("JWT_SECRET_KEY"))
-		if len(secretKey) == 0 {
-			secretKey = []byte("samuelabebayehu")
-			println("WARNING: JWT_SECRET_KEY environment variable not set. Using insecure default key!")
-		}
+		secretKey := []byte(GetConfigFromContext(c).Auth.JWTSecret)
 
 		token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
 			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -46,31 +54,59 @@ func AuthenticationMiddleware() gin.HandlerFunc {
 		})
 
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token: " + err.Error()})
+			apierror.InvalidToken(c, err)
 			return
 		}
 
 		claims, ok := token.Claims.(*jwt.RegisteredClaims)
 		if !ok || !token.Valid {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+			apierror.Respond(c, http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid token claims")
+			return
+		}
+
+		if slices.Contains(claims.Audience, ClientTokenAudience) {
+			apierror.Respond(c, http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid token - client tokens are not accepted")
+			return
+		}
+		if slices.Contains(claims.Audience, MFATokenAudience) {
+			apierror.Respond(c, http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid token - two-factor authentication is not complete")
 			return
 		}
 
 		userIDString, err := claims.GetSubject()
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID from token"})
+			apierror.Internal(c, err, "Failed to get user ID from token")
 			return
 		}
 
 		userID, err := strconv.Atoi(userIDString)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID in token"})
+			apierror.BadRequest(c, "Invalid user ID in token")
 			return
 		}
 
 		db := GetDBFromContext(c)
 		if db == nil {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+			apierror.Internal(c, nil, "Database connection error")
+			return
+		}
+
+		sessionID, err := strconv.Atoi(claims.ID)
+		if err != nil {
+			apierror.Respond(c, http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid token - no session")
+			return
+		}
+		var session models.Session
+		if err := db.First(&session, sessionID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				apierror.Respond(c, http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid token - Session not found")
+			} else {
+				apierror.Internal(c, err, "Database error during authentication")
+			}
+			return
+		}
+		if !session.IsActive(time.Now()) || session.UserID != uint(userID) {
+			apierror.Respond(c, http.StatusUnauthorized, apierror.CodeInvalidToken, "Session has been revoked")
 			return
 		}
 
@@ -78,36 +114,20 @@ func AuthenticationMiddleware() gin.HandlerFunc {
 		result := db.First(&user, userID)
 		if result.Error != nil {
 			if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token - User not found"})
+				apierror.Respond(c, http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid token - User not found")
 			} else {
-				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Database error during authentication"})
+				apierror.Internal(c, result.Error, "Database error during authentication")
 			}
 			return
 		}
 
 		ctx := context.WithValue(c.Request.Context(), UserContextKey, &user)
+		ctx = context.WithValue(ctx, SessionContextKey, &session)
 		c.Request = c.Request.WithContext(ctx)
 		c.Next()
 	}
 }
 
-func AdminAuthorizationMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		user, exists := c.Request.Context().Value(UserContextKey).(*models.User)
-		if !exists || user == nil {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Unauthorized - User information missing"})
-			return
-		}
-
-		if !user.IsAdmin {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden - Admin access required"})
-			return
-		}
-
-		c.Next()
-	}
-}
-
 func GetUserFromContext(c *gin.Context) *models.User {
 	user, ok := c.Request.Context().Value(UserContextKey).(*models.User)
 	if !ok || user == nil {
@@ -115,3 +135,11 @@ func GetUserFromContext(c *gin.Context) *models.User {
 	}
 	return user
 }
+
+func GetSessionFromContext(c *gin.Context) *models.Session {
+	session, ok := c.Request.Context().Value(SessionContextKey).(*models.Session)
+	if !ok || session == nil {
+		return nil
+	}
+	return session
+}
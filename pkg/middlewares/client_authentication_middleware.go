@@ -0,0 +1,101 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"yom-kitchen/pkg/apierror"
+	"yom-kitchen/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const ClientContextKey = "client"
+
+// ClientClaims are the claims of a client token. TokenVersion is the client's token version when
+// the token was issued; the token is rejected once the version has changed.
+type ClientClaims struct {
+	jwt.RegisteredClaims
+	TokenVersion int `json:"tv"`
+}
+
+// ClientAuthenticationMiddleware authenticates clients with the token issued by the client login.
+func ClientAuthenticationMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := c.GetHeader("Authorization")
+
+		if tokenString == "" {
+			apierror.Unauthorized(c, "Authentication required")
+			return
+		}
+
+		parts := strings.Split(tokenString, " ")
+		if len(parts) != 2 || parts[0] != "Bearer" {
+			apierror.Respond(c, http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid token format")
+			return
+		}
+
+		token, err := jwt.ParseWithClaims(parts[1], &ClientClaims{}, func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, errors.New("invalid signing method")
+			}
+			return []byte(GetConfigFromContext(c).Auth.JWTSecret), nil
+		}, jwt.WithAudience(ClientTokenAudience))
+		if err != nil {
+			apierror.InvalidToken(c, err)
+			return
+		}
+
+		claims, ok := token.Claims.(*ClientClaims)
+		if !ok || !token.Valid {
+			apierror.Respond(c, http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid token claims")
+			return
+		}
+
+		clientID, err := strconv.Atoi(claims.Subject)
+		if err != nil {
+			apierror.Respond(c, http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid client ID in token")
+			return
+		}
+
+		db := GetDBFromContext(c)
+		if db == nil {
+			apierror.Internal(c, nil, "Database connection error")
+			return
+		}
+
+		var client models.Client
+		if err := db.First(&client, clientID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				apierror.Respond(c, http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid token - Client not found")
+			} else {
+				apierror.Internal(c, err, "Database error during authentication")
+			}
+			return
+		}
+		if claims.TokenVersion != client.TokenVersion {
+			apierror.Respond(c, http.StatusUnauthorized, apierror.CodeInvalidToken, "Token has been revoked")
+			return
+		}
+		if !client.IsActive {
+			apierror.Forbidden(c, "Client account is inactive")
+			return
+		}
+
+		ctx := context.WithValue(c.Request.Context(), ClientContextKey, &client)
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+func GetClientFromContext(c *gin.Context) *models.Client {
+	client, ok := c.Request.Context().Value(ClientContextKey).(*models.Client)
+	if !ok || client == nil {
+		return nil
+	}
+	return client
+}
@@ -0,0 +1,27 @@
+package middlewares
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"yom-kitchen/pkg/config"
+)
+
+const ConfigContextKey = "config"
+
+func ConfigMiddleware(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := context.WithValue(c.Request.Context(), ConfigContextKey, cfg)
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// GetConfigFromContext returns the configuration given to ConfigMiddleware. Unlike the other
+// context values it is always there, so it panics when the middleware is missing.
+func GetConfigFromContext(c *gin.Context) *config.Config {
+	cfg, ok := c.Request.Context().Value(ConfigContextKey).(*config.Config)
+	if !ok || cfg == nil {
+		panic("middlewares: ConfigMiddleware is not installed")
+	}
+	return cfg
+}
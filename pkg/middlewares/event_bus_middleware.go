@@ -0,0 +1,25 @@
+package middlewares
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"yom-kitchen/pkg/events"
+)
+
+const EventBusContextKey = "eventBus"
+
+func EventBusMiddleware(bus *events.Bus) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := context.WithValue(c.Request.Context(), EventBusContextKey, bus)
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+func GetEventBusFromContext(c *gin.Context) *events.Bus {
+	bus, ok := c.Request.Context().Value(EventBusContextKey).(*events.Bus)
+	if !ok || bus == nil {
+		return nil
+	}
+	return bus
+}
@@ -0,0 +1,182 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+	"yom-kitchen/pkg/apierror"
+	"yom-kitchen/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader is set on responses replayed from an earlier request.
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+const maxIdempotencyKeyLength = 255
+
+// idempotencyLockMargin is added to the server write timeout to get how long a key stays claimed
+// by a request that never finished, such as one handled by an instance that crashed, before a
+// retry may take it over. A request still running by then has outlived its connection.
+const idempotencyLockMargin = time.Minute
+
+// Idempotency makes requests sent with an Idempotency-Key header safe to retry. The response to the
+// first request with a key is stored for the configured TTL and replayed for retries with the same
+// key and the same method, path and body; a different request with the key is rejected. While the
+// first request is being handled, retries get 409 Conflict. Server errors and panics release the
+// key, so that the request can be retried. If the response cannot be stored the key stays
+// claimed and retries keep getting 409 Conflict, as the request may have had its effect. It must
+// run after AuthenticationMiddleware or ClientAuthenticationMiddleware, as keys are scoped to the
+// user or client sending them.
+func Idempotency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			apierror.BadRequest(c, fmt.Sprintf("%s must be at most %d characters", IdempotencyKeyHeader, maxIdempotencyKeyLength))
+			return
+		}
+
+		var scope string
+		if user := GetUserFromContext(c); user != nil {
+			scope = fmt.Sprintf("user:%d", user.ID)
+		} else if client := GetClientFromContext(c); client != nil {
+			scope = fmt.Sprintf("client:%d", client.ID)
+		} else {
+			apierror.Forbidden(c, "Unauthorized - User information missing")
+			return
+		}
+
+		db := GetDBFromContext(c)
+		if db == nil {
+			apierror.Internal(c, nil, "Database connection error")
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			apierror.BadRequest(c, "Failed to read request body")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		hash := sha256.New()
+		fmt.Fprintf(hash, "%s %s\n", c.Request.Method, c.Request.URL.Path)
+		hash.Write(body)
+
+		now := time.Now()
+		cfg := GetConfigFromContext(c).HTTP
+		record := models.IdempotencyKey{
+			Scope:       scope,
+			Key:         key,
+			RequestHash: hex.EncodeToString(hash.Sum(nil)),
+			ExpiresAt:   now.Add(cfg.IdempotencyKeyTTL),
+		}
+		claimed, err := claimIdempotencyKey(db, &record, now, cfg.WriteTimeout+idempotencyLockMargin)
+		if err != nil {
+			apierror.Internal(c, err, "Database error checking idempotency key")
+			return
+		}
+		if !claimed {
+			replayIdempotentResponse(c, db, record)
+			return
+		}
+
+		// Release the key if the handler panics, so that the request can be retried.
+		returned := false
+		defer func() {
+			if !returned {
+				db.Delete(&record)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+		returned = true
+
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			db.Delete(&record)
+			return
+		}
+		err = db.Model(&record).Updates(map[string]interface{}{
+			"status_code":  status,
+			"content_type": recorder.Header().Get("Content-Type"),
+			"body":         recorder.body.Bytes(),
+		}).Error
+		if err != nil {
+			log.Printf("Failed to store the response for %s %s: %v", IdempotencyKeyHeader, key, err)
+		}
+	}
+}
+
+// claimIdempotencyKey stores the key as being handled, reporting false if another request already
+// holds it. A claim older than lockTimeout that has no response is taken over. Two requests racing
+// for a key cannot both claim it, as the insert of the second waits for the first and then does
+// nothing.
+func claimIdempotencyKey(db *gorm.DB, record *models.IdempotencyKey, now time.Time, lockTimeout time.Duration) (bool, error) {
+	err := db.Where("expires_at < ?", now).
+		Or("scope = ? AND key = ? AND status_code = 0 AND created_at < ?", record.Scope, record.Key, now.Add(-lockTimeout)).
+		Delete(&models.IdempotencyKey{}).Error
+	if err != nil {
+		return false, err
+	}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	return result.RowsAffected == 1, result.Error
+}
+
+func replayIdempotentResponse(c *gin.Context, db *gorm.DB, request models.IdempotencyKey) {
+	var existing models.IdempotencyKey
+	err := db.Where("scope = ? AND key = ?", request.Scope, request.Key).First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// The first request failed and released the key in the meantime.
+		c.Header("Retry-After", "1")
+		apierror.Respond(c, http.StatusConflict, apierror.CodeRequestInProgress, "A request with this "+IdempotencyKeyHeader+" is still being processed")
+		return
+	}
+	if err != nil {
+		apierror.Internal(c, err, "Database error checking idempotency key")
+		return
+	}
+	if existing.RequestHash != request.RequestHash {
+		apierror.Respond(c, http.StatusUnprocessableEntity, apierror.CodeIdempotencyKeyReused, IdempotencyKeyHeader+" was already used for a different request")
+		return
+	}
+	if existing.StatusCode == 0 {
+		c.Header("Retry-After", "1")
+		apierror.Respond(c, http.StatusConflict, apierror.CodeRequestInProgress, "A request with this "+IdempotencyKeyHeader+" is still being processed")
+		return
+	}
+	c.Header(IdempotentReplayedHeader, "true")
+	c.Data(existing.StatusCode, existing.ContentType, existing.Body)
+	c.Abort()
+}
+
+// responseRecorder keeps a copy of the response body written through it.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,26 @@
+package middlewares
+
+import (
+	"context"
+	"yom-kitchen/pkg/bruteforce"
+
+	"github.com/gin-gonic/gin"
+)
+
+const LoginLimiterContextKey = "loginLimiter"
+
+func LoginLimiterMiddleware(limiter *bruteforce.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := context.WithValue(c.Request.Context(), LoginLimiterContextKey, limiter)
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+func GetLoginLimiterFromContext(c *gin.Context) *bruteforce.Limiter {
+	limiter, ok := c.Request.Context().Value(LoginLimiterContextKey).(*bruteforce.Limiter)
+	if !ok || limiter == nil {
+		return nil
+	}
+	return limiter
+}
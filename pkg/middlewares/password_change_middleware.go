@@ -0,0 +1,25 @@
+package middlewares
+
+import (
+	"net/http"
+	"yom-kitchen/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequirePasswordChanged stops users who have to change their password from doing anything else
+// until they have, at POST /account/password. It must run after AuthenticationMiddleware.
+func RequirePasswordChanged() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := GetUserFromContext(c)
+		if user == nil {
+			apierror.Forbidden(c, "Unauthorized - User information missing")
+			return
+		}
+		if user.MustChangePassword {
+			apierror.Respond(c, http.StatusForbidden, apierror.CodePasswordChangeRequired, "Password change required")
+			return
+		}
+		c.Next()
+	}
+}
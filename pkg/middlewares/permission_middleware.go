@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"yom-kitchen/pkg/apierror"
+	"yom-kitchen/pkg/models"
+	"yom-kitchen/pkg/repository"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RequirePermission only lets through users whose roles grant the permission. It must run after
+// AuthenticationMiddleware.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := GetUserFromContext(c)
+		if user == nil {
+			apierror.Forbidden(c, "Unauthorized - User information missing")
+			return
+		}
+
+		db := GetDBFromContext(c)
+		if db == nil {
+			apierror.Internal(c, nil, "Database connection error")
+			return
+		}
+
+		allowed, err := HasPermission(db, user, permission)
+		if err != nil {
+			apierror.Internal(c, err, "Database error checking permissions")
+			return
+		}
+		if !allowed {
+			apierror.Forbidden(c, "Forbidden - "+permission+" permission required")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// HasPermission reports whether the user is an admin or has a role granting the permission.
+func HasPermission(db *gorm.DB, user *models.User, permission string) (bool, error) {
+	if user.IsAdmin {
+		return true, nil
+	}
+	permissions, err := UserPermissions(db, user.ID)
+	if err != nil {
+		return false, err
+	}
+	return models.GrantsPermission(permissions, permission), nil
+}
+
+// UserPermissions returns the permissions granted by the roles of a user.
+func UserPermissions(db *gorm.DB, userID uint) ([]string, error) {
+	return repository.NewPostgresUserRepository(db).Permissions(db.Statement.Context, userID)
+}
@@ -0,0 +1,31 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+	"yom-kitchen/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// validRequestID limits the request IDs accepted from clients and proxies, as they are written
+// to the logs.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// RequestIDMiddleware gives every request an ID, taken from the X-Request-ID header when the
+// caller sent a valid one, and returns it in the X-Request-ID response header, where
+// apierror finds it. It must run before anything that can respond with an error, so that the
+// error carries the ID.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(apierror.RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			id := make([]byte, 16)
+			rand.Read(id)
+			requestID = hex.EncodeToString(id)
+		}
+		c.Header(apierror.RequestIDHeader, requestID)
+		c.Next()
+	}
+}
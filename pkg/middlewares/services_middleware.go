@@ -0,0 +1,25 @@
+package middlewares
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"yom-kitchen/pkg/services"
+)
+
+const ServicesContextKey = "services"
+
+func ServicesMiddleware(svc *services.Services) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := context.WithValue(c.Request.Context(), ServicesContextKey, svc)
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+func GetServicesFromContext(c *gin.Context) *services.Services {
+	svc, ok := c.Request.Context().Value(ServicesContextKey).(*services.Services)
+	if !ok || svc == nil {
+		return nil
+	}
+	return svc
+}
@@ -0,0 +1,297 @@
+// Package migrate applies the versioned SQL migrations embedded in the binary and records them in
+// the schema_migrations table.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// lockKey is the key of the Postgres advisory lock held while migrating, so that instances starting
+// together apply each migration once.
+const lockKey int64 = 0x796f6d6b6974636e // "yomkitcn"
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is one schema change, read from the files NNNN_name.up.sql and NNNN_name.down.sql.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// State is a migration and when it was applied, or nil if it is pending.
+type State struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrator applies the embedded migrations to a database.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	adopt      func(ctx context.Context) error
+}
+
+// New returns a Migrator for db. adopt is called by Up on a database whose schema was created
+// before migrations existed: it has tables but no schema_migrations. It must bring the schema up
+// to the baseline, the first migration, which is then recorded as applied instead of being run.
+func New(db *sql.DB, adopt func(ctx context.Context) error) (*Migrator, error) {
+	migrations, err := load(files)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations, adopt: adopt}, nil
+}
+
+func load(fsys fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(fsys, "sql/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	for _, name := range names {
+		match := fileName.FindStringSubmatch(path.Base(name))
+		if match == nil {
+			return nil, fmt.Errorf("migration %s: name must be NNNN_name.up.sql or NNNN_name.down.sql", name)
+		}
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %s: version %d is also named %s", name, version, m.Name)
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %s: needs both an up and a down file", m)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Up applies all pending migrations in order and returns them. Each migration runs in its own
+// transaction together with its schema_migrations row.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		if err := m.adoptLegacySchema(ctx, conn); err != nil {
+			return err
+		}
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`,
+					migration.Version, migration.Name)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %s: %w", migration, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns them.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		if err := createTable(ctx, conn); err != nil {
+			return err
+		}
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range lastApplied(m.migrations, done, steps) {
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, migration.Down); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %s: %w", migration, err)
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// lastApplied returns the last steps of migrations that are in done, newest first.
+func lastApplied(migrations []Migration, done map[int64]time.Time, steps int) []Migration {
+	var last []Migration
+	for i := len(migrations) - 1; i >= 0 && len(last) < steps; i-- {
+		if _, ok := done[migrations[i].Version]; ok {
+			last = append(last, migrations[i])
+		}
+	}
+	return last
+}
+
+// Status returns every migration with when it was applied. It does not change the database.
+func (m *Migrator) Status(ctx context.Context) ([]State, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	exists, err := tableExists(ctx, conn, "schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	done := map[int64]time.Time{}
+	if exists {
+		if done, err = appliedVersions(ctx, conn); err != nil {
+			return nil, err
+		}
+	}
+
+	states := make([]State, len(m.migrations))
+	for i, migration := range m.migrations {
+		states[i].Migration = migration
+		if appliedAt, ok := done[migration.Version]; ok {
+			states[i].AppliedAt = &appliedAt
+		}
+	}
+	return states, nil
+}
+
+// adoptLegacySchema records the baseline as applied on a database created by AutoMigrate, after
+// running the adopt hook on it. It does nothing once schema_migrations exists.
+func (m *Migrator) adoptLegacySchema(ctx context.Context, conn *sql.Conn) error {
+	exists, err := tableExists(ctx, conn, "schema_migrations")
+	if err != nil || exists {
+		return err
+	}
+	legacy, err := tableExists(ctx, conn, "users")
+	if err != nil {
+		return err
+	}
+	if !legacy || len(m.migrations) == 0 {
+		return createTable(ctx, conn)
+	}
+
+	if m.adopt != nil {
+		if err := m.adopt(ctx); err != nil {
+			return fmt.Errorf("upgrading schema to the baseline: %w", err)
+		}
+	}
+	if err := createTable(ctx, conn); err != nil {
+		return err
+	}
+	baseline := m.migrations[0]
+	_, err = conn.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`,
+		baseline.Version, baseline.Name)
+	return err
+}
+
+// locked runs fn on a single connection holding the migration advisory lock, waiting for any
+// other instance holding it.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return fmt.Errorf("acquiring migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey)
+
+	return fn(conn)
+}
+
+func createTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint PRIMARY KEY,
+		name text NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`)
+	return err
+}
+
+func tableExists(ctx context.Context, conn *sql.Conn, table string) (bool, error) {
+	var exists bool
+	err := conn.QueryRowContext(ctx, `SELECT to_regclass($1) IS NOT NULL`, table).Scan(&exists)
+	return exists, err
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	done := map[int64]time.Time{}
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		done[version] = appliedAt
+	}
+	return done, rows.Err()
+}
+
+func inTx(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,93 @@
+package migrate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/0010_add_index.up.sql":     {Data: []byte("CREATE INDEX")},
+		"sql/0010_add_index.down.sql":   {Data: []byte("DROP INDEX")},
+		"sql/0002_add_column.up.sql":    {Data: []byte("ADD COLUMN")},
+		"sql/0002_add_column.down.sql":  {Data: []byte("DROP COLUMN")},
+		"sql/0001_baseline.up.sql":      {Data: []byte("CREATE TABLE")},
+		"sql/0001_baseline.down.sql":    {Data: []byte("DROP TABLE")},
+		"sql/notes.txt":                 {Data: []byte("not a migration")},
+		"other/0003_elsewhere.up.sql":   {Data: []byte("ignored")},
+		"other/0003_elsewhere.down.sql": {Data: []byte("ignored")},
+	}
+	migrations, err := load(fsys)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	want := []Migration{
+		{Version: 1, Name: "baseline", Up: "CREATE TABLE", Down: "DROP TABLE"},
+		{Version: 2, Name: "add_column", Up: "ADD COLUMN", Down: "DROP COLUMN"},
+		{Version: 10, Name: "add_index", Up: "CREATE INDEX", Down: "DROP INDEX"},
+	}
+	if !reflect.DeepEqual(migrations, want) {
+		t.Errorf("load = %+v, want %+v", migrations, want)
+	}
+}
+
+func TestLoadRejectsInvalidFiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  string
+	}{
+		{"missing down", []string{"0001_baseline.up.sql"}, "needs both an up and a down file"},
+		{"missing up", []string{"0001_baseline.down.sql"}, "needs both an up and a down file"},
+		{"bad name", []string{"baseline.up.sql"}, "name must be NNNN_name.up.sql"},
+		{"bad direction", []string{"0001_baseline.sideways.sql"}, "name must be NNNN_name.up.sql"},
+		{"version reused", []string{"0001_baseline.up.sql", "0001_baseline.down.sql", "0001_other.up.sql", "0001_other.down.sql"}, "version 1 is also named"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{}
+			for _, name := range tt.files {
+				fsys["sql/"+name] = &fstest.MapFile{Data: []byte("SELECT 1")}
+			}
+			if _, err := load(fsys); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("load error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadEmbedded(t *testing.T) {
+	migrations, err := load(files)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(migrations) == 0 || migrations[0].String() != "0001_baseline" {
+		t.Fatalf("embedded migrations = %v, want 0001_baseline first", migrations)
+	}
+}
+
+func TestLastApplied(t *testing.T) {
+	migrations := []Migration{{Version: 1}, {Version: 2}, {Version: 3}, {Version: 4}}
+	done := map[int64]time.Time{1: {}, 2: {}, 4: {}}
+	tests := []struct {
+		steps int
+		want  []int64
+	}{
+		{0, nil},
+		{1, []int64{4}},
+		{2, []int64{4, 2}},
+		{5, []int64{4, 2, 1}},
+	}
+	for _, tt := range tests {
+		var versions []int64
+		for _, m := range lastApplied(migrations, done, tt.steps) {
+			versions = append(versions, m.Version)
+		}
+		if !reflect.DeepEqual(versions, tt.want) {
+			t.Errorf("lastApplied(%d) = %v, want %v", tt.steps, versions, tt.want)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+DROP TRIGGER IF EXISTS audit_logs_append_only ON audit_logs;
+DROP FUNCTION IF EXISTS reject_audit_log_change();
+
+DROP TABLE IF EXISTS "stock_movements";
+DROP TABLE IF EXISTS "recipe_items";
+DROP TABLE IF EXISTS "ingredients";
+DROP TABLE IF EXISTS "invoice_sequences";
+DROP TABLE IF EXISTS "invoices";
+DROP TABLE IF EXISTS "order_status_events";
+DROP TABLE IF EXISTS "idempotency_keys";
+DROP TABLE IF EXISTS "promo_redemptions";
+DROP TABLE IF EXISTS "promo_categories";
+DROP TABLE IF EXISTS "promo_menu_items";
+DROP TABLE IF EXISTS "promos";
+DROP TABLE IF EXISTS "order_tax_lines";
+DROP TABLE IF EXISTS "order_items";
+DROP TABLE IF EXISTS "orders";
+DROP TABLE IF EXISTS "client_login_tokens";
+DROP TABLE IF EXISTS "clients";
+DROP TABLE IF EXISTS "category_tax_rates";
+DROP TABLE IF EXISTS "menu_items";
+DROP TABLE IF EXISTS "login_attempts";
+DROP TABLE IF EXISTS "audit_logs";
+DROP TABLE IF EXISTS "role_permissions";
+DROP TABLE IF EXISTS "sessions";
+DROP TABLE IF EXISTS "recovery_codes";
+DROP TABLE IF EXISTS "user_roles";
+DROP TABLE IF EXISTS "roles";
+DROP TABLE IF EXISTS "users";
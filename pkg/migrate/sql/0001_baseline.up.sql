@@ -0,0 +1,374 @@
+-- Baseline: the schema as created by GORM AutoMigrate before versioned migrations were introduced.
+
+CREATE TABLE "users" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "username" text NOT NULL,
+    "password_hash" text NOT NULL,
+    "is_admin" boolean DEFAULT false,
+    "must_change_password" boolean NOT NULL DEFAULT false,
+    "totp_secret" text,
+    "totp_enabled" boolean NOT NULL DEFAULT false,
+    "totp_last_step" bigint NOT NULL DEFAULT 0,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_users_username" UNIQUE ("username")
+);
+CREATE INDEX "idx_users_deleted_at" ON "users" ("deleted_at");
+
+CREATE TABLE "roles" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "name" text NOT NULL,
+    "description" text,
+    "is_system" boolean NOT NULL DEFAULT false,
+    "require_totp" boolean NOT NULL DEFAULT false,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_roles_name" UNIQUE ("name")
+);
+CREATE INDEX "idx_roles_deleted_at" ON "roles" ("deleted_at");
+
+CREATE TABLE "user_roles" (
+    "role_id" bigint,
+    "user_id" bigint,
+    PRIMARY KEY ("role_id","user_id"),
+    CONSTRAINT "fk_user_roles_role" FOREIGN KEY ("role_id") REFERENCES "roles"("id"),
+    CONSTRAINT "fk_user_roles_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+
+CREATE TABLE "recovery_codes" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "code_hash" text NOT NULL,
+    "used_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_users_recovery_codes" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX "idx_recovery_codes_code_hash" ON "recovery_codes" ("code_hash");
+CREATE INDEX "idx_recovery_codes_user_id" ON "recovery_codes" ("user_id");
+
+CREATE TABLE "sessions" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "refresh_token_hash" text NOT NULL,
+    "previous_refresh_token_hash" text,
+    "expires_at" timestamptz NOT NULL,
+    "last_used_at" timestamptz,
+    "revoked_at" timestamptz,
+    "user_agent" text,
+    "ip_address" text,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX "idx_sessions_refresh_token_hash" ON "sessions" ("refresh_token_hash");
+CREATE INDEX "idx_sessions_user_id" ON "sessions" ("user_id");
+CREATE INDEX "idx_sessions_previous_refresh_token_hash" ON "sessions" ("previous_refresh_token_hash");
+
+CREATE TABLE "role_permissions" (
+    "id" bigserial,
+    "role_id" bigint NOT NULL,
+    "permission" text NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_roles_permissions" FOREIGN KEY ("role_id") REFERENCES "roles"("id") ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX "idx_role_permissions_role_permission" ON "role_permissions" ("role_id","permission");
+
+CREATE TABLE "audit_logs" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "actor_id" bigint,
+    "actor_name" text,
+    "action" text NOT NULL,
+    "entity_type" text NOT NULL,
+    "entity_id" text NOT NULL,
+    "changes" jsonb,
+    "ip_address" text,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_audit_logs_actor_id" ON "audit_logs" ("actor_id");
+CREATE INDEX "idx_audit_logs_created_at" ON "audit_logs" ("created_at");
+CREATE INDEX "idx_audit_logs_entity" ON "audit_logs" ("entity_type","entity_id");
+
+CREATE TABLE "login_attempts" (
+    "key" text,
+    "failures" bigint NOT NULL DEFAULT 0,
+    "last_failure_at" timestamptz NOT NULL,
+    "locked_until" timestamptz,
+    PRIMARY KEY ("key")
+);
+
+CREATE TABLE "menu_items" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "name" text NOT NULL,
+    "desc" text,
+    "image_key" text NOT NULL DEFAULT '',
+    "image_variant_keys" jsonb,
+    "price" bigint NOT NULL,
+    "category" text,
+    "available" boolean DEFAULT true,
+    "auto_unavailable" boolean NOT NULL DEFAULT false,
+    "tax_rate" decimal(5,2),
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_menu_items_name" UNIQUE ("name")
+);
+CREATE INDEX "idx_menu_items_deleted_at" ON "menu_items" ("deleted_at");
+
+CREATE TABLE "category_tax_rates" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "category" text NOT NULL,
+    "rate" decimal(5,2) NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_category_tax_rates_category" UNIQUE ("category")
+);
+CREATE INDEX "idx_category_tax_rates_deleted_at" ON "category_tax_rates" ("deleted_at");
+
+CREATE TABLE "clients" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "name" text NOT NULL,
+    "passcode_hash" text NOT NULL,
+    "email" text,
+    "phone" text,
+    "address" text,
+    "is_active" boolean,
+    "is_admin" boolean,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_clients_email" UNIQUE ("email")
+);
+CREATE INDEX "idx_clients_deleted_at" ON "clients" ("deleted_at");
+
+CREATE TABLE "client_login_tokens" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "client_id" bigint NOT NULL,
+    "token_hash" text NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "used_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_client_login_tokens_client_id" ON "client_login_tokens" ("client_id");
+CREATE UNIQUE INDEX "idx_client_login_tokens_token_hash" ON "client_login_tokens" ("token_hash");
+
+CREATE TABLE "orders" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "client_id" bigint NOT NULL,
+    "order_date" timestamptz NOT NULL DEFAULT now(),
+    "subtotal_amount" bigint NOT NULL DEFAULT 0,
+    "discount_amount" bigint NOT NULL DEFAULT 0,
+    "net_amount" bigint NOT NULL DEFAULT 0,
+    "service_charge_amount" bigint NOT NULL DEFAULT 0,
+    "tax_amount" bigint NOT NULL DEFAULT 0,
+    "total_amount" bigint NOT NULL,
+    "promo_code" text,
+    "status" text DEFAULT 'Pending',
+    "notes" text,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_orders_client" FOREIGN KEY ("client_id") REFERENCES "clients"("id")
+);
+CREATE INDEX "idx_orders_deleted_at" ON "orders" ("deleted_at");
+
+CREATE TABLE "order_items" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "order_id" bigint NOT NULL,
+    "menu_item_id" bigint NOT NULL,
+    "item_name" text NOT NULL,
+    "item_price" bigint NOT NULL,
+    "quantity" bigint NOT NULL DEFAULT 1,
+    "subtotal" bigint NOT NULL,
+    "discount_amount" bigint NOT NULL DEFAULT 0,
+    "tax_rate" decimal(5,2) NOT NULL DEFAULT 0,
+    "tax_amount" bigint NOT NULL DEFAULT 0,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_orders_order_items" FOREIGN KEY ("order_id") REFERENCES "orders"("id") ON DELETE CASCADE
+);
+CREATE INDEX "idx_order_items_deleted_at" ON "order_items" ("deleted_at");
+
+CREATE TABLE "order_tax_lines" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "order_id" bigint NOT NULL,
+    "rate" decimal(5,2) NOT NULL,
+    "taxable_amount" bigint NOT NULL,
+    "tax_amount" bigint NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_orders_tax_lines" FOREIGN KEY ("order_id") REFERENCES "orders"("id") ON DELETE CASCADE
+);
+CREATE INDEX "idx_order_tax_lines_order_id" ON "order_tax_lines" ("order_id");
+CREATE INDEX "idx_order_tax_lines_deleted_at" ON "order_tax_lines" ("deleted_at");
+
+CREATE TABLE "promos" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "code" text NOT NULL,
+    "description" text,
+    "discount_type" text NOT NULL,
+    "percent" decimal(5,2) NOT NULL DEFAULT 0,
+    "amount" bigint NOT NULL DEFAULT 0,
+    "min_order_amount" bigint NOT NULL DEFAULT 0,
+    "starts_at" timestamptz,
+    "expires_at" timestamptz,
+    "max_redemptions" bigint NOT NULL DEFAULT 0,
+    "max_per_client" bigint NOT NULL DEFAULT 0,
+    "redemption_count" bigint NOT NULL DEFAULT 0,
+    "is_active" boolean NOT NULL DEFAULT true,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_promos_code" UNIQUE ("code")
+);
+CREATE INDEX "idx_promos_deleted_at" ON "promos" ("deleted_at");
+
+CREATE TABLE "promo_menu_items" (
+    "promo_id" bigint,
+    "menu_item_id" bigint,
+    PRIMARY KEY ("promo_id","menu_item_id"),
+    CONSTRAINT "fk_promo_menu_items_promo" FOREIGN KEY ("promo_id") REFERENCES "promos"("id"),
+    CONSTRAINT "fk_promo_menu_items_menu_item" FOREIGN KEY ("menu_item_id") REFERENCES "menu_items"("id")
+);
+
+CREATE TABLE "promo_categories" (
+    "id" bigserial,
+    "promo_id" bigint NOT NULL,
+    "category" text NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_promos_categories" FOREIGN KEY ("promo_id") REFERENCES "promos"("id") ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX "idx_promo_categories_promo_category" ON "promo_categories" ("promo_id","category");
+
+CREATE TABLE "promo_redemptions" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "promo_id" bigint NOT NULL,
+    "order_id" bigint NOT NULL,
+    "client_id" bigint NOT NULL,
+    "discount_amount" bigint NOT NULL,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_promo_redemptions_client_id" ON "promo_redemptions" ("client_id");
+CREATE UNIQUE INDEX "idx_promo_redemptions_order_id" ON "promo_redemptions" ("order_id");
+CREATE INDEX "idx_promo_redemptions_promo_id" ON "promo_redemptions" ("promo_id");
+CREATE INDEX "idx_promo_redemptions_deleted_at" ON "promo_redemptions" ("deleted_at");
+
+CREATE TABLE "idempotency_keys" (
+    "id" bigserial,
+    "created_at" timestamptz NOT NULL,
+    "scope" text NOT NULL,
+    "key" text NOT NULL,
+    "request_hash" text NOT NULL,
+    "status_code" bigint NOT NULL DEFAULT 0,
+    "content_type" text,
+    "body" bytea,
+    "expires_at" timestamptz NOT NULL,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_idempotency_keys_expires_at" ON "idempotency_keys" ("expires_at");
+CREATE UNIQUE INDEX "idx_idempotency_keys_scope_key" ON "idempotency_keys" ("scope","key");
+
+CREATE TABLE "order_status_events" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "order_id" bigint NOT NULL,
+    "from_status" text,
+    "to_status" text NOT NULL,
+    "changed_by_id" bigint,
+    "changed_by_name" text,
+    "reason" text,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_order_status_events_order_id" ON "order_status_events" ("order_id");
+CREATE INDEX "idx_order_status_events_deleted_at" ON "order_status_events" ("deleted_at");
+
+CREATE TABLE "invoices" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "order_id" bigint NOT NULL,
+    "fiscal_year" bigint NOT NULL,
+    "number" bigint NOT NULL,
+    "invoice_number" text NOT NULL,
+    "issued_at" timestamptz NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_invoices_invoice_number" UNIQUE ("invoice_number")
+);
+CREATE UNIQUE INDEX "idx_invoices_fiscal_year_number" ON "invoices" ("fiscal_year","number");
+CREATE UNIQUE INDEX "idx_invoices_order_id" ON "invoices" ("order_id");
+
+CREATE TABLE "invoice_sequences" (
+    "fiscal_year" bigint,
+    "last_number" bigint NOT NULL,
+    PRIMARY KEY ("fiscal_year")
+);
+
+CREATE TABLE "ingredients" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "name" text NOT NULL,
+    "unit" text NOT NULL,
+    "stock_quantity" decimal(12,3) NOT NULL DEFAULT 0,
+    "low_stock_threshold" decimal(12,3) NOT NULL DEFAULT 0,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_ingredients_name" UNIQUE ("name")
+);
+CREATE INDEX "idx_ingredients_deleted_at" ON "ingredients" ("deleted_at");
+
+CREATE TABLE "recipe_items" (
+    "id" bigserial,
+    "menu_item_id" bigint NOT NULL,
+    "ingredient_id" bigint NOT NULL,
+    "quantity_per_portion" decimal(12,3) NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_recipe_items_ingredient" FOREIGN KEY ("ingredient_id") REFERENCES "ingredients"("id")
+);
+CREATE UNIQUE INDEX "idx_recipe_items_menu_item_ingredient" ON "recipe_items" ("menu_item_id","ingredient_id");
+
+CREATE TABLE "stock_movements" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "ingredient_id" bigint NOT NULL,
+    "change" decimal(12,3) NOT NULL,
+    "reason" text NOT NULL,
+    "order_id" bigint,
+    "user_id" bigint,
+    "note" text,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_stock_movements_order_id" ON "stock_movements" ("order_id");
+CREATE INDEX "idx_stock_movements_ingredient_id" ON "stock_movements" ("ingredient_id");
+CREATE INDEX "idx_stock_movements_deleted_at" ON "stock_movements" ("deleted_at");
+
+CREATE FUNCTION reject_audit_log_change() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_logs is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_logs_append_only BEFORE UPDATE OR DELETE ON audit_logs
+    FOR EACH ROW EXECUTE FUNCTION reject_audit_log_change();
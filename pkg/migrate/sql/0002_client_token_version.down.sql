@@ -0,0 +1,3 @@
+ALTER TABLE "clients"
+    DROP COLUMN "token_version",
+    DROP COLUMN "passcode_rotation_required";
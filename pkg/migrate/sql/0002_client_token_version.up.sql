@@ -0,0 +1,7 @@
+-- Clients carried over from the legacy 4-digit passcodes must be given a new passcode before they
+-- can log in with one. The legacy upgrade adds passcode_rotation_required itself to flag them, so
+-- it may already exist. token_version is stored in client tokens and bumped when the passcode is
+-- rotated, which invalidates the tokens issued before.
+ALTER TABLE "clients"
+    ADD COLUMN IF NOT EXISTS "passcode_rotation_required" boolean NOT NULL DEFAULT false,
+    ADD COLUMN "token_version" bigint NOT NULL DEFAULT 0;
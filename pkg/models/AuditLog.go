@@ -0,0 +1,26 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+)
+
+// AuditLog records a change made through the admin API. Entries are never updated or deleted.
+// Changes maps each changed field to its "from" and "to" values.
+type AuditLog struct {
+	ID         uint            `json:"id" gorm:"primarykey"`
+	CreatedAt  time.Time       `json:"created_at" gorm:"index"`
+	ActorID    *uint           `json:"actor_id,omitempty" gorm:"index"`
+	ActorName  string          `json:"actor_name,omitempty"`
+	Action     string          `json:"action" gorm:"not null"`
+	EntityType string          `json:"entity_type" gorm:"not null;index:idx_audit_logs_entity"`
+	EntityID   string          `json:"entity_id" gorm:"not null;index:idx_audit_logs_entity"`
+	Changes    json.RawMessage `json:"changes" gorm:"type:jsonb"`
+	IPAddress  string          `json:"ip_address,omitempty"`
+}
@@ -1,42 +1,89 @@
 package models
 
 import (
-	"errors"
-	"fmt"
-	"gorm.io/gorm"
-	"math/rand"
+	"crypto/rand"
+	"math/big"
+	"strings"
 	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
+// passcodeAlphabet leaves out characters that are easily confused when read out or typed.
+const passcodeAlphabet = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+
+const passcodeLength = 10
+
 type Client struct {
 	gorm.Model
-	Name     string `json:"name" gorm:"not null"`
-	Passcode string `json:"passcode" gorm:"unique;not null;size:4"`
-	Email    string `json:"email,omitempty" gorm:"unique"`
-	Phone    string `json:"phone,omitempty"`
-	Address  string `json:"address,omitempty"`
-	IsActive bool   `json:"is_active"`
-	IsAdmin  bool   `json:"is_admin"`
+	Name string `json:"name" gorm:"not null"`
+	// Passcode is only set right after a passcode is generated, so that it can be handed to the
+	// client once. Only its hash is stored.
+	Passcode     string `json:"passcode,omitempty" gorm:"-"`
+	PasscodeHash string `json:"-" gorm:"not null"`
+	Email        string `json:"email,omitempty" gorm:"unique"`
+	Phone        string `json:"phone,omitempty"`
+	Address      string `json:"address,omitempty"`
+	IsActive     bool   `json:"is_active"`
+	IsAdmin      bool   `json:"is_admin"`
+	// PasscodeRotationRequired is set on clients whose passcode was carried over from the legacy
+	// 4-digit passcodes. They cannot log in with it until they are given a new one.
+	PasscodeRotationRequired bool `json:"passcode_rotation_required" gorm:"not null;default:false"`
+	// TokenVersion is stored in the client's tokens, which are only accepted while it is unchanged.
+	// It is bumped when the passcode is rotated.
+	TokenVersion int `json:"-" gorm:"not null;default:0"`
+}
+
+// ClientLoginToken is a single use magic link token. Only the SHA-256 hash of the token is stored.
+type ClientLoginToken struct {
+	ID        uint       `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time  `json:"created_at"`
+	ClientID  uint       `json:"client_id" gorm:"not null;index"`
+	TokenHash string     `json:"-" gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
 }
 
 func (c *Client) BeforeCreate(tx *gorm.DB) (err error) {
-	rand.NewSource(time.Now().UnixNano())
-
-	for {
-		passcode := generatePasscode()
-		var existingClient Client
-		if err := tx.Where("passcode = ?", passcode).First(&existingClient).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				c.Passcode = passcode
-				break
-			} else {
-				return err
-			}
-		}
+	if c.PasscodeHash == "" {
+		return c.SetNewPasscode()
 	}
 	return nil
 }
 
-func generatePasscode() string {
-	return fmt.Sprintf("%04d", rand.Intn(10000))
+// SetNewPasscode generates a random passcode for the client, storing it in Passcode and its hash
+// in PasscodeHash. The caller saves the hash.
+func (c *Client) SetNewPasscode() error {
+	passcode, err := generatePasscode()
+	if err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(passcode), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	c.Passcode = passcode
+	c.PasscodeHash = string(hash)
+	return nil
+}
+
+// CheckPasscode reports whether passcode matches the client's passcode. Passcodes are not case
+// sensitive.
+func (c *Client) CheckPasscode(passcode string) bool {
+	passcode = strings.ToUpper(strings.TrimSpace(passcode))
+	return bcrypt.CompareHashAndPassword([]byte(c.PasscodeHash), []byte(passcode)) == nil
+}
+
+func generatePasscode() (string, error) {
+	passcode := make([]byte, passcodeLength)
+	max := big.NewInt(int64(len(passcodeAlphabet)))
+	for i := range passcode {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		passcode[i] = passcodeAlphabet[n.Int64()]
+	}
+	return string(passcode), nil
 }
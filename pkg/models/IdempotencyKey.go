@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// IdempotencyKey records the response to a request sent with an Idempotency-Key header, so that
+// retries of the request get the stored response instead of repeating it. Keys are unique per
+// sender, identified by Scope such as "user:1" or "client:2".
+type IdempotencyKey struct {
+	ID        uint      `gorm:"primarykey"`
+	CreatedAt time.Time `gorm:"not null"`
+	Scope     string    `gorm:"not null;uniqueIndex:idx_idempotency_keys_scope_key"`
+	Key       string    `gorm:"not null;uniqueIndex:idx_idempotency_keys_scope_key"`
+	// RequestHash is the SHA-256 hash of the method, path and body of the first request.
+	RequestHash string `gorm:"not null"`
+	// StatusCode is 0 while the first request is still being handled.
+	StatusCode  int `gorm:"not null;default:0"`
+	ContentType string
+	Body        []byte
+	ExpiresAt   time.Time `gorm:"not null;index"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "gorm.io/gorm"
+
+const (
+	StockMovementOrderAccepted  = "order_accepted"
+	StockMovementOrderCancelled = "order_cancelled"
+	StockMovementOrderDeleted   = "order_deleted"
+	StockMovementAdjustment     = "adjustment"
+	StockMovementDelivery       = "delivery"
+)
+
+// Ingredient is a stocked ingredient. Quantities are in the ingredient's Unit, such as kg or pcs.
+type Ingredient struct {
+	gorm.Model
+	Name              string  `json:"name" gorm:"unique;not null"`
+	Unit              string  `json:"unit" gorm:"not null"`
+	StockQuantity     float64 `json:"stock_quantity" gorm:"not null;default:0;type:decimal(12,3)"`
+	LowStockThreshold float64 `json:"low_stock_threshold" gorm:"not null;default:0;type:decimal(12,3)"`
+}
+
+// RecipeItem is the quantity of an ingredient used by one portion of a menu item.
+type RecipeItem struct {
+	ID                 uint       `json:"id" gorm:"primarykey"`
+	MenuItemID         uint       `json:"menu_item_id" gorm:"not null;uniqueIndex:idx_recipe_items_menu_item_ingredient"`
+	IngredientID       uint       `json:"ingredient_id" gorm:"not null;uniqueIndex:idx_recipe_items_menu_item_ingredient"`
+	Ingredient         Ingredient `json:"ingredient" gorm:"foreignKey:IngredientID;references:ID"`
+	QuantityPerPortion float64    `json:"quantity_per_portion" gorm:"not null;type:decimal(12,3)"`
+}
+
+// StockMovement records a change of an ingredient's stock level and why it happened.
+type StockMovement struct {
+	gorm.Model
+	IngredientID uint    `json:"ingredient_id" gorm:"not null;index"`
+	Change       float64 `json:"change" gorm:"not null;type:decimal(12,3)"`
+	Reason       string  `json:"reason" gorm:"not null"`
+	OrderID      *int    `json:"order_id,omitempty" gorm:"index"`
+	UserID       *uint   `json:"user_id,omitempty"`
+	Note         string  `json:"note,omitempty"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Invoice is the fiscal document issued for an order once it has been delivered. Invoices are
+// numbered sequentially without gaps within each fiscal year and are never deleted, even when
+// the order they belong to is.
+type Invoice struct {
+	ID            uint      `json:"id" gorm:"primarykey"`
+	CreatedAt     time.Time `json:"created_at"`
+	OrderID       int       `json:"order_id" gorm:"not null;uniqueIndex"`
+	FiscalYear    int       `json:"fiscal_year" gorm:"not null;uniqueIndex:idx_invoices_fiscal_year_number"`
+	Number        int       `json:"number" gorm:"not null;uniqueIndex:idx_invoices_fiscal_year_number"`
+	InvoiceNumber string    `json:"invoice_number" gorm:"not null;unique"`
+	IssuedAt      time.Time `json:"issued_at" gorm:"not null"`
+}
+
+// InvoiceSequence holds the last invoice number handed out in a fiscal year. The row is updated
+// in the same transaction that creates the invoice, so a rolled back invoice also rolls back its
+// number.
+type InvoiceSequence struct {
+	FiscalYear int `gorm:"primaryKey;autoIncrement:false"`
+	LastNumber int `gorm:"not null"`
+}
@@ -1,15 +1,37 @@
 package models
 
 import (
+	"yom-kitchen/pkg/money"
+
 	"gorm.io/gorm"
 )
 
 type MenuItem struct {
 	gorm.Model
-	Name      string  `form:"name" json:"name" gorm:"unique;not null"`
-	Desc      string  `form:"desc" json:"desc"`
-	ImageUrl  string  `json:"image_url"`
-	Price     float64 `form:"price" json:"price" gorm:"not null"`
-	Category  string  `form:"category" json:"category"`
-	Available bool    `form:"available" json:"available" gorm:"default:true"`
+	Name string `form:"name" json:"name" gorm:"unique;not null"`
+	Desc string `form:"desc" json:"desc"`
+	// ImageKey is the key of the item's image in the image store, its largest variant.
+	// ImageVariantKeys holds the keys of every variant by name; images uploaded before variants
+	// were generated have none. ImageUrl and ImageUrls are not stored; handlers fill them in from
+	// the keys for each response, since the store may hand out expiring URLs.
+	ImageKey         string            `form:"-" json:"image_key,omitempty" gorm:"not null;default:''"`
+	ImageVariantKeys map[string]string `form:"-" json:"-" gorm:"type:jsonb;serializer:json"`
+	ImageUrl         string            `form:"-" json:"image_url" gorm:"-"`
+	ImageUrls        map[string]string `form:"-" json:"image_urls,omitempty" gorm:"-"`
+	Price            money.Amount      `form:"price" json:"price" gorm:"not null;type:bigint"`
+	Category         string            `form:"category" json:"category"`
+	Available        bool              `form:"available" json:"available" gorm:"default:true"`
+	// AutoUnavailable is set when the item was made unavailable because an ingredient ran out, so
+	// that it can be made available again once the ingredient is restocked.
+	AutoUnavailable bool `json:"auto_unavailable" gorm:"not null;default:false"`
+	// TaxRate overrides the tax rate of the item's category, in percent.
+	TaxRate *float64 `form:"tax_rate" json:"tax_rate,omitempty" gorm:"type:decimal(5,2)"`
+}
+
+// CategoryTaxRate is the tax rate, in percent, applied to menu items of a category that do not
+// set their own rate.
+type CategoryTaxRate struct {
+	gorm.Model
+	Category string  `json:"category" gorm:"unique;not null"`
+	Rate     float64 `json:"rate" gorm:"not null;type:decimal(5,2)"`
 }
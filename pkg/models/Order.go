@@ -3,27 +3,95 @@ package models
 import (
 	"time"
 
+	"yom-kitchen/pkg/money"
+
 	"gorm.io/gorm"
 )
 
+const (
+	OrderStatusPending   = "Pending"
+	OrderStatusAccepted  = "Accepted"
+	OrderStatusReady     = "Ready"
+	OrderStatusDelivered = "Delivered"
+	OrderStatusCancelled = "Cancelled"
+)
+
+// orderStatusTransitions lists, for every order status, the statuses an order may move to next.
+// Delivered and Cancelled are terminal.
+var orderStatusTransitions = map[string][]string{
+	OrderStatusPending:   {OrderStatusAccepted, OrderStatusCancelled},
+	OrderStatusAccepted:  {OrderStatusReady, OrderStatusCancelled},
+	OrderStatusReady:     {OrderStatusDelivered},
+	OrderStatusDelivered: {},
+	OrderStatusCancelled: {},
+}
+
+// Order amounts: SubtotalAmount is the sum of the item subtotals, NetAmount is what remains after
+// discounts, and TotalAmount is the gross amount due including service charge and taxes.
 type Order struct {
 	gorm.Model
-	ClientID    int         `json:"client_id" gorm:"not null"`
-	Client      Client      `json:"client" gorm:"foreignKey:ClientID;references:ID"`
-	OrderDate   time.Time   `json:"order_date" gorm:"not null;default:now()"`
-	OrderItems  []OrderItem `json:"order_items" gorm:"foreignKey:OrderID;references:ID;constraint:OnDelete:CASCADE"`
-	TotalAmount float64     `json:"total_amount" gorm:"not null;type:decimal(10,2)"`
-	Status      string      `json:"status" gorm:"default:'Pending'"`
-	Notes       string      `json:"notes,omitempty"`
+	ClientID            int            `json:"client_id" gorm:"not null"`
+	Client              Client         `json:"client" gorm:"foreignKey:ClientID;references:ID"`
+	OrderDate           time.Time      `json:"order_date" gorm:"not null;default:now()"`
+	OrderItems          []OrderItem    `json:"order_items" gorm:"foreignKey:OrderID;references:ID;constraint:OnDelete:CASCADE"`
+	TaxLines            []OrderTaxLine `json:"tax_lines" gorm:"foreignKey:OrderID;references:ID;constraint:OnDelete:CASCADE"`
+	SubtotalAmount      money.Amount   `json:"subtotal_amount" gorm:"not null;default:0;type:bigint"`
+	DiscountAmount      money.Amount   `json:"discount_amount" gorm:"not null;default:0;type:bigint"`
+	NetAmount           money.Amount   `json:"net_amount" gorm:"not null;default:0;type:bigint"`
+	ServiceChargeAmount money.Amount   `json:"service_charge_amount" gorm:"not null;default:0;type:bigint"`
+	TaxAmount           money.Amount   `json:"tax_amount" gorm:"not null;default:0;type:bigint"`
+	TotalAmount         money.Amount   `json:"total_amount" gorm:"not null;type:bigint"`
+	PromoCode           string         `json:"promo_code,omitempty"`
+	Status              string         `json:"status" gorm:"default:'Pending'"`
+	Notes               string         `json:"notes,omitempty"`
 }
 
+// OrderItem amounts: Subtotal is ItemPrice times Quantity, DiscountAmount includes the item's
+// share of any order level discount, and TaxAmount is charged on the discounted subtotal.
 type OrderItem struct {
 	gorm.Model
-	OrderID    int     `json:"order_id" gorm:"not null"`
-	Order      Order   `json:"order" gorm:"foreignKey:OrderID;references:ID"`
-	MenuItemID int     `json:"menu_item_id" gorm:"not null"`
-	ItemName   string  `json:"item_name" gorm:"not null"`
-	ItemPrice  float64 `json:"item_price" gorm:"not null;type:decimal(10,2);"`
-	Quantity   int     `json:"quantity" gorm:"not null;default:1"`
-	Subtotal   float64 `json:"subtotal" gorm:"not null;type:decimal(10,2);"`
+	OrderID        int          `json:"order_id" gorm:"not null"`
+	Order          Order        `json:"order" gorm:"foreignKey:OrderID;references:ID"`
+	MenuItemID     int          `json:"menu_item_id" gorm:"not null"`
+	ItemName       string       `json:"item_name" gorm:"not null"`
+	ItemPrice      money.Amount `json:"item_price" gorm:"not null;type:bigint"`
+	Quantity       int          `json:"quantity" gorm:"not null;default:1"`
+	Subtotal       money.Amount `json:"subtotal" gorm:"not null;type:bigint"`
+	DiscountAmount money.Amount `json:"discount_amount" gorm:"not null;default:0;type:bigint"`
+	TaxRate        float64      `json:"tax_rate" gorm:"not null;default:0;type:decimal(5,2)"`
+	TaxAmount      money.Amount `json:"tax_amount" gorm:"not null;default:0;type:bigint"`
+}
+
+// OrderTaxLine is the tax charged on an order at one rate, as shown in the invoice's VAT
+// breakdown.
+type OrderTaxLine struct {
+	gorm.Model
+	OrderID       int          `json:"order_id" gorm:"not null;index"`
+	Rate          float64      `json:"rate" gorm:"not null;type:decimal(5,2)"`
+	TaxableAmount money.Amount `json:"taxable_amount" gorm:"not null;type:bigint"`
+	TaxAmount     money.Amount `json:"tax_amount" gorm:"not null;type:bigint"`
+}
+
+// IsValidOrderStatus reports whether status is one of the known order statuses.
+func IsValidOrderStatus(status string) bool {
+	_, ok := orderStatusTransitions[status]
+	return ok
+}
+
+// AllowedOrderStatusTransitions returns the statuses an order in the given status may move to.
+func AllowedOrderStatusTransitions(status string) []string {
+	next := orderStatusTransitions[status]
+	allowed := make([]string, len(next))
+	copy(allowed, next)
+	return allowed
+}
+
+// CanTransitionOrderStatus reports whether an order may move from one status to another.
+func CanTransitionOrderStatus(from, to string) bool {
+	for _, status := range orderStatusTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
 }
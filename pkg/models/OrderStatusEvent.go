@@ -0,0 +1,16 @@
+package models
+
+import "gorm.io/gorm"
+
+// OrderStatusEvent records a single change of an order's status. FromStatus is empty for the
+// event written when the order is created, and ChangedByID is nil when the change was not made
+// by a staff user (for example an order placed by a client).
+type OrderStatusEvent struct {
+	gorm.Model
+	OrderID       int    `json:"order_id" gorm:"not null;index"`
+	FromStatus    string `json:"from_status"`
+	ToStatus      string `json:"to_status" gorm:"not null"`
+	ChangedByID   *uint  `json:"changed_by_id,omitempty"`
+	ChangedByName string `json:"changed_by_name,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"yom-kitchen/pkg/money"
+
+	"gorm.io/gorm"
+)
+
+const (
+	PromoTypePercentage = "percentage"
+	PromoTypeFixed      = "fixed"
+)
+
+// Promo is a promotional code clients can enter when ordering. A promo restricted to menu items
+// or categories only discounts the matching lines; otherwise it discounts the whole order.
+// Limits of zero mean unlimited.
+type Promo struct {
+	gorm.Model
+	Code            string          `json:"code" gorm:"unique;not null"`
+	Description     string          `json:"description,omitempty"`
+	DiscountType    string          `json:"discount_type" gorm:"not null"`
+	Percent         float64         `json:"percent,omitempty" gorm:"not null;default:0;type:decimal(5,2)"`
+	Amount          money.Amount    `json:"amount,omitempty" gorm:"not null;default:0;type:bigint"`
+	MinOrderAmount  money.Amount    `json:"min_order_amount" gorm:"not null;default:0;type:bigint"`
+	StartsAt        *time.Time      `json:"starts_at,omitempty"`
+	ExpiresAt       *time.Time      `json:"expires_at,omitempty"`
+	MaxRedemptions  int             `json:"max_redemptions" gorm:"not null;default:0"`
+	MaxPerClient    int             `json:"max_per_client" gorm:"not null;default:0"`
+	RedemptionCount int             `json:"redemption_count" gorm:"not null;default:0"`
+	IsActive        bool            `json:"is_active" gorm:"not null;default:true"`
+	MenuItems       []MenuItem      `json:"menu_items,omitempty" gorm:"many2many:promo_menu_items"`
+	Categories      []PromoCategory `json:"categories,omitempty" gorm:"foreignKey:PromoID;constraint:OnDelete:CASCADE"`
+}
+
+type PromoCategory struct {
+	ID       uint   `json:"-" gorm:"primarykey"`
+	PromoID  uint   `json:"-" gorm:"not null;uniqueIndex:idx_promo_categories_promo_category"`
+	Category string `json:"category" gorm:"not null;uniqueIndex:idx_promo_categories_promo_category"`
+}
+
+// PromoRedemption records the use of a promo on an order.
+type PromoRedemption struct {
+	gorm.Model
+	PromoID        uint         `json:"promo_id" gorm:"not null;index"`
+	OrderID        int          `json:"order_id" gorm:"not null;uniqueIndex"`
+	ClientID       int          `json:"client_id" gorm:"not null;index"`
+	DiscountAmount money.Amount `json:"discount_amount" gorm:"not null;type:bigint"`
+}
+
+// AppliesTo reports whether the promo discounts the given menu item.
+func (p *Promo) AppliesTo(item MenuItem) bool {
+	if len(p.MenuItems) == 0 && len(p.Categories) == 0 {
+		return true
+	}
+	for _, menuItem := range p.MenuItems {
+		if menuItem.ID == item.ID {
+			return true
+		}
+	}
+	for _, category := range p.Categories {
+		if category.Category == item.Category {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizePromoCode returns the form in which promo codes are stored, so that clients can enter
+// them in any case.
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"slices"
+
+	"gorm.io/gorm"
+)
+
+const (
+	PermissionAll               = "*"
+	PermissionStatsRead         = "stats:read"
+	PermissionUsersRead         = "users:read"
+	PermissionUsersManage       = "users:manage"
+	PermissionRolesManage       = "roles:manage"
+	PermissionMenusRead         = "menus:read"
+	PermissionMenusManage       = "menus:manage"
+	PermissionMenusAvailability = "menus:availability"
+	PermissionInventoryRead     = "inventory:read"
+	PermissionInventoryManage   = "inventory:manage"
+	PermissionTaxRatesManage    = "tax_rates:manage"
+	PermissionPromosManage      = "promos:manage"
+	PermissionClientsRead       = "clients:read"
+	PermissionClientsManage     = "clients:manage"
+	PermissionOrdersRead        = "orders:read"
+	PermissionOrdersCreate      = "orders:create"
+	PermissionOrdersUpdate      = "orders:update_status"
+	PermissionOrdersDelete      = "orders:delete"
+	PermissionOrdersExport      = "orders:export"
+	PermissionAuditRead         = "audit:read"
+)
+
+// Permissions lists every permission that can be granted to a role, apart from PermissionAll.
+var Permissions = []string{
+	PermissionStatsRead,
+	PermissionUsersRead,
+	PermissionUsersManage,
+	PermissionRolesManage,
+	PermissionMenusRead,
+	PermissionMenusManage,
+	PermissionMenusAvailability,
+	PermissionInventoryRead,
+	PermissionInventoryManage,
+	PermissionTaxRatesManage,
+	PermissionPromosManage,
+	PermissionClientsRead,
+	PermissionClientsManage,
+	PermissionOrdersRead,
+	PermissionOrdersCreate,
+	PermissionOrdersUpdate,
+	PermissionOrdersDelete,
+	PermissionOrdersExport,
+	PermissionAuditRead,
+}
+
+const (
+	RoleOwner   = "owner"
+	RoleManager = "manager"
+	RoleCashier = "cashier"
+	RoleKitchen = "kitchen"
+	RoleDriver  = "driver"
+)
+
+// Role is a named set of permissions given to staff users. System roles are created on start and
+// cannot be renamed or deleted.
+type Role struct {
+	gorm.Model
+	Name        string `json:"name" gorm:"unique;not null"`
+	Description string `json:"description,omitempty"`
+	IsSystem    bool   `json:"is_system" gorm:"not null;default:false"`
+	// RequireTOTP makes users with the role enroll in TOTP two-factor authentication before they
+	// can log in.
+	RequireTOTP bool             `json:"require_totp" gorm:"not null;default:false"`
+	Permissions []RolePermission `json:"-" gorm:"foreignKey:RoleID;constraint:OnDelete:CASCADE"`
+	Users       []User           `json:"-" gorm:"many2many:user_roles"`
+}
+
+type RolePermission struct {
+	ID         uint   `json:"id" gorm:"primarykey"`
+	RoleID     uint   `json:"role_id" gorm:"not null;uniqueIndex:idx_role_permissions_role_permission"`
+	Permission string `json:"permission" gorm:"not null;uniqueIndex:idx_role_permissions_role_permission"`
+}
+
+func (r *Role) PermissionNames() []string {
+	names := make([]string, 0, len(r.Permissions))
+	for _, permission := range r.Permissions {
+		names = append(names, permission.Permission)
+	}
+	return names
+}
+
+func IsValidPermission(permission string) bool {
+	return permission == PermissionAll || slices.Contains(Permissions, permission)
+}
+
+// DefaultRoles returns the system roles with their initial permissions.
+func DefaultRoles() []Role {
+	role := func(name, description string, permissions ...string) Role {
+		r := Role{Name: name, Description: description, IsSystem: true}
+		for _, permission := range permissions {
+			r.Permissions = append(r.Permissions, RolePermission{Permission: permission})
+		}
+		return r
+	}
+	return []Role{
+		role(RoleOwner, "Full access", PermissionAll),
+		role(RoleManager, "Runs the restaurant, without managing staff accounts",
+			PermissionStatsRead, PermissionUsersRead, PermissionMenusRead, PermissionMenusManage,
+			PermissionMenusAvailability, PermissionInventoryRead, PermissionInventoryManage,
+			PermissionTaxRatesManage, PermissionPromosManage, PermissionClientsRead,
+			PermissionClientsManage, PermissionOrdersRead, PermissionOrdersCreate,
+			PermissionOrdersUpdate, PermissionOrdersDelete, PermissionOrdersExport, PermissionAuditRead),
+		role(RoleCashier, "Takes orders and manages clients",
+			PermissionMenusRead, PermissionClientsRead, PermissionClientsManage,
+			PermissionOrdersRead, PermissionOrdersCreate, PermissionOrdersUpdate),
+		role(RoleKitchen, "Prepares orders",
+			PermissionMenusRead, PermissionMenusAvailability, PermissionInventoryRead,
+			PermissionOrdersRead, PermissionOrdersUpdate),
+		role(RoleDriver, "Delivers orders",
+			PermissionOrdersRead, PermissionOrdersUpdate),
+	}
+}
+
+// GrantsPermission reports whether the granted permissions include permission, either directly
+// or through PermissionAll.
+func GrantsPermission(granted []string, permission string) bool {
+	return slices.Contains(granted, permission) || slices.Contains(granted, PermissionAll)
+}
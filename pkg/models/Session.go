@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Session is a staff user's login. Access tokens carry the session ID and stop working once the
+// session is revoked. The refresh token is rotated on every use; only SHA-256 hashes of the
+// current and the previous refresh token are stored, the latter to detect a reused token.
+type Session struct {
+	ID                       uint       `json:"id" gorm:"primarykey"`
+	CreatedAt                time.Time  `json:"created_at"`
+	UpdatedAt                time.Time  `json:"updated_at"`
+	UserID                   uint       `json:"user_id" gorm:"not null;index"`
+	RefreshTokenHash         string     `json:"-" gorm:"not null;uniqueIndex"`
+	PreviousRefreshTokenHash string     `json:"-" gorm:"index"`
+	ExpiresAt                time.Time  `json:"expires_at" gorm:"not null"`
+	LastUsedAt               time.Time  `json:"last_used_at"`
+	RevokedAt                *time.Time `json:"revoked_at,omitempty"`
+	UserAgent                string     `json:"user_agent,omitempty"`
+	IPAddress                string     `json:"ip_address,omitempty"`
+}
+
+func (s *Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
@@ -1,10 +1,36 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type User struct {
 	gorm.Model
 	Username     string `json:"username" gorm:"unique;not null"`
 	PasswordHash string `json:"password" gorm:"not null"`
-	IsAdmin      bool   `json:"is_admin" gorm:"default:false"`
+	// IsAdmin grants every permission, like the owner role.
+	IsAdmin bool   `json:"is_admin" gorm:"default:false"`
+	Roles   []Role `json:"roles,omitempty" gorm:"many2many:user_roles"`
+	// MustChangePassword keeps the user out of the admin routes until they set a new password, for
+	// passwords that someone else knows, like the bootstrap admin password.
+	MustChangePassword bool `json:"must_change_password" gorm:"not null;default:false"`
+	// TOTPSecret is set when TOTP enrollment starts. TOTPEnabled is only set once a code from the
+	// secret has been verified, after which logins need a code.
+	TOTPSecret  string `json:"-"`
+	TOTPEnabled bool   `json:"totp_enabled" gorm:"not null;default:false"`
+	// TOTPLastStep is the time step of the last accepted code, so that a code cannot be used twice.
+	TOTPLastStep  int64          `json:"-" gorm:"not null;default:0"`
+	RecoveryCodes []RecoveryCode `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// RecoveryCode is a single use code that replaces a TOTP code when the authenticator is lost. Only
+// the SHA-256 hash of the code is stored.
+type RecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time  `json:"created_at"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	CodeHash  string     `json:"-" gorm:"not null;uniqueIndex"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
 }
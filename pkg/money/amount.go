@@ -0,0 +1,174 @@
+// Package money represents monetary amounts exactly, as an integer number of minor units
+// (cents), so that prices, totals and reports never suffer from floating point rounding.
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Amount is a monetary amount in minor units: Amount(1250) is 12.50. It is stored as a bigint
+// column and is written to and read from JSON and forms as a decimal number in major units, so
+// API clients keep sending and receiving prices like 12.5.
+type Amount int64
+
+const minorUnitsPerMajor = 100
+
+var ErrInvalidAmount = errors.New("invalid amount: expected a decimal number with at most two decimal places")
+
+// Parse reads a decimal amount in major units, such as "12", "12.5" or "-0.75", without going
+// through floating point.
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, ErrInvalidAmount
+	}
+
+	negative := false
+	if s[0] == '-' || s[0] == '+' {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+
+	whole, fraction, _ := strings.Cut(s, ".")
+	if whole == "" && fraction == "" {
+		return 0, ErrInvalidAmount
+	}
+	fraction = strings.TrimRight(fraction, "0")
+	if len(fraction) > 2 {
+		return 0, ErrInvalidAmount
+	}
+	for len(fraction) < 2 {
+		fraction += "0"
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	for _, part := range []string{whole, fraction} {
+		for _, r := range part {
+			if r < '0' || r > '9' {
+				return 0, ErrInvalidAmount
+			}
+		}
+	}
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || units > math.MaxInt64/minorUnitsPerMajor-1 {
+		return 0, ErrInvalidAmount
+	}
+	cents, _ := strconv.ParseInt(fraction, 10, 64)
+	amount := Amount(units*minorUnitsPerMajor + cents)
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// FromFloat converts an amount in major units, rounding to the nearest minor unit. It is meant
+// for values that are already floating point, such as legacy data; prefer Parse for input.
+func FromFloat(f float64) Amount {
+	return Amount(math.Round(f * minorUnitsPerMajor))
+}
+
+// Minor returns the amount in minor units.
+func (a Amount) Minor() int64 {
+	return int64(a)
+}
+
+// Float64 returns the amount in major units, for display or spreadsheet cells only.
+func (a Amount) Float64() float64 {
+	return float64(a) / minorUnitsPerMajor
+}
+
+// Mul multiplies the amount by a quantity.
+func (a Amount) Mul(quantity int) Amount {
+	return a * Amount(quantity)
+}
+
+// Percent returns percent percent of the amount, rounded half away from zero to the nearest
+// minor unit.
+func (a Amount) Percent(percent float64) Amount {
+	return Amount(math.Round(float64(a) * percent / 100))
+}
+
+// String formats the amount in major units with two decimals, e.g. "12.50".
+func (a Amount) String() string {
+	sign := ""
+	minor := int64(a)
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, minor/minorUnitsPerMajor, minor%minorUnitsPerMajor)
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a numeric string in major units.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "null" {
+		return nil
+	}
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// UnmarshalParam lets gin bind amounts from form fields and query parameters.
+func (a *Amount) UnmarshalParam(param string) error {
+	parsed, err := Parse(param)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+func (a Amount) Value() (driver.Value, error) {
+	return int64(a), nil
+}
+
+// Scan reads an amount stored in minor units. Aggregates such as SUM and AVG may come back as
+// numeric strings, which are rounded to the nearest minor unit.
+func (a *Amount) Scan(value any) error {
+	switch v := value.(type) {
+	case nil:
+		*a = 0
+	case int64:
+		*a = Amount(v)
+	case int32:
+		*a = Amount(v)
+	case float64:
+		*a = Amount(math.Round(v))
+	case []byte:
+		return a.scanString(string(v))
+	case string:
+		return a.scanString(v)
+	default:
+		return fmt.Errorf("cannot scan %T into money.Amount", value)
+	}
+	return nil
+}
+
+func (a *Amount) scanString(s string) error {
+	if minor, err := strconv.ParseInt(s, 10, 64); err == nil {
+		*a = Amount(minor)
+		return nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("cannot scan %q into money.Amount", s)
+	}
+	*a = Amount(math.Round(f))
+	return nil
+}
@@ -0,0 +1,198 @@
+// Package pricing computes the amounts of an order: line and order discounts, the service
+// charge, taxes per rate, and the net and gross totals. Every order creation path goes through
+// Calculate so that all orders are priced the same way.
+//
+// Menu prices are tax exclusive. Discounts are taken off before the service charge and taxes
+// are computed, an order level discount is spread over the eligible lines in proportion to their value,
+// and the service charge is taxed at the service charge tax rate.
+package pricing
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"yom-kitchen/pkg/money"
+)
+
+// Discount is either a percentage or a fixed amount; at most one of the two may be set.
+type Discount struct {
+	Percent float64
+	Amount  money.Amount
+}
+
+type Line struct {
+	UnitPrice money.Amount
+	Quantity  int
+	TaxRate   float64
+	Discount  Discount
+	// ExcludeFromOrderDiscount keeps the order level discount off this line, for discounts that
+	// only apply to some items.
+	ExcludeFromOrderDiscount bool
+}
+
+type Settings struct {
+	ServiceChargePercent float64
+	ServiceChargeTaxRate float64
+}
+
+type LineResult struct {
+	Subtotal       money.Amount
+	DiscountAmount money.Amount
+	NetAmount      money.Amount
+	TaxRate        float64
+	TaxAmount      money.Amount
+}
+
+type TaxLine struct {
+	Rate          float64
+	TaxableAmount money.Amount
+	TaxAmount     money.Amount
+}
+
+type Result struct {
+	Lines []LineResult
+	// Subtotal is the sum of price times quantity before any discount.
+	Subtotal       money.Amount
+	DiscountAmount money.Amount
+	// OrderDiscountAmount is the part of DiscountAmount that comes from the order level discount.
+	OrderDiscountAmount money.Amount
+	NetAmount           money.Amount
+	ServiceCharge       money.Amount
+	TaxAmount           money.Amount
+	TaxLines            []TaxLine
+	// Total is the gross amount due: net amount plus service charge plus taxes.
+	Total money.Amount
+}
+
+var (
+	ErrInvalidDiscount = errors.New("a discount must be a percentage between 0 and 100 or a non-negative amount, not both")
+	ErrDiscountTooHigh = errors.New("discount exceeds the amount it applies to")
+)
+
+func Calculate(lines []Line, orderDiscount Discount, settings Settings) (Result, error) {
+	result := Result{Lines: make([]LineResult, len(lines))}
+
+	var netBeforeOrderDiscount money.Amount
+	for i, line := range lines {
+		subtotal := line.UnitPrice.Mul(line.Quantity)
+		discount, err := discountAmount(line.Discount, subtotal)
+		if err != nil {
+			return Result{}, err
+		}
+		result.Lines[i] = LineResult{
+			Subtotal:       subtotal,
+			DiscountAmount: discount,
+			NetAmount:      subtotal - discount,
+			TaxRate:        line.TaxRate,
+		}
+		result.Subtotal += subtotal
+		if !line.ExcludeFromOrderDiscount {
+			netBeforeOrderDiscount += result.Lines[i].NetAmount
+		}
+	}
+
+	orderDiscountAmount, err := discountAmount(orderDiscount, netBeforeOrderDiscount)
+	if err != nil {
+		return Result{}, err
+	}
+	allocateOrderDiscount(lines, result.Lines, orderDiscountAmount, netBeforeOrderDiscount)
+	result.OrderDiscountAmount = orderDiscountAmount
+
+	taxByRate := make(map[float64]*TaxLine)
+	addTax := func(rate float64, taxable, tax money.Amount) {
+		taxLine, ok := taxByRate[rate]
+		if !ok {
+			taxLine = &TaxLine{Rate: rate}
+			taxByRate[rate] = taxLine
+		}
+		taxLine.TaxableAmount += taxable
+		taxLine.TaxAmount += tax
+	}
+
+	for i := range result.Lines {
+		line := &result.Lines[i]
+		line.TaxAmount = line.NetAmount.Percent(line.TaxRate)
+		result.DiscountAmount += line.DiscountAmount
+		result.NetAmount += line.NetAmount
+		addTax(line.TaxRate, line.NetAmount, line.TaxAmount)
+	}
+
+	result.ServiceCharge = result.NetAmount.Percent(settings.ServiceChargePercent)
+	if result.ServiceCharge > 0 {
+		addTax(settings.ServiceChargeTaxRate, result.ServiceCharge, result.ServiceCharge.Percent(settings.ServiceChargeTaxRate))
+	}
+
+	for _, taxLine := range taxByRate {
+		if taxLine.Rate == 0 {
+			continue
+		}
+		result.TaxLines = append(result.TaxLines, *taxLine)
+		result.TaxAmount += taxLine.TaxAmount
+	}
+	sort.Slice(result.TaxLines, func(i, j int) bool { return result.TaxLines[i].Rate < result.TaxLines[j].Rate })
+	result.Total = result.NetAmount + result.ServiceCharge + result.TaxAmount
+
+	return result, nil
+}
+
+func discountAmount(discount Discount, base money.Amount) (money.Amount, error) {
+	if discount.Percent < 0 || discount.Percent > 100 || discount.Amount < 0 ||
+		(discount.Percent > 0 && discount.Amount > 0) {
+		return 0, ErrInvalidDiscount
+	}
+	if discount.Percent > 0 {
+		return base.Percent(discount.Percent), nil
+	}
+	if discount.Amount > base {
+		return 0, ErrDiscountTooHigh
+	}
+	return discount.Amount, nil
+}
+
+// allocateOrderDiscount spreads an order level discount over the eligible lines in proportion
+// to their net amounts. Rounding differences go on the last line, and whatever it cannot absorb
+// onto the earlier lines that still have a net amount left, so that the line discounts always add
+// up to the order discount.
+func allocateOrderDiscount(requested []Line, lines []LineResult, amount, base money.Amount) {
+	if amount == 0 || base == 0 {
+		return
+	}
+	eligible := func(i int) bool {
+		return !requested[i].ExcludeFromOrderDiscount && lines[i].NetAmount > 0
+	}
+	remaining := amount
+	last := -1
+	for i := range lines {
+		if eligible(i) {
+			last = i
+		}
+	}
+	for i := range lines {
+		if !eligible(i) {
+			continue
+		}
+		share := money.Amount(math.Round(float64(amount) * float64(lines[i].NetAmount) / float64(base)))
+		if i == last || share > remaining {
+			share = remaining
+		}
+		if share > lines[i].NetAmount {
+			share = lines[i].NetAmount
+		}
+		lines[i].DiscountAmount += share
+		lines[i].NetAmount -= share
+		remaining -= share
+	}
+	for i := range lines {
+		if remaining == 0 {
+			break
+		}
+		if !eligible(i) {
+			continue
+		}
+		share := min(remaining, lines[i].NetAmount)
+		lines[i].DiscountAmount += share
+		lines[i].NetAmount -= share
+		remaining -= share
+	}
+}
@@ -0,0 +1,141 @@
+package pricing
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"yom-kitchen/pkg/money"
+)
+
+func TestCalculate(t *testing.T) {
+	tests := []struct {
+		name          string
+		lines         []Line
+		orderDiscount Discount
+		settings      Settings
+		lineDiscounts []money.Amount
+		discount      money.Amount
+		net           money.Amount
+		serviceCharge money.Amount
+		taxLines      []TaxLine
+		total         money.Amount
+	}{
+		{
+			name: "taxes per rate and taxed service charge",
+			lines: []Line{
+				{UnitPrice: 1000, Quantity: 2, TaxRate: 10},
+				{UnitPrice: 500, Quantity: 1},
+			},
+			settings:      Settings{ServiceChargePercent: 10, ServiceChargeTaxRate: 10},
+			lineDiscounts: []money.Amount{0, 0},
+			net:           2500,
+			serviceCharge: 250,
+			taxLines:      []TaxLine{{Rate: 10, TaxableAmount: 2250, TaxAmount: 225}},
+			total:         2975,
+		},
+		{
+			name: "line discount before the order discount",
+			lines: []Line{
+				{UnitPrice: 1000, Quantity: 1, Discount: Discount{Percent: 10}},
+				{UnitPrice: 1000, Quantity: 1},
+			},
+			orderDiscount: Discount{Percent: 10},
+			lineDiscounts: []money.Amount{190, 100},
+			discount:      290,
+			net:           1710,
+			total:         1710,
+		},
+		{
+			name: "excluded line keeps its price",
+			lines: []Line{
+				{UnitPrice: 1000, Quantity: 1, ExcludeFromOrderDiscount: true},
+				{UnitPrice: 1000, Quantity: 1},
+			},
+			orderDiscount: Discount{Amount: 500},
+			lineDiscounts: []money.Amount{0, 500},
+			discount:      500,
+			net:           1500,
+			total:         1500,
+		},
+		{
+			name: "rounding left over by a small last line goes to earlier lines",
+			lines: []Line{
+				{UnitPrice: 10, Quantity: 1},
+				{UnitPrice: 10, Quantity: 1},
+				{UnitPrice: 10, Quantity: 1},
+				{UnitPrice: 1, Quantity: 1},
+			},
+			orderDiscount: Discount{Amount: 29},
+			lineDiscounts: []money.Amount{10, 9, 9, 1},
+			discount:      29,
+			net:           2,
+			total:         2,
+		},
+		{
+			name: "full order discount",
+			lines: []Line{
+				{UnitPrice: 333, Quantity: 1, TaxRate: 20},
+				{UnitPrice: 667, Quantity: 1, TaxRate: 20},
+			},
+			orderDiscount: Discount{Percent: 100},
+			lineDiscounts: []money.Amount{333, 667},
+			discount:      1000,
+			taxLines:      []TaxLine{{Rate: 20}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Calculate(tt.lines, tt.orderDiscount, tt.settings)
+			if err != nil {
+				t.Fatalf("Calculate: %v", err)
+			}
+			var lineDiscounts []money.Amount
+			var lineDiscountSum money.Amount
+			for _, line := range result.Lines {
+				lineDiscounts = append(lineDiscounts, line.DiscountAmount)
+				lineDiscountSum += line.DiscountAmount
+			}
+			if !reflect.DeepEqual(lineDiscounts, tt.lineDiscounts) {
+				t.Errorf("line discounts = %v, want %v", lineDiscounts, tt.lineDiscounts)
+			}
+			if lineDiscountSum != result.DiscountAmount {
+				t.Errorf("line discounts add up to %s, order discount is %s", lineDiscountSum, result.DiscountAmount)
+			}
+			if result.DiscountAmount != tt.discount || result.NetAmount != tt.net ||
+				result.ServiceCharge != tt.serviceCharge || result.Total != tt.total {
+				t.Errorf("discount %s, net %s, service charge %s, total %s; want %s, %s, %s, %s",
+					result.DiscountAmount, result.NetAmount, result.ServiceCharge, result.Total,
+					tt.discount, tt.net, tt.serviceCharge, tt.total)
+			}
+			if !reflect.DeepEqual(result.TaxLines, tt.taxLines) {
+				t.Errorf("tax lines = %+v, want %+v", result.TaxLines, tt.taxLines)
+			}
+		})
+	}
+}
+
+func TestCalculateRejectsInvalidDiscounts(t *testing.T) {
+	lines := []Line{{UnitPrice: 1000, Quantity: 1}}
+	tests := []struct {
+		name          string
+		lineDiscount  Discount
+		orderDiscount Discount
+		want          error
+	}{
+		{"percent over 100", Discount{Percent: 150}, Discount{}, ErrInvalidDiscount},
+		{"negative percent", Discount{}, Discount{Percent: -1}, ErrInvalidDiscount},
+		{"percent and amount", Discount{Percent: 10, Amount: 100}, Discount{}, ErrInvalidDiscount},
+		{"line amount over subtotal", Discount{Amount: 1001}, Discount{}, ErrDiscountTooHigh},
+		{"order amount over net", Discount{Amount: 500}, Discount{Amount: 501}, ErrDiscountTooHigh},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines[0].Discount = tt.lineDiscount
+			if _, err := Calculate(lines, tt.orderDiscount, Settings{}); !errors.Is(err, tt.want) {
+				t.Fatalf("Calculate error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+// Package receipt renders order receipts and invoices as PDF documents using only Go code, so
+// that they can be produced without any external service or binary.
+package receipt
+
+import (
+	"io"
+	"strconv"
+	"time"
+
+	"yom-kitchen/pkg/money"
+
+	"github.com/go-pdf/fpdf"
+)
+
+type Line struct {
+	Name      string
+	Quantity  int
+	UnitPrice money.Amount
+	Amount    money.Amount
+}
+
+// Total is one row of the totals block, such as the subtotal, a tax or the amount due.
+type Total struct {
+	Label  string
+	Amount money.Amount
+	Bold   bool
+}
+
+type Receipt struct {
+	BusinessName    string
+	BusinessAddress string
+	BusinessTaxID   string
+	Currency        string
+
+	// InvoiceNumber is empty for orders that have not been invoiced yet, in which case the
+	// document is rendered as a pro-forma receipt.
+	InvoiceNumber string
+	IssuedAt      time.Time
+	OrderID       uint
+	OrderDate     time.Time
+	Status        string
+
+	ClientName    string
+	ClientAddress string
+	ClientPhone   string
+
+	Lines  []Line
+	Totals []Total
+	Notes  string
+}
+
+const (
+	pageMargin  = 15.0
+	lineHeight  = 6.0
+	nameWidth   = 90.0
+	qtyWidth    = 20.0
+	priceWidth  = 35.0
+	amountWidth = 35.0
+)
+
+// Render writes r to w as an A4 PDF.
+func Render(w io.Writer, r Receipt) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(pageMargin, pageMargin, pageMargin)
+	pdf.SetAutoPageBreak(true, pageMargin)
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 8, tr(r.BusinessName), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	if r.BusinessAddress != "" {
+		pdf.CellFormat(0, 5, tr(r.BusinessAddress), "", 1, "L", false, 0, "")
+	}
+	if r.BusinessTaxID != "" {
+		pdf.CellFormat(0, 5, tr("Tax ID: "+r.BusinessTaxID), "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(4)
+
+	title := "PRO-FORMA RECEIPT"
+	if r.InvoiceNumber != "" {
+		title = "INVOICE"
+	}
+	pdf.SetFont("Helvetica", "B", 14)
+	pdf.CellFormat(0, 8, title, "", 1, "L", false, 0, "")
+
+	pdf.SetFont("Helvetica", "", 10)
+	if r.InvoiceNumber != "" {
+		pdf.CellFormat(0, 5, tr("Invoice No: "+r.InvoiceNumber), "", 1, "L", false, 0, "")
+		pdf.CellFormat(0, 5, "Issued: "+r.IssuedAt.Format("2006-01-02 15:04"), "", 1, "L", false, 0, "")
+	}
+	pdf.CellFormat(0, 5, "Order No: "+strconv.FormatUint(uint64(r.OrderID), 10), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 5, "Order date: "+r.OrderDate.Format("2006-01-02 15:04"), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 5, tr("Status: "+r.Status), "", 1, "L", false, 0, "")
+	pdf.Ln(4)
+
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(0, 5, "Bill to", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	for _, field := range []string{r.ClientName, r.ClientAddress, r.ClientPhone} {
+		if field != "" {
+			pdf.CellFormat(0, 5, tr(field), "", 1, "L", false, 0, "")
+		}
+	}
+	pdf.Ln(4)
+
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.SetFillColor(230, 230, 230)
+	pdf.CellFormat(nameWidth, lineHeight+1, "Item", "B", 0, "L", true, 0, "")
+	pdf.CellFormat(qtyWidth, lineHeight+1, "Qty", "B", 0, "R", true, 0, "")
+	pdf.CellFormat(priceWidth, lineHeight+1, "Unit price", "B", 0, "R", true, 0, "")
+	pdf.CellFormat(amountWidth, lineHeight+1, "Amount", "B", 1, "R", true, 0, "")
+
+	pdf.SetFont("Helvetica", "", 10)
+	for _, line := range r.Lines {
+		pdf.CellFormat(nameWidth, lineHeight, tr(line.Name), "", 0, "L", false, 0, "")
+		pdf.CellFormat(qtyWidth, lineHeight, strconv.Itoa(line.Quantity), "", 0, "R", false, 0, "")
+		pdf.CellFormat(priceWidth, lineHeight, formatAmount(line.UnitPrice, ""), "", 0, "R", false, 0, "")
+		pdf.CellFormat(amountWidth, lineHeight, formatAmount(line.Amount, ""), "", 1, "R", false, 0, "")
+	}
+	pdf.CellFormat(nameWidth+qtyWidth+priceWidth+amountWidth, 1, "", "T", 1, "", false, 0, "")
+	pdf.Ln(2)
+
+	for _, total := range r.Totals {
+		style := ""
+		if total.Bold {
+			style = "B"
+		}
+		pdf.SetFont("Helvetica", style, 10)
+		pdf.CellFormat(nameWidth+qtyWidth, lineHeight, "", "", 0, "", false, 0, "")
+		pdf.CellFormat(priceWidth, lineHeight, tr(total.Label), "", 0, "R", false, 0, "")
+		pdf.CellFormat(amountWidth, lineHeight, formatAmount(total.Amount, r.Currency), "", 1, "R", false, 0, "")
+	}
+
+	if r.Notes != "" {
+		pdf.Ln(6)
+		pdf.SetFont("Helvetica", "I", 9)
+		pdf.MultiCell(0, 5, tr("Notes: "+r.Notes), "", "L", false)
+	}
+
+	return pdf.Output(w)
+}
+
+func formatAmount(amount money.Amount, currency string) string {
+	formatted := amount.String()
+	if currency != "" {
+		formatted += " " + currency
+	}
+	return formatted
+}
@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"context"
+
+	"yom-kitchen/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+type AuditRepository interface {
+	Record(ctx context.Context, entry *models.AuditLog) error
+}
+
+type PostgresAuditRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresAuditRepository(db *gorm.DB) *PostgresAuditRepository {
+	return &PostgresAuditRepository{db: db}
+}
+
+// Record inserts entry in a nested transaction, which inside the caller's transaction is a
+// savepoint, so that a failed insert is rolled back alone instead of aborting the transaction.
+func (r *PostgresAuditRepository) Record(ctx context.Context, entry *models.AuditLog) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		return tx.Create(entry).Error
+	})
+}
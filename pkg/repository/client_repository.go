@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+
+	"yom-kitchen/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// ClientFilter narrows a client list. A nil Active matches active and inactive clients.
+type ClientFilter struct {
+	Active *bool
+}
+
+func (f ClientFilter) Scope(tx *gorm.DB) *gorm.DB {
+	if f.Active != nil {
+		tx = tx.Where("is_active = ?", *f.Active)
+	}
+	return tx
+}
+
+type ClientRepository interface {
+	Get(ctx context.Context, id uint) (models.Client, error)
+	// FindByNameOrEmail returns a client with the name or the email.
+	FindByNameOrEmail(ctx context.Context, name, email string) (models.Client, error)
+	List(ctx context.Context, filter ClientFilter, page Page) ([]models.Client, int64, error)
+	Create(ctx context.Context, client *models.Client) error
+	// Update saves the non-zero fields of changes.
+	Update(ctx context.Context, id uint, changes models.Client) error
+	SetActive(ctx context.Context, id uint, active bool) error
+	Delete(ctx context.Context, id uint) error
+}
+
+type PostgresClientRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresClientRepository(db *gorm.DB) *PostgresClientRepository {
+	return &PostgresClientRepository{db: db}
+}
+
+func (r *PostgresClientRepository) Get(ctx context.Context, id uint) (models.Client, error) {
+	var client models.Client
+	err := conn(ctx, r.db).First(&client, id).Error
+	return client, translate(err)
+}
+
+func (r *PostgresClientRepository) FindByNameOrEmail(ctx context.Context, name, email string) (models.Client, error) {
+	var client models.Client
+	err := conn(ctx, r.db).Where("name = ? OR email = ?", name, email).First(&client).Error
+	return client, translate(err)
+}
+
+func (r *PostgresClientRepository) List(ctx context.Context, filter ClientFilter, page Page) ([]models.Client, int64, error) {
+	db := conn(ctx, r.db)
+	var total int64
+	if err := db.Model(&models.Client{}).Scopes(filter.Scope).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	clients := []models.Client{}
+	if err := db.Scopes(filter.Scope, page.Scope).Find(&clients).Error; err != nil {
+		return nil, 0, err
+	}
+	return clients, total, nil
+}
+
+func (r *PostgresClientRepository) Create(ctx context.Context, client *models.Client) error {
+	return conn(ctx, r.db).Create(client).Error
+}
+
+func (r *PostgresClientRepository) Update(ctx context.Context, id uint, changes models.Client) error {
+	return affected(conn(ctx, r.db).Model(&models.Client{}).Where("id = ?", id).Updates(changes))
+}
+
+func (r *PostgresClientRepository) SetActive(ctx context.Context, id uint, active bool) error {
+	return affected(conn(ctx, r.db).Model(&models.Client{}).Where("id = ?", id).UpdateColumn("is_active", active))
+}
+
+func (r *PostgresClientRepository) Delete(ctx context.Context, id uint) error {
+	return affected(conn(ctx, r.db).Delete(&models.Client{}, id))
+}
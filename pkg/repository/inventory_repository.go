@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+
+	"yom-kitchen/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+type InventoryRepository interface {
+	// ConsumeOrder takes the ingredients of an accepted order out of stock.
+	ConsumeOrder(ctx context.Context, orderID uint, userID *uint) error
+	// RestoreOrder puts back what ConsumeOrder took for an order that is cancelled or deleted,
+	// recording the movements with the given reason. It reverses the recorded movements rather
+	// than the current recipes, which may have changed.
+	RestoreOrder(ctx context.Context, orderID uint, userID *uint, reason string) error
+}
+
+type PostgresInventoryRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresInventoryRepository(db *gorm.DB) *PostgresInventoryRepository {
+	return &PostgresInventoryRepository{db: db}
+}
+
+func (r *PostgresInventoryRepository) ConsumeOrder(ctx context.Context, orderID uint, userID *uint) error {
+	tx := conn(ctx, r.db)
+	var usages []struct {
+		IngredientID uint
+		Quantity     float64
+	}
+	if err := tx.Table("order_items").
+		Select("recipe_items.ingredient_id AS ingredient_id, SUM(recipe_items.quantity_per_portion * order_items.quantity) AS quantity").
+		Joins("JOIN recipe_items ON recipe_items.menu_item_id = order_items.menu_item_id").
+		Where("order_items.order_id = ? AND order_items.deleted_at IS NULL", orderID).
+		Group("recipe_items.ingredient_id").
+		Scan(&usages).Error; err != nil {
+		return err
+	}
+
+	orderIDInt := int(orderID)
+	ingredientIDs := make([]uint, 0, len(usages))
+	for _, usage := range usages {
+		ingredientIDs = append(ingredientIDs, usage.IngredientID)
+		if err := tx.Model(&models.Ingredient{}).Where("id = ?", usage.IngredientID).
+			UpdateColumn("stock_quantity", gorm.Expr("stock_quantity - ?", usage.Quantity)).Error; err != nil {
+			return err
+		}
+		movement := models.StockMovement{
+			IngredientID: usage.IngredientID,
+			Change:       -usage.Quantity,
+			Reason:       models.StockMovementOrderAccepted,
+			OrderID:      &orderIDInt,
+			UserID:       userID,
+		}
+		if err := tx.Create(&movement).Error; err != nil {
+			return err
+		}
+	}
+
+	return RefreshMenuAvailability(tx, ingredientIDs)
+}
+
+func (r *PostgresInventoryRepository) RestoreOrder(ctx context.Context, orderID uint, userID *uint, reason string) error {
+	tx := conn(ctx, r.db)
+	var consumed []struct {
+		IngredientID uint
+		Quantity     float64
+	}
+	if err := tx.Model(&models.StockMovement{}).
+		Select("ingredient_id, -SUM(change) AS quantity").
+		Where("order_id = ? AND reason = ?", orderID, models.StockMovementOrderAccepted).
+		Group("ingredient_id").
+		Scan(&consumed).Error; err != nil {
+		return err
+	}
+
+	orderIDInt := int(orderID)
+	ingredientIDs := make([]uint, 0, len(consumed))
+	for _, usage := range consumed {
+		ingredientIDs = append(ingredientIDs, usage.IngredientID)
+		if err := tx.Model(&models.Ingredient{}).Where("id = ?", usage.IngredientID).
+			UpdateColumn("stock_quantity", gorm.Expr("stock_quantity + ?", usage.Quantity)).Error; err != nil {
+			return err
+		}
+		movement := models.StockMovement{
+			IngredientID: usage.IngredientID,
+			Change:       usage.Quantity,
+			Reason:       reason,
+			OrderID:      &orderIDInt,
+			UserID:       userID,
+		}
+		if err := tx.Create(&movement).Error; err != nil {
+			return err
+		}
+	}
+
+	return RefreshMenuAvailability(tx, ingredientIDs)
+}
+
+// missingIngredientCondition matches menu items with an ingredient whose stock is below what a
+// single portion needs.
+const missingIngredientCondition = `EXISTS (
+	SELECT 1 FROM recipe_items
+	JOIN ingredients ON ingredients.id = recipe_items.ingredient_id AND ingredients.deleted_at IS NULL
+	WHERE recipe_items.menu_item_id = menu_items.id
+	AND ingredients.stock_quantity < recipe_items.quantity_per_portion)`
+
+// RefreshMenuAvailability makes the menu items using one of the given ingredients unavailable when
+// one of their ingredients runs out, and makes the items it disabled available again once all
+// their ingredients are back in stock. Items disabled by hand are left alone.
+func RefreshMenuAvailability(tx *gorm.DB, ingredientIDs []uint) error {
+	if len(ingredientIDs) == 0 {
+		return nil
+	}
+	return refreshMenuAvailability(tx,
+		"menu_items.id IN (SELECT menu_item_id FROM recipe_items WHERE ingredient_id IN ?)", ingredientIDs)
+}
+
+// RefreshMenuItemAvailability is RefreshMenuAvailability for a single menu item, whose recipe
+// has changed.
+func RefreshMenuItemAvailability(tx *gorm.DB, menuItemID uint) error {
+	return refreshMenuAvailability(tx, "menu_items.id = ?", menuItemID)
+}
+
+func refreshMenuAvailability(tx *gorm.DB, scope string, args ...any) error {
+	if err := tx.Model(&models.MenuItem{}).
+		Where(scope, args...).
+		Where("available = ?", true).
+		Where(missingIngredientCondition).
+		UpdateColumns(map[string]interface{}{"available": false, "auto_unavailable": true}).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.MenuItem{}).
+		Where(scope, args...).
+		Where("auto_unavailable = ?", true).
+		Where("NOT " + missingIngredientCondition).
+		UpdateColumns(map[string]interface{}{"available": true, "auto_unavailable": false}).Error
+}
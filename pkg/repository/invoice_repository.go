@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"yom-kitchen/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+type InvoiceRepository interface {
+	// Issue assigns the next invoice number of the fiscal year to an order, or returns the
+	// invoice the order already has. It must run in the transaction that marks the order as
+	// delivered: the sequence row stays locked until that transaction ends, and rolling it back
+	// also gives the number back, which keeps the numbering free of gaps.
+	Issue(ctx context.Context, orderID uint, fiscalYear int, issuedAt time.Time) (models.Invoice, error)
+}
+
+type PostgresInvoiceRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresInvoiceRepository(db *gorm.DB) *PostgresInvoiceRepository {
+	return &PostgresInvoiceRepository{db: db}
+}
+
+func (r *PostgresInvoiceRepository) Issue(ctx context.Context, orderID uint, fiscalYear int, issuedAt time.Time) (models.Invoice, error) {
+	tx := conn(ctx, r.db)
+	var existing models.Invoice
+	if err := tx.Where("order_id = ?", orderID).First(&existing).Error; err == nil {
+		return existing, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return existing, err
+	}
+
+	var number int
+	if err := tx.Raw(`INSERT INTO invoice_sequences (fiscal_year, last_number) VALUES (?, 1)
+		ON CONFLICT (fiscal_year) DO UPDATE SET last_number = invoice_sequences.last_number + 1
+		RETURNING last_number`, fiscalYear).Scan(&number).Error; err != nil {
+		return models.Invoice{}, err
+	}
+
+	invoice := models.Invoice{
+		OrderID:       int(orderID),
+		FiscalYear:    fiscalYear,
+		Number:        number,
+		InvoiceNumber: fmt.Sprintf("%d-%06d", fiscalYear, number),
+		IssuedAt:      issuedAt,
+	}
+	if err := tx.Create(&invoice).Error; err != nil {
+		return models.Invoice{}, err
+	}
+	return invoice, nil
+}
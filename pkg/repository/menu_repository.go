@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+
+	"yom-kitchen/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// MenuFilter narrows a menu list. Empty fields match every item.
+type MenuFilter struct {
+	Category  string
+	Available *bool
+}
+
+func (f MenuFilter) Scope(tx *gorm.DB) *gorm.DB {
+	if f.Category != "" {
+		tx = tx.Where("category = ?", f.Category)
+	}
+	if f.Available != nil {
+		tx = tx.Where("available = ?", *f.Available)
+	}
+	return tx
+}
+
+type MenuRepository interface {
+	Get(ctx context.Context, id uint) (models.MenuItem, error)
+	FindByNameAndCategory(ctx context.Context, name, category string) (models.MenuItem, error)
+	List(ctx context.Context, filter MenuFilter, page Page) ([]models.MenuItem, int64, error)
+	ListAvailable(ctx context.Context) ([]models.MenuItem, error)
+	Create(ctx context.Context, item *models.MenuItem) error
+	// Update saves the non-zero fields of changes.
+	Update(ctx context.Context, id uint, changes models.MenuItem) error
+	// SetAvailable makes an item available or not by hand, which stops stock levels from changing
+	// its availability until it is made available again.
+	SetAvailable(ctx context.Context, id uint, available bool) error
+	Delete(ctx context.Context, id uint) error
+	// CategoryTaxRates returns the tax rate of each category that has one.
+	CategoryTaxRates(ctx context.Context) (map[string]float64, error)
+}
+
+type PostgresMenuRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresMenuRepository(db *gorm.DB) *PostgresMenuRepository {
+	return &PostgresMenuRepository{db: db}
+}
+
+func (r *PostgresMenuRepository) Get(ctx context.Context, id uint) (models.MenuItem, error) {
+	var item models.MenuItem
+	err := conn(ctx, r.db).First(&item, id).Error
+	return item, translate(err)
+}
+
+func (r *PostgresMenuRepository) FindByNameAndCategory(ctx context.Context, name, category string) (models.MenuItem, error) {
+	var item models.MenuItem
+	err := conn(ctx, r.db).Where("name = ? AND category = ?", name, category).First(&item).Error
+	return item, translate(err)
+}
+
+func (r *PostgresMenuRepository) List(ctx context.Context, filter MenuFilter, page Page) ([]models.MenuItem, int64, error) {
+	db := conn(ctx, r.db)
+	var total int64
+	if err := db.Model(&models.MenuItem{}).Scopes(filter.Scope).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	items := []models.MenuItem{}
+	if err := db.Scopes(filter.Scope, page.Scope).Find(&items).Error; err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+func (r *PostgresMenuRepository) ListAvailable(ctx context.Context) ([]models.MenuItem, error) {
+	items := []models.MenuItem{}
+	err := conn(ctx, r.db).Where("available = ?", true).Find(&items).Error
+	return items, err
+}
+
+func (r *PostgresMenuRepository) Create(ctx context.Context, item *models.MenuItem) error {
+	return conn(ctx, r.db).Create(item).Error
+}
+
+func (r *PostgresMenuRepository) Update(ctx context.Context, id uint, changes models.MenuItem) error {
+	return affected(conn(ctx, r.db).Model(&models.MenuItem{}).Where("id = ?", id).Updates(changes))
+}
+
+func (r *PostgresMenuRepository) SetAvailable(ctx context.Context, id uint, available bool) error {
+	return affected(conn(ctx, r.db).Model(&models.MenuItem{}).Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{"available": available, "auto_unavailable": false}))
+}
+
+func (r *PostgresMenuRepository) Delete(ctx context.Context, id uint) error {
+	return affected(conn(ctx, r.db).Delete(&models.MenuItem{}, id))
+}
+
+func (r *PostgresMenuRepository) CategoryTaxRates(ctx context.Context) (map[string]float64, error) {
+	var rates []models.CategoryTaxRate
+	if err := conn(ctx, r.db).Find(&rates).Error; err != nil {
+		return nil, err
+	}
+	byCategory := make(map[string]float64, len(rates))
+	for _, rate := range rates {
+		byCategory[rate.Category] = rate.Rate
+	}
+	return byCategory, nil
+}
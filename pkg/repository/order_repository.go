@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"yom-kitchen/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OrderFilter narrows an order list. Empty fields match every order; To is exclusive. Its scope
+// qualifies the columns, so that it can be used on queries joining orders with other tables.
+type OrderFilter struct {
+	Status   string
+	ClientID uint
+	From     time.Time
+	To       time.Time
+}
+
+func (f OrderFilter) Scope(tx *gorm.DB) *gorm.DB {
+	if f.Status != "" {
+		tx = tx.Where("orders.status = ?", f.Status)
+	}
+	if f.ClientID != 0 {
+		tx = tx.Where("orders.client_id = ?", f.ClientID)
+	}
+	if !f.From.IsZero() {
+		tx = tx.Where("orders.order_date >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		tx = tx.Where("orders.order_date < ?", f.To)
+	}
+	return tx
+}
+
+type OrderRepository interface {
+	// Get returns an order with its client, items and tax lines.
+	Get(ctx context.Context, id uint) (models.Order, error)
+	// GetForUpdate returns an order without its associations, locking it until the transaction
+	// of ctx ends.
+	GetForUpdate(ctx context.Context, id uint) (models.Order, error)
+	List(ctx context.Context, filter OrderFilter, page Page) ([]models.Order, int64, error)
+	ListByClient(ctx context.Context, clientID uint) ([]models.Order, error)
+	// Create saves an order together with its items and tax lines.
+	Create(ctx context.Context, order *models.Order) error
+	SetStatus(ctx context.Context, id uint, status string) error
+	Delete(ctx context.Context, id uint) error
+	AddStatusEvent(ctx context.Context, event *models.OrderStatusEvent) error
+	// StatusHistory returns the status changes of an order, oldest first.
+	StatusHistory(ctx context.Context, orderID uint) ([]models.OrderStatusEvent, error)
+}
+
+type PostgresOrderRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresOrderRepository(db *gorm.DB) *PostgresOrderRepository {
+	return &PostgresOrderRepository{db: db}
+}
+
+func withOrderDetails(tx *gorm.DB) *gorm.DB {
+	return tx.Preload("Client").Preload("OrderItems").Preload("TaxLines")
+}
+
+func (r *PostgresOrderRepository) Get(ctx context.Context, id uint) (models.Order, error) {
+	var order models.Order
+	err := conn(ctx, r.db).Scopes(withOrderDetails).First(&order, id).Error
+	return order, translate(err)
+}
+
+func (r *PostgresOrderRepository) GetForUpdate(ctx context.Context, id uint) (models.Order, error) {
+	var order models.Order
+	err := conn(ctx, r.db).Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, id).Error
+	return order, translate(err)
+}
+
+func (r *PostgresOrderRepository) List(ctx context.Context, filter OrderFilter, page Page) ([]models.Order, int64, error) {
+	db := conn(ctx, r.db)
+	var total int64
+	if err := db.Model(&models.Order{}).Scopes(filter.Scope).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	orders := []models.Order{}
+	if err := db.Scopes(filter.Scope, page.Scope, withOrderDetails).Find(&orders).Error; err != nil {
+		return nil, 0, err
+	}
+	return orders, total, nil
+}
+
+func (r *PostgresOrderRepository) ListByClient(ctx context.Context, clientID uint) ([]models.Order, error) {
+	orders := []models.Order{}
+	err := conn(ctx, r.db).Scopes(withOrderDetails).Where("client_id = ?", clientID).Find(&orders).Error
+	return orders, err
+}
+
+func (r *PostgresOrderRepository) Create(ctx context.Context, order *models.Order) error {
+	return conn(ctx, r.db).Create(order).Error
+}
+
+func (r *PostgresOrderRepository) SetStatus(ctx context.Context, id uint, status string) error {
+	return affected(conn(ctx, r.db).Model(&models.Order{}).Where("id = ?", id).UpdateColumn("status", status))
+}
+
+func (r *PostgresOrderRepository) Delete(ctx context.Context, id uint) error {
+	return affected(conn(ctx, r.db).Delete(&models.Order{}, id))
+}
+
+func (r *PostgresOrderRepository) AddStatusEvent(ctx context.Context, event *models.OrderStatusEvent) error {
+	return conn(ctx, r.db).Create(event).Error
+}
+
+func (r *PostgresOrderRepository) StatusHistory(ctx context.Context, orderID uint) ([]models.OrderStatusEvent, error) {
+	events := []models.OrderStatusEvent{}
+	err := conn(ctx, r.db).Where("order_id = ?", orderID).Order("created_at, id").Find(&events).Error
+	return events, err
+}
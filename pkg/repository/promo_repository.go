@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"yom-kitchen/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PromoRepository interface {
+	// GetByCodeForUpdate returns the promo with the code, with its menu items and categories,
+	// locking it until the transaction of ctx ends.
+	GetByCodeForUpdate(ctx context.Context, code string) (models.Promo, error)
+	CountClientRedemptions(ctx context.Context, promoID, clientID uint) (int64, error)
+	// Redeem records a redemption and counts it against the promo.
+	Redeem(ctx context.Context, redemption *models.PromoRedemption) error
+	// ReleaseOrder removes the redemption of an order, if it has one, and stops counting it
+	// against the promo.
+	ReleaseOrder(ctx context.Context, orderID uint) error
+}
+
+type PostgresPromoRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresPromoRepository(db *gorm.DB) *PostgresPromoRepository {
+	return &PostgresPromoRepository{db: db}
+}
+
+func (r *PostgresPromoRepository) GetByCodeForUpdate(ctx context.Context, code string) (models.Promo, error) {
+	db := conn(ctx, r.db)
+	var promo models.Promo
+	if err := db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("code = ?", code).First(&promo).Error; err != nil {
+		return promo, translate(err)
+	}
+	if err := db.Model(&promo).Association("MenuItems").Find(&promo.MenuItems); err != nil {
+		return promo, err
+	}
+	err := db.Where("promo_id = ?", promo.ID).Find(&promo.Categories).Error
+	return promo, err
+}
+
+func (r *PostgresPromoRepository) CountClientRedemptions(ctx context.Context, promoID, clientID uint) (int64, error) {
+	var count int64
+	err := conn(ctx, r.db).Model(&models.PromoRedemption{}).
+		Where("promo_id = ? AND client_id = ?", promoID, clientID).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *PostgresPromoRepository) Redeem(ctx context.Context, redemption *models.PromoRedemption) error {
+	db := conn(ctx, r.db)
+	if err := db.Create(redemption).Error; err != nil {
+		return err
+	}
+	return db.Model(&models.Promo{}).Where("id = ?", redemption.PromoID).
+		UpdateColumn("redemption_count", gorm.Expr("redemption_count + 1")).Error
+}
+
+func (r *PostgresPromoRepository) ReleaseOrder(ctx context.Context, orderID uint) error {
+	db := conn(ctx, r.db)
+	var redemption models.PromoRedemption
+	if err := db.Where("order_id = ?", orderID).First(&redemption).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := db.Delete(&redemption).Error; err != nil {
+		return err
+	}
+	return db.Model(&models.Promo{}).Where("id = ?", redemption.PromoID).
+		UpdateColumn("redemption_count", gorm.Expr("GREATEST(redemption_count - 1, 0)")).Error
+}
@@ -0,0 +1,91 @@
+// Package repository defines how the services load and save entities, with implementations
+// backed by Postgres through gorm. Repositories called with the context of a transaction started
+// by a Transactor take part in that transaction.
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned when the entity looked up or changed does not exist.
+var ErrNotFound = errors.New("record not found")
+
+// Transactor runs functions in a database transaction.
+type Transactor interface {
+	// Transaction runs fn in a transaction, which is committed if fn returns nil and rolled back
+	// otherwise. Repositories must be called with the context passed to fn to take part in the
+	// transaction. Calls nested in fn join the outer transaction.
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type txKey struct{}
+
+type PostgresTransactor struct {
+	db *gorm.DB
+}
+
+func NewPostgresTransactor(db *gorm.DB) *PostgresTransactor {
+	return &PostgresTransactor{db: db}
+}
+
+func (t *PostgresTransactor) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return fn(ctx)
+	}
+	return t.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// conn returns the transaction of ctx, if any, or db.
+func conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx
+	}
+	return db.WithContext(ctx)
+}
+
+// translate replaces the errors of gorm that callers act on with the errors of this package.
+func translate(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// affected returns the error of a change, or ErrNotFound when it matched no row.
+func affected(result *gorm.DB) error {
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Page selects one page of a list sorted on a column. The primary key is used as a tie breaker so
+// that pages stay stable when the sort column has duplicates. Callers must check SortColumn
+// against the columns they allow.
+type Page struct {
+	Number     int
+	Size       int
+	SortColumn string
+	SortDesc   bool
+}
+
+// Scope is a gorm scope applying the sort order and the page window.
+func (p Page) Scope(tx *gorm.DB) *gorm.DB {
+	direction := "ASC"
+	if p.SortDesc {
+		direction = "DESC"
+	}
+	tx = tx.Order(p.SortColumn + " " + direction)
+	if p.SortColumn != "id" {
+		tx = tx.Order("id " + direction)
+	}
+	return tx.Offset((p.Number - 1) * p.Size).Limit(p.Size)
+}
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"yom-kitchen/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+type UserRepository interface {
+	// Get returns a user with their roles.
+	Get(ctx context.Context, id uint) (models.User, error)
+	// GetIncludingDeleted is Get for users that may have been soft deleted.
+	GetIncludingDeleted(ctx context.Context, id uint) (models.User, error)
+	// UsernameTaken reports whether a user other than exceptID, which may be zero, has the
+	// username. Deleted users keep their username.
+	UsernameTaken(ctx context.Context, username string, exceptID uint) (bool, error)
+	List(ctx context.Context, page Page) ([]models.User, int64, error)
+	// Create saves a user together with their roles.
+	Create(ctx context.Context, user *models.User) error
+	Update(ctx context.Context, id uint, changes map[string]any) error
+	// Delete removes a user for good, together with their sessions and role assignments.
+	Delete(ctx context.Context, id uint) error
+	// RevokeSessions revokes the active sessions of a user except exceptSessionID, if not zero,
+	// and returns how many it revoked.
+	RevokeSessions(ctx context.Context, userID, exceptSessionID uint) (int64, error)
+	// Permissions returns the permissions granted by the roles of a user.
+	Permissions(ctx context.Context, userID uint) ([]string, error)
+	// RolesByID returns the roles with the given IDs that exist.
+	RolesByID(ctx context.Context, ids []uint) ([]models.Role, error)
+}
+
+type PostgresUserRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresUserRepository(db *gorm.DB) *PostgresUserRepository {
+	return &PostgresUserRepository{db: db}
+}
+
+func (r *PostgresUserRepository) Get(ctx context.Context, id uint) (models.User, error) {
+	var user models.User
+	err := conn(ctx, r.db).Preload("Roles").First(&user, id).Error
+	return user, translate(err)
+}
+
+func (r *PostgresUserRepository) GetIncludingDeleted(ctx context.Context, id uint) (models.User, error) {
+	var user models.User
+	err := conn(ctx, r.db).Unscoped().Preload("Roles").First(&user, id).Error
+	return user, translate(err)
+}
+
+func (r *PostgresUserRepository) UsernameTaken(ctx context.Context, username string, exceptID uint) (bool, error) {
+	var count int64
+	err := conn(ctx, r.db).Unscoped().Model(&models.User{}).
+		Where("username = ? AND id <> ?", username, exceptID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *PostgresUserRepository) List(ctx context.Context, page Page) ([]models.User, int64, error) {
+	db := conn(ctx, r.db)
+	var total int64
+	if err := db.Model(&models.User{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	users := []models.User{}
+	if err := db.Preload("Roles").Scopes(page.Scope).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (r *PostgresUserRepository) Create(ctx context.Context, user *models.User) error {
+	return conn(ctx, r.db).Create(user).Error
+}
+
+func (r *PostgresUserRepository) Update(ctx context.Context, id uint, changes map[string]any) error {
+	return affected(conn(ctx, r.db).Model(&models.User{}).Where("id = ?", id).Updates(changes))
+}
+
+func (r *PostgresUserRepository) Delete(ctx context.Context, id uint) error {
+	db := conn(ctx, r.db)
+	if err := db.Where("user_id = ?", id).Delete(&models.Session{}).Error; err != nil {
+		return err
+	}
+	user := models.User{}
+	user.ID = id
+	if err := db.Model(&user).Association("Roles").Clear(); err != nil {
+		return err
+	}
+	return affected(db.Unscoped().Delete(&models.User{}, id))
+}
+
+func (r *PostgresUserRepository) RevokeSessions(ctx context.Context, userID, exceptSessionID uint) (int64, error) {
+	query := conn(ctx, r.db).Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL", userID)
+	if exceptSessionID != 0 {
+		query = query.Where("id <> ?", exceptSessionID)
+	}
+	result := query.UpdateColumn("revoked_at", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+func (r *PostgresUserRepository) Permissions(ctx context.Context, userID uint) ([]string, error) {
+	var permissions []string
+	err := conn(ctx, r.db).Table("role_permissions").
+		Distinct("role_permissions.permission").
+		Joins("JOIN user_roles ON user_roles.role_id = role_permissions.role_id").
+		Joins("JOIN roles ON roles.id = role_permissions.role_id AND roles.deleted_at IS NULL").
+		Where("user_roles.user_id = ?", userID).
+		Pluck("role_permissions.permission", &permissions).Error
+	return permissions, err
+}
+
+func (r *PostgresUserRepository) RolesByID(ctx context.Context, ids []uint) ([]models.Role, error) {
+	roles := []models.Role{}
+	if len(ids) == 0 {
+		return roles, nil
+	}
+	err := conn(ctx, r.db).Where("id IN ?", ids).Find(&roles).Error
+	return roles, err
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"yom-kitchen/pkg/audit"
+	"yom-kitchen/pkg/models"
+	"yom-kitchen/pkg/repository"
+)
+
+type ClientService struct {
+	repos Repositories
+}
+
+func (s *ClientService) Get(ctx context.Context, id uint) (models.Client, error) {
+	client, err := s.repos.Clients.Get(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return client, ErrClientNotFound
+	}
+	return client, err
+}
+
+func (s *ClientService) List(ctx context.Context, filter repository.ClientFilter, page repository.Page) ([]models.Client, int64, error) {
+	return s.repos.Clients.List(ctx, filter, page)
+}
+
+// Create saves a new client, whose name and email must not be used by another client. The
+// passcode of the client is generated when it is saved.
+func (s *ClientService) Create(ctx context.Context, actor audit.Actor, client *models.Client) error {
+	_, err := s.repos.Clients.FindByNameOrEmail(ctx, client.Name, client.Email)
+	if err == nil {
+		return ErrClientExists
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	if err := s.repos.Clients.Create(ctx, client); err != nil {
+		return err
+	}
+	RecordAudit(ctx, s.repos.Audit, actor, models.AuditActionCreate, "client", client.ID, nil, *client)
+	return nil
+}
+
+// Update saves the non-zero fields of changes.
+func (s *ClientService) Update(ctx context.Context, actor audit.Actor, id uint, changes models.Client) (models.Client, error) {
+	return s.update(ctx, actor, id, func(ctx context.Context) error {
+		return s.repos.Clients.Update(ctx, id, changes)
+	})
+}
+
+func (s *ClientService) SetActive(ctx context.Context, actor audit.Actor, id uint, active bool) (models.Client, error) {
+	return s.update(ctx, actor, id, func(ctx context.Context) error {
+		return s.repos.Clients.SetActive(ctx, id, active)
+	})
+}
+
+func (s *ClientService) update(ctx context.Context, actor audit.Actor, id uint, change func(ctx context.Context) error) (models.Client, error) {
+	before, err := s.repos.Clients.Get(ctx, id)
+	if err == nil {
+		err = change(ctx)
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.Client{}, ErrClientNotFound
+	} else if err != nil {
+		return models.Client{}, err
+	}
+
+	updated, err := s.repos.Clients.Get(ctx, id)
+	if err != nil {
+		return models.Client{}, err
+	}
+	RecordAudit(ctx, s.repos.Audit, actor, models.AuditActionUpdate, "client", id, before, updated)
+	return updated, nil
+}
+
+func (s *ClientService) Delete(ctx context.Context, actor audit.Actor, id uint) error {
+	client, err := s.repos.Clients.Get(ctx, id)
+	if err == nil {
+		err = s.repos.Clients.Delete(ctx, id)
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrClientNotFound
+	} else if err != nil {
+		return err
+	}
+	RecordAudit(ctx, s.repos.Audit, actor, models.AuditActionDelete, "client", id, client, nil)
+	return nil
+}
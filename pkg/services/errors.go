@@ -0,0 +1,65 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+)
+
+var (
+	ErrOrderNotFound    = errors.New("order not found")
+	ErrMenuItemNotFound = errors.New("menu item not found")
+	ErrClientNotFound   = errors.New("client not found")
+	ErrUserNotFound     = errors.New("user not found")
+
+	ErrMenuItemExists = errors.New("menu item already exists")
+	ErrClientExists   = errors.New("client or email already exists")
+	ErrUsernameTaken  = errors.New("username already exists")
+
+	// ErrInvalidClient and ErrInvalidMenuItem are returned when a new order refers to a client or
+	// menu item that does not exist.
+	ErrInvalidClient       = errors.New("invalid client ID")
+	ErrInvalidMenuItem     = errors.New("invalid menu item ID")
+	ErrMenuItemUnavailable = errors.New("menu item not available")
+	ErrInvalidOrderStatus  = errors.New("invalid order status")
+	ErrDiscountWithPromo   = errors.New("an order cannot have both a discount and a promo code")
+)
+
+// StatusTransitionError is returned when an order cannot move from its current status to the
+// requested one.
+type StatusTransitionError struct {
+	From    string
+	To      string
+	Allowed []string
+}
+
+func (e *StatusTransitionError) Error() string {
+	return "cannot change order status from " + e.From + " to " + e.To
+}
+
+// PromoError is returned when a promo code cannot be applied to an order; its message is safe to
+// show to the client.
+type PromoError struct {
+	Message string
+}
+
+func (e *PromoError) Error() string {
+	return e.Message
+}
+
+// PermissionError is returned when the actor lacks a permission that a change requires on top of
+// the ones needed to make changes of its kind.
+type PermissionError struct {
+	Permission string
+}
+
+func (e *PermissionError) Error() string {
+	return e.Permission + " permission required"
+}
+
+type InvalidRoleError struct {
+	RoleID uint
+}
+
+func (e *InvalidRoleError) Error() string {
+	return "invalid role ID: " + strconv.Itoa(int(e.RoleID))
+}
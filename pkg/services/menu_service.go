@@ -0,0 +1,195 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"yom-kitchen/pkg/audit"
+	"yom-kitchen/pkg/imaging"
+	"yom-kitchen/pkg/models"
+	"yom-kitchen/pkg/repository"
+	"yom-kitchen/pkg/storage"
+)
+
+type MenuService struct {
+	repos  Repositories
+	images storage.ImageStore
+}
+
+func (s *MenuService) Get(ctx context.Context, id uint) (models.MenuItem, error) {
+	item, err := s.repos.Menu.Get(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return item, ErrMenuItemNotFound
+	}
+	return item, err
+}
+
+func (s *MenuService) List(ctx context.Context, filter repository.MenuFilter, page repository.Page) ([]models.MenuItem, int64, error) {
+	return s.repos.Menu.List(ctx, filter, page)
+}
+
+func (s *MenuService) ListAvailable(ctx context.Context) ([]models.MenuItem, error) {
+	return s.repos.Menu.ListAvailable(ctx)
+}
+
+// Create saves a new menu item, whose name must be unique within its category. The image of the
+// item, if any, is deleted when the item cannot be saved.
+func (s *MenuService) Create(ctx context.Context, actor audit.Actor, item *models.MenuItem) error {
+	_, err := s.repos.Menu.FindByNameAndCategory(ctx, item.Name, item.Category)
+	if err == nil {
+		err = ErrMenuItemExists
+	} else if errors.Is(err, repository.ErrNotFound) {
+		err = s.repos.Menu.Create(ctx, item)
+	}
+	if err != nil {
+		s.DeleteImage(ctx, *item)
+		return err
+	}
+	RecordAudit(ctx, s.repos.Audit, actor, models.AuditActionCreate, "menu_item", item.ID, nil, *item)
+	return nil
+}
+
+// Update saves the non-zero fields of changes. A new image in changes replaces the old one, which
+// is deleted; the new image is deleted instead when the item cannot be updated.
+func (s *MenuService) Update(ctx context.Context, actor audit.Actor, id uint, changes models.MenuItem) (models.MenuItem, error) {
+	before, err := s.repos.Menu.Get(ctx, id)
+	if err == nil {
+		err = s.repos.Menu.Update(ctx, id, changes)
+	}
+	if err != nil {
+		s.DeleteImage(ctx, changes)
+		if errors.Is(err, repository.ErrNotFound) {
+			return models.MenuItem{}, ErrMenuItemNotFound
+		}
+		return models.MenuItem{}, err
+	}
+	if changes.ImageKey != "" {
+		s.DeleteImage(ctx, before)
+	}
+
+	updated, err := s.repos.Menu.Get(ctx, id)
+	if err != nil {
+		return models.MenuItem{}, err
+	}
+	RecordAudit(ctx, s.repos.Audit, actor, models.AuditActionUpdate, "menu_item", id, before, updated)
+	return updated, nil
+}
+
+// ToggleAvailable makes an available item unavailable and the other way round.
+func (s *MenuService) ToggleAvailable(ctx context.Context, actor audit.Actor, id uint) (models.MenuItem, error) {
+	before, err := s.repos.Menu.Get(ctx, id)
+	if err == nil {
+		err = s.repos.Menu.SetAvailable(ctx, id, !before.Available)
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.MenuItem{}, ErrMenuItemNotFound
+	} else if err != nil {
+		return models.MenuItem{}, err
+	}
+
+	updated, err := s.repos.Menu.Get(ctx, id)
+	if err != nil {
+		return models.MenuItem{}, err
+	}
+	RecordAudit(ctx, s.repos.Audit, actor, models.AuditActionUpdate, "menu_item", id, before, updated)
+	return updated, nil
+}
+
+func (s *MenuService) Delete(ctx context.Context, actor audit.Actor, id uint) error {
+	item, err := s.repos.Menu.Get(ctx, id)
+	if err == nil {
+		err = s.repos.Menu.Delete(ctx, id)
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrMenuItemNotFound
+	} else if err != nil {
+		return err
+	}
+	s.DeleteImage(ctx, item)
+	RecordAudit(ctx, s.repos.Audit, actor, models.AuditActionDelete, "menu_item", id, item, nil)
+	return nil
+}
+
+// SaveImage stores the variants of a processed image and sets the image keys of item.
+func (s *MenuService) SaveImage(ctx context.Context, item *models.MenuItem, images []imaging.Image) error {
+	if s.images == nil {
+		return errors.New("image store not available")
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	prefix := fmt.Sprintf("menu/%d-%s", time.Now().UnixNano(), hex.EncodeToString(suffix))
+
+	saved := models.MenuItem{ImageVariantKeys: make(map[string]string, len(images))}
+	for _, image := range images {
+		key := prefix + "-" + image.Variant + image.Extension
+		if err := s.images.Put(ctx, key, bytes.NewReader(image.Data), int64(len(image.Data)), image.ContentType); err != nil {
+			s.DeleteImage(ctx, saved)
+			return err
+		}
+		saved.ImageVariantKeys[image.Variant] = key
+	}
+	item.ImageVariantKeys = saved.ImageVariantKeys
+	item.ImageKey = saved.ImageVariantKeys[imaging.Variants[len(imaging.Variants)-1].Name]
+	return nil
+}
+
+// DeleteImage removes the image of an item once it is no longer used. Failures are only logged,
+// since the change that made the image unused has already been made.
+func (s *MenuService) DeleteImage(ctx context.Context, item models.MenuItem) {
+	keys := make(map[string]bool)
+	if item.ImageKey != "" {
+		keys[item.ImageKey] = true
+	}
+	for _, key := range item.ImageVariantKeys {
+		keys[key] = true
+	}
+	if len(keys) == 0 {
+		return
+	}
+	if s.images == nil {
+		log.Printf("Image store not available, not deleting image %s", item.ImageKey)
+		return
+	}
+	for key := range keys {
+		if err := s.images.Delete(ctx, key); err != nil {
+			log.Printf("Error deleting image %s: %v", key, err)
+		}
+	}
+}
+
+// SetImageURLs fills in the image URLs of menu items from their keys. Items whose image has no
+// variants get the URL of the image for every variant.
+func (s *MenuService) SetImageURLs(ctx context.Context, items ...*models.MenuItem) {
+	if s.images == nil {
+		return
+	}
+	url := func(key string) string {
+		u, err := s.images.URL(ctx, key)
+		if err != nil {
+			log.Printf("Error getting URL of image %s: %v", key, err)
+		}
+		return u
+	}
+	for _, item := range items {
+		if item.ImageKey == "" {
+			continue
+		}
+		item.ImageUrl = url(item.ImageKey)
+		item.ImageUrls = make(map[string]string, len(imaging.Variants))
+		for _, variant := range imaging.Variants {
+			key, ok := item.ImageVariantKeys[variant.Name]
+			if !ok {
+				key = item.ImageKey
+			}
+			item.ImageUrls[variant.Name] = url(key)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+
+	"yom-kitchen/pkg/models"
+	"yom-kitchen/pkg/pricing"
+)
+
+// pricedLine is a menu item being ordered, together with the quantity and any line discount.
+type pricedLine struct {
+	MenuItem                 models.MenuItem
+	Quantity                 int
+	Discount                 pricing.Discount
+	ExcludeFromOrderDiscount bool
+}
+
+// price builds an order with its items and tax lines from the requested lines, snapshotting the
+// menu item names and prices and resolving each item's tax rate. The caller fills in the client,
+// date, status and notes. The pricing result is returned alongside the order.
+func (s *OrderService) price(ctx context.Context, lines []pricedLine, orderDiscount pricing.Discount) (models.Order, pricing.Result, error) {
+	ratesByCategory, err := s.repos.Menu.CategoryTaxRates(ctx)
+	if err != nil {
+		return models.Order{}, pricing.Result{}, err
+	}
+	defaultRate := s.business.DefaultTaxRate
+
+	pricingLines := make([]pricing.Line, len(lines))
+	for i, line := range lines {
+		taxRate := defaultRate
+		if line.MenuItem.TaxRate != nil {
+			taxRate = *line.MenuItem.TaxRate
+		} else if rate, ok := ratesByCategory[line.MenuItem.Category]; ok {
+			taxRate = rate
+		}
+		pricingLines[i] = pricing.Line{
+			UnitPrice: line.MenuItem.Price,
+			Quantity:  line.Quantity,
+			TaxRate:   taxRate,
+			Discount:  line.Discount,
+
+			ExcludeFromOrderDiscount: line.ExcludeFromOrderDiscount,
+		}
+	}
+
+	result, err := pricing.Calculate(pricingLines, orderDiscount, pricing.Settings{
+		ServiceChargePercent: s.business.ServiceChargePercent,
+		ServiceChargeTaxRate: defaultRate,
+	})
+	if err != nil {
+		return models.Order{}, pricing.Result{}, err
+	}
+
+	order := models.Order{
+		SubtotalAmount:      result.Subtotal,
+		DiscountAmount:      result.DiscountAmount,
+		NetAmount:           result.NetAmount,
+		ServiceChargeAmount: result.ServiceCharge,
+		TaxAmount:           result.TaxAmount,
+		TotalAmount:         result.Total,
+	}
+	for i, line := range lines {
+		order.OrderItems = append(order.OrderItems, models.OrderItem{
+			MenuItemID:     int(line.MenuItem.ID),
+			ItemName:       line.MenuItem.Name,
+			ItemPrice:      line.MenuItem.Price,
+			Quantity:       line.Quantity,
+			Subtotal:       result.Lines[i].Subtotal,
+			DiscountAmount: result.Lines[i].DiscountAmount,
+			TaxRate:        result.Lines[i].TaxRate,
+			TaxAmount:      result.Lines[i].TaxAmount,
+		})
+	}
+	for _, taxLine := range result.TaxLines {
+		order.TaxLines = append(order.TaxLines, models.OrderTaxLine{
+			Rate:          taxLine.Rate,
+			TaxableAmount: taxLine.TaxableAmount,
+			TaxAmount:     taxLine.TaxAmount,
+		})
+	}
+	return order, result, nil
+}
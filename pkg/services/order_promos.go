@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"yom-kitchen/pkg/models"
+	"yom-kitchen/pkg/money"
+	"yom-kitchen/pkg/pricing"
+	"yom-kitchen/pkg/repository"
+)
+
+// reservePromo locks the promo with the given code and checks that the client may use it on an
+// order with the given lines. The row lock is held until the order transaction ends, so
+// concurrent orders using the same promo are serialized and the usage limits cannot be exceeded.
+// On success the lines the promo does not apply to are excluded from the order discount it
+// returns.
+func (s *OrderService) reservePromo(ctx context.Context, code string, clientID uint, lines []pricedLine) (*models.Promo, pricing.Discount, error) {
+	promo, err := s.repos.Promos.GetByCodeForUpdate(ctx, models.NormalizePromoCode(code))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, pricing.Discount{}, &PromoError{"Invalid promo code"}
+	} else if err != nil {
+		return nil, pricing.Discount{}, err
+	}
+
+	now := time.Now()
+	switch {
+	case !promo.IsActive:
+		return nil, pricing.Discount{}, &PromoError{"Promo code is not active"}
+	case promo.StartsAt != nil && now.Before(*promo.StartsAt):
+		return nil, pricing.Discount{}, &PromoError{"Promo code is not valid yet"}
+	case promo.ExpiresAt != nil && !now.Before(*promo.ExpiresAt):
+		return nil, pricing.Discount{}, &PromoError{"Promo code has expired"}
+	case promo.MaxRedemptions > 0 && promo.RedemptionCount >= promo.MaxRedemptions:
+		return nil, pricing.Discount{}, &PromoError{"Promo code has been fully redeemed"}
+	}
+
+	if promo.MaxPerClient > 0 {
+		clientRedemptions, err := s.repos.Promos.CountClientRedemptions(ctx, promo.ID, clientID)
+		if err != nil {
+			return nil, pricing.Discount{}, err
+		}
+		if clientRedemptions >= int64(promo.MaxPerClient) {
+			return nil, pricing.Discount{}, &PromoError{"Promo code has already been used the maximum number of times"}
+		}
+	}
+
+	var orderSubtotal, eligibleSubtotal money.Amount
+	for i := range lines {
+		subtotal := lines[i].MenuItem.Price.Mul(lines[i].Quantity)
+		orderSubtotal += subtotal
+		if promo.AppliesTo(lines[i].MenuItem) {
+			eligibleSubtotal += subtotal
+		} else {
+			lines[i].ExcludeFromOrderDiscount = true
+		}
+	}
+	if orderSubtotal < promo.MinOrderAmount {
+		return nil, pricing.Discount{}, &PromoError{"Order total is below the promo minimum of " + promo.MinOrderAmount.String()}
+	}
+	if eligibleSubtotal == 0 {
+		return nil, pricing.Discount{}, &PromoError{"Promo code does not apply to any item in the order"}
+	}
+
+	discount := pricing.Discount{Percent: promo.Percent}
+	if promo.DiscountType == models.PromoTypeFixed {
+		discount = pricing.Discount{Amount: min(promo.Amount, eligibleSubtotal)}
+	}
+	return &promo, discount, nil
+}
@@ -0,0 +1,289 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"yom-kitchen/pkg/audit"
+	"yom-kitchen/pkg/config"
+	"yom-kitchen/pkg/events"
+	"yom-kitchen/pkg/models"
+	"yom-kitchen/pkg/pricing"
+	"yom-kitchen/pkg/repository"
+)
+
+type OrderService struct {
+	repos     Repositories
+	business  config.Business
+	publisher EventPublisher
+}
+
+// OrderLine is a menu item being ordered.
+type OrderLine struct {
+	MenuItemID uint
+	Quantity   int
+	Discount   pricing.Discount
+}
+
+// NewOrder describes an order to create. An order gets its discount either from Discount or from
+// a promo code.
+type NewOrder struct {
+	ClientID  uint
+	Lines     []OrderLine
+	Discount  pricing.Discount
+	PromoCode string
+	Notes     string
+}
+
+// Create prices and saves a pending order, snapshotting the names and prices of the menu items,
+// and notifies subscribers about it. A promo code is checked against the limits of the promo and
+// redeemed with the order.
+func (s *OrderService) Create(ctx context.Context, actor audit.Actor, request NewOrder) (models.Order, error) {
+	if request.PromoCode != "" && (request.Discount != pricing.Discount{}) {
+		return models.Order{}, ErrDiscountWithPromo
+	}
+
+	var order models.Order
+	err := s.repos.Transactor.Transaction(ctx, func(ctx context.Context) error {
+		if _, err := s.repos.Clients.Get(ctx, request.ClientID); errors.Is(err, repository.ErrNotFound) {
+			return ErrInvalidClient
+		} else if err != nil {
+			return err
+		}
+
+		lines := make([]pricedLine, 0, len(request.Lines))
+		for _, line := range request.Lines {
+			menuItem, err := s.repos.Menu.Get(ctx, line.MenuItemID)
+			if errors.Is(err, repository.ErrNotFound) {
+				return ErrInvalidMenuItem
+			} else if err != nil {
+				return err
+			}
+			if !menuItem.Available {
+				return ErrMenuItemUnavailable
+			}
+			lines = append(lines, pricedLine{MenuItem: menuItem, Quantity: line.Quantity, Discount: line.Discount})
+		}
+
+		var promo *models.Promo
+		discount := request.Discount
+		if request.PromoCode != "" {
+			var err error
+			promo, discount, err = s.reservePromo(ctx, request.PromoCode, request.ClientID, lines)
+			if err != nil {
+				return err
+			}
+		}
+
+		var priced pricing.Result
+		var err error
+		order, priced, err = s.price(ctx, lines, discount)
+		if err != nil {
+			return err
+		}
+		order.ClientID = int(request.ClientID)
+		order.OrderDate = time.Now()
+		order.Status = models.OrderStatusPending
+		order.Notes = request.Notes
+		if promo != nil {
+			order.PromoCode = promo.Code
+		}
+		if err := s.repos.Orders.Create(ctx, &order); err != nil {
+			return err
+		}
+		if promo != nil {
+			if err := s.repos.Promos.Redeem(ctx, &models.PromoRedemption{
+				PromoID:        promo.ID,
+				OrderID:        int(order.ID),
+				ClientID:       order.ClientID,
+				DiscountAmount: priced.OrderDiscountAmount,
+			}); err != nil {
+				return err
+			}
+		}
+		event := newOrderStatusEvent(actor, order.ID, "", order.Status, "")
+		if err := s.repos.Orders.AddStatusEvent(ctx, &event); err != nil {
+			return err
+		}
+		RecordAudit(ctx, s.repos.Audit, actor, models.AuditActionCreate, "order", order.ID, nil, order)
+		return nil
+	})
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	created, err := s.repos.Orders.Get(ctx, order.ID)
+	if err != nil {
+		log.Printf("Error loading order %d for event publication: %v", order.ID, err)
+		return order, nil
+	}
+	s.publish(events.OrderCreated, created)
+	return created, nil
+}
+
+func (s *OrderService) Get(ctx context.Context, id uint) (models.Order, error) {
+	order, err := s.repos.Orders.Get(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return order, ErrOrderNotFound
+	}
+	return order, err
+}
+
+func (s *OrderService) List(ctx context.Context, filter repository.OrderFilter, page repository.Page) ([]models.Order, int64, error) {
+	return s.repos.Orders.List(ctx, filter, page)
+}
+
+func (s *OrderService) ListForClient(ctx context.Context, clientID uint) ([]models.Order, error) {
+	return s.repos.Orders.ListByClient(ctx, clientID)
+}
+
+// Delete deletes an order. The ingredients of an accepted order go back into stock, as when it is
+// cancelled, and its promo redemption, if any, stops counting against the limits of the promo.
+func (s *OrderService) Delete(ctx context.Context, actor audit.Actor, id uint) error {
+	var order models.Order
+	err := s.repos.Transactor.Transaction(ctx, func(ctx context.Context) error {
+		if _, err := s.repos.Orders.GetForUpdate(ctx, id); err != nil {
+			return err
+		}
+		var err error
+		if order, err = s.repos.Orders.Get(ctx, id); err != nil {
+			return err
+		}
+		if order.Status == models.OrderStatusAccepted {
+			if err := s.repos.Inventory.RestoreOrder(ctx, order.ID, actor.UserID, models.StockMovementOrderDeleted); err != nil {
+				return err
+			}
+		}
+		if err := s.repos.Promos.ReleaseOrder(ctx, order.ID); err != nil {
+			return err
+		}
+		if err := s.repos.Orders.Delete(ctx, id); err != nil {
+			return err
+		}
+		RecordAudit(ctx, s.repos.Audit, actor, models.AuditActionDelete, "order", order.ID, order, nil)
+		return nil
+	})
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrOrderNotFound
+	} else if err != nil {
+		return err
+	}
+	s.publish(events.OrderDeleted, map[string]any{"order_id": order.ID})
+	return nil
+}
+
+// UpdateStatus moves an order to a new status along the allowed transitions and records the
+// change. Accepting an order takes its ingredients out of stock and cancelling an accepted order
+// puts them back; delivering an order issues its invoice.
+func (s *OrderService) UpdateStatus(ctx context.Context, actor audit.Actor, id uint, status, reason string) (models.Order, error) {
+	if !models.IsValidOrderStatus(status) {
+		return models.Order{}, ErrInvalidOrderStatus
+	}
+
+	var previousStatus string
+	err := s.repos.Transactor.Transaction(ctx, func(ctx context.Context) error {
+		order, err := s.repos.Orders.GetForUpdate(ctx, id)
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrOrderNotFound
+		} else if err != nil {
+			return err
+		}
+
+		if !models.CanTransitionOrderStatus(order.Status, status) {
+			return &StatusTransitionError{
+				From:    order.Status,
+				To:      status,
+				Allowed: models.AllowedOrderStatusTransitions(order.Status),
+			}
+		}
+
+		previousStatus = order.Status
+		if err := s.repos.Orders.SetStatus(ctx, order.ID, status); err != nil {
+			return err
+		}
+		event := newOrderStatusEvent(actor, order.ID, previousStatus, status, reason)
+		if err := s.repos.Orders.AddStatusEvent(ctx, &event); err != nil {
+			return err
+		}
+		RecordAudit(ctx, s.repos.Audit, actor, models.AuditActionUpdate, "order", order.ID,
+			map[string]any{"status": previousStatus}, map[string]any{"status": status})
+
+		switch {
+		case status == models.OrderStatusAccepted:
+			if err := s.repos.Inventory.ConsumeOrder(ctx, order.ID, actor.UserID); err != nil {
+				return err
+			}
+		case status == models.OrderStatusCancelled && previousStatus == models.OrderStatusAccepted:
+			if err := s.repos.Inventory.RestoreOrder(ctx, order.ID, actor.UserID, models.StockMovementOrderCancelled); err != nil {
+				return err
+			}
+		}
+
+		if status == models.OrderStatusDelivered {
+			issuedAt := time.Now()
+			fiscalYear := fiscalYearOf(issuedAt.In(s.business.Location), s.business.FiscalYearStartMonth)
+			if _, err := s.repos.Invoices.Issue(ctx, order.ID, fiscalYear, issuedAt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	updated, err := s.repos.Orders.Get(ctx, id)
+	if err != nil {
+		return models.Order{}, err
+	}
+	s.publish(events.OrderStatusChanged, map[string]any{
+		"order_id":    updated.ID,
+		"from_status": previousStatus,
+		"to_status":   updated.Status,
+		"order":       updated,
+	})
+	return updated, nil
+}
+
+// StatusHistory returns an order together with its status changes, oldest first.
+func (s *OrderService) StatusHistory(ctx context.Context, id uint) (models.Order, []models.OrderStatusEvent, error) {
+	order, err := s.Get(ctx, id)
+	if err != nil {
+		return order, nil, err
+	}
+	history, err := s.repos.Orders.StatusHistory(ctx, id)
+	return order, history, err
+}
+
+func (s *OrderService) publish(eventType string, data any) {
+	if s.publisher != nil {
+		s.publisher.Publish(eventType, data)
+	}
+}
+
+// newOrderStatusEvent builds the history entry for an order status change, attributing it to the
+// actor when they are a staff user.
+func newOrderStatusEvent(actor audit.Actor, orderID uint, from, to, reason string) models.OrderStatusEvent {
+	event := models.OrderStatusEvent{
+		OrderID:    int(orderID),
+		FromStatus: from,
+		ToStatus:   to,
+		Reason:     reason,
+	}
+	if actor.UserID != nil {
+		event.ChangedByID = actor.UserID
+		event.ChangedByName = actor.Username
+	}
+	return event
+}
+
+// fiscalYearOf returns the fiscal year t falls in, named after the calendar year in which it
+// starts. The fiscal year starts on the first day of startMonth.
+func fiscalYearOf(t time.Time, startMonth int) int {
+	if int(t.Month()) < startMonth {
+		return t.Year() - 1
+	}
+	return t.Year()
+}
@@ -0,0 +1,69 @@
+// Package services holds the business rules for orders, the menu, clients and staff users. The
+// services know nothing about HTTP: they take an audit.Actor for the caller, load and save
+// entities through the repository interfaces and report failures with the errors of this package,
+// so that they can be used by any transport and tested against in-memory repositories.
+package services
+
+import (
+	"context"
+	"log"
+
+	"yom-kitchen/pkg/audit"
+	"yom-kitchen/pkg/config"
+	"yom-kitchen/pkg/events"
+	"yom-kitchen/pkg/repository"
+	"yom-kitchen/pkg/storage"
+)
+
+// EventPublisher notifies subscribers, such as kitchen displays, about changes. *events.Bus
+// implements it.
+type EventPublisher interface {
+	Publish(eventType string, data any) events.Event
+}
+
+// Repositories are the repositories the services work with.
+type Repositories struct {
+	Transactor repository.Transactor
+	Orders     repository.OrderRepository
+	Menu       repository.MenuRepository
+	Clients    repository.ClientRepository
+	Users      repository.UserRepository
+	Promos     repository.PromoRepository
+	Inventory  repository.InventoryRepository
+	Invoices   repository.InvoiceRepository
+	Audit      repository.AuditRepository
+}
+
+type Services struct {
+	Orders  *OrderService
+	Menu    *MenuService
+	Clients *ClientService
+	Users   *UserService
+}
+
+func New(repos Repositories, business config.Business, images storage.ImageStore, publisher EventPublisher) *Services {
+	return &Services{
+		Orders:  &OrderService{repos: repos, business: business, publisher: publisher},
+		Menu:    &MenuService{repos: repos, images: images},
+		Clients: &ClientService{repos: repos},
+		Users:   &UserService{repos: repos},
+	}
+}
+
+// RecordAudit appends an entry to the audit log for a change made by actor. Call it with the
+// context of the transaction making the change when there is one, so that the entry is only kept
+// if the change is. Failures are logged rather than failing the change. The handlers that still
+// make changes themselves record them through it too.
+func RecordAudit(ctx context.Context, repo repository.AuditRepository, actor audit.Actor, action, entityType string, entityID any, before, after any) {
+	entry, ok, err := audit.NewEntry(actor, action, entityType, entityID, before, after)
+	if err != nil {
+		log.Printf("Error recording audit log for %s %v: %v", entityType, entityID, err)
+		return
+	}
+	if !ok {
+		return
+	}
+	if err := repo.Record(ctx, &entry); err != nil {
+		log.Printf("Error recording audit log for %s %v: %v", entityType, entityID, err)
+	}
+}
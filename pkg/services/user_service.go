@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"yom-kitchen/pkg/audit"
+	"yom-kitchen/pkg/models"
+	"yom-kitchen/pkg/repository"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type UserService struct {
+	repos Repositories
+}
+
+// NewUser describes a staff user to create. Making a user an admin requires the actor to have
+// every permission, and assigning roles requires the permission to manage roles.
+type NewUser struct {
+	Username string
+	Password string
+	IsAdmin  bool
+	RoleIDs  []uint
+}
+
+// UserChanges lists the changes to a user; nil fields are left alone. Changing whether a user is
+// an admin requires the actor to have every permission.
+type UserChanges struct {
+	Username *string
+	Password *string
+	IsAdmin  *bool
+}
+
+func (s *UserService) Get(ctx context.Context, id uint) (models.User, error) {
+	user, err := s.repos.Users.Get(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return user, ErrUserNotFound
+	}
+	return user, err
+}
+
+func (s *UserService) List(ctx context.Context, page repository.Page) ([]models.User, int64, error) {
+	return s.repos.Users.List(ctx, page)
+}
+
+func (s *UserService) Create(ctx context.Context, actor audit.Actor, request NewUser) (models.User, error) {
+	if request.IsAdmin {
+		if err := s.requirePermission(ctx, actor, models.PermissionAll); err != nil {
+			return models.User{}, err
+		}
+	}
+	if len(request.RoleIDs) > 0 {
+		if err := s.requirePermission(ctx, actor, models.PermissionRolesManage); err != nil {
+			return models.User{}, err
+		}
+	}
+	roles, err := s.repos.Users.RolesByID(ctx, request.RoleIDs)
+	if err != nil {
+		return models.User{}, err
+	}
+	for _, roleID := range request.RoleIDs {
+		if !slices.ContainsFunc(roles, func(role models.Role) bool { return role.ID == roleID }) {
+			return models.User{}, &InvalidRoleError{RoleID: roleID}
+		}
+	}
+
+	if taken, err := s.repos.Users.UsernameTaken(ctx, request.Username, 0); err != nil {
+		return models.User{}, err
+	} else if taken {
+		return models.User{}, ErrUsernameTaken
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user := models.User{
+		Username:     request.Username,
+		PasswordHash: string(hashedPassword),
+		IsAdmin:      request.IsAdmin,
+		Roles:        roles,
+	}
+	if err := s.repos.Users.Create(ctx, &user); err != nil {
+		return models.User{}, err
+	}
+	RecordAudit(ctx, s.repos.Audit, actor, models.AuditActionCreate, "user", user.ID, nil, user)
+	return user, nil
+}
+
+// Update applies changes to a user. A new password signs the user out everywhere except from
+// keepSessionID, if not zero, which is meant for the session making the change.
+func (s *UserService) Update(ctx context.Context, actor audit.Actor, id uint, changes UserChanges, keepSessionID uint) (models.User, error) {
+	before, err := s.Get(ctx, id)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	updates := make(map[string]any)
+	if changes.Username != nil {
+		if taken, err := s.repos.Users.UsernameTaken(ctx, *changes.Username, id); err != nil {
+			return models.User{}, err
+		} else if taken {
+			return models.User{}, ErrUsernameTaken
+		}
+		updates["username"] = *changes.Username
+	}
+	if changes.Password != nil {
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(*changes.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return models.User{}, err
+		}
+		updates["password_hash"] = string(hashedPassword)
+	}
+	if changes.IsAdmin != nil {
+		if *changes.IsAdmin != before.IsAdmin {
+			if err := s.requirePermission(ctx, actor, models.PermissionAll); err != nil {
+				return models.User{}, err
+			}
+		}
+		updates["is_admin"] = *changes.IsAdmin
+	}
+
+	if len(updates) > 0 {
+		if err := s.repos.Users.Update(ctx, id, updates); errors.Is(err, repository.ErrNotFound) {
+			return models.User{}, ErrUserNotFound
+		} else if err != nil {
+			return models.User{}, err
+		}
+	}
+	if changes.Password != nil {
+		if _, err := s.repos.Users.RevokeSessions(ctx, id, keepSessionID); err != nil {
+			return models.User{}, err
+		}
+	}
+
+	updated, err := s.Get(ctx, id)
+	if err != nil {
+		return models.User{}, err
+	}
+	RecordAudit(ctx, s.repos.Audit, actor, models.AuditActionUpdate, "user", id, before, updated)
+	return updated, nil
+}
+
+// Delete removes a user for good, signing them out.
+func (s *UserService) Delete(ctx context.Context, actor audit.Actor, id uint) error {
+	user, err := s.repos.Users.GetIncludingDeleted(ctx, id)
+	if err == nil {
+		err = s.repos.Users.Delete(ctx, id)
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrUserNotFound
+	} else if err != nil {
+		return err
+	}
+	RecordAudit(ctx, s.repos.Audit, actor, models.AuditActionDelete, "user", id, user, nil)
+	return nil
+}
+
+func (s *UserService) requirePermission(ctx context.Context, actor audit.Actor, permission string) error {
+	if actor.UserID == nil {
+		return &PermissionError{Permission: permission}
+	}
+	user, err := s.repos.Users.Get(ctx, *actor.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return &PermissionError{Permission: permission}
+	} else if err != nil {
+		return err
+	}
+	if user.IsAdmin {
+		return nil
+	}
+	permissions, err := s.repos.Users.Permissions(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if !models.GrantsPermission(permissions, permission) {
+		return &PermissionError{Permission: permission}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+type S3Options struct {
+	// Endpoint is the host and optional port of the S3 API, such as s3.amazonaws.com or
+	// localhost:9000 for a MinIO server.
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	UseSSL          bool
+	// PublicBaseURL is set when the bucket, or a CDN in front of it, is publicly readable. Without
+	// it URL returns presigned URLs valid for URLExpiry.
+	PublicBaseURL string
+	URLExpiry     time.Duration
+}
+
+// S3Store keeps images in a bucket of an S3-compatible service.
+type S3Store struct {
+	client  *minio.Client
+	options S3Options
+}
+
+func NewS3Store(options S3Options) (*S3Store, error) {
+	client, err := minio.New(options.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(options.AccessKeyID, options.SecretAccessKey, ""),
+		Secure: options.UseSSL,
+		Region: options.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+	options.PublicBaseURL = strings.TrimSuffix(options.PublicBaseURL, "/")
+	return &S3Store{client: client, options: options}, nil
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	if !validKey(key) {
+		return ErrInvalidKey
+	}
+	_, err := s.client.PutObject(ctx, s.options.Bucket, key, body, size, minio.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	if !validKey(key) {
+		return ErrInvalidKey
+	}
+	return s.client.RemoveObject(ctx, s.options.Bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (s *S3Store) URL(ctx context.Context, key string) (string, error) {
+	if !validKey(key) {
+		return "", ErrInvalidKey
+	}
+	if s.options.PublicBaseURL != "" {
+		return s.options.PublicBaseURL + "/" + key, nil
+	}
+	u, err := s.client.PresignedGetObject(ctx, s.options.Bucket, key, s.options.URLExpiry, nil)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
@@ -0,0 +1,29 @@
+// Package storage keeps uploaded images, on the local disk or in an S3-compatible bucket so that
+// several instances can share them.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+var ErrInvalidKey = errors.New("storage: invalid key")
+
+// ImageStore stores images under keys chosen by the caller. The key is saved with the record that
+// uses the image; URL turns it into an address clients can load the image from, which may be
+// signed and expire, so it should be computed for each response rather than stored.
+type ImageStore interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Delete removes an image. Deleting a key that does not exist is not an error.
+	Delete(ctx context.Context, key string) error
+	URL(ctx context.Context, key string) (string, error)
+}
+
+// validKey reports whether key is a relative slash separated path that stays inside the store.
+func validKey(key string) bool {
+	return key != "" && !strings.HasPrefix(key, "/") && !strings.Contains(key, "\\") &&
+		path.Clean(key) == key && key != ".." && !strings.HasPrefix(key, "../")
+}
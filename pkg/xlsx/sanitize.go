@@ -0,0 +1,15 @@
+package xlsx
+
+// SanitizeCell makes user-entered text safe to open in a spreadsheet. Text starting with =, +, -,
+// @, a tab or a carriage return would be taken as a formula by Excel and Sheets, so it is prefixed
+// with a quote. It is used for both the XLSX and the CSV exports.
+func SanitizeCell(text string) string {
+	if text == "" {
+		return text
+	}
+	switch text[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return "'" + text
+	}
+	return text
+}
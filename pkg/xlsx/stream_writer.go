@@ -0,0 +1,154 @@
+// Package xlsx writes single-sheet Office Open XML spreadsheets row by row, so that large exports
+// can be streamed to the client without holding the whole workbook in memory.
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	contentTypesXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`
+
+	rootRelsXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`
+
+	workbookRelsXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`
+
+	workbookXMLFormat = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`
+
+	sheetHeaderXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+
+	sheetFooterXML = `</sheetData></worksheet>`
+)
+
+// StreamWriter writes a workbook with a single worksheet. Rows must be written in order and
+// Close must be called to finish the file.
+type StreamWriter struct {
+	zip   *zip.Writer
+	sheet io.Writer
+	row   int
+}
+
+func NewStreamWriter(w io.Writer, sheetName string) (*StreamWriter, error) {
+	zw := zip.NewWriter(w)
+
+	escapedName, err := escape(sheetName)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", contentTypesXML},
+		{"_rels/.rels", rootRelsXML},
+		{"xl/workbook.xml", fmt.Sprintf(workbookXMLFormat, escapedName)},
+		{"xl/_rels/workbook.xml.rels", workbookRelsXML},
+	}
+	for _, part := range parts {
+		fw, err := zw.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(fw, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	sheet, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(sheet, sheetHeaderXML); err != nil {
+		return nil, err
+	}
+
+	return &StreamWriter{zip: zw, sheet: sheet}, nil
+}
+
+// WriteRow appends a row. Numbers are written as numeric cells, times as text in the
+// "2006-01-02 15:04:05" layout and everything else as inline strings passed through SanitizeCell.
+func (w *StreamWriter) WriteRow(values ...any) error {
+	w.row++
+	if _, err := fmt.Fprintf(w.sheet, `<row r="%d">`, w.row); err != nil {
+		return err
+	}
+	for i, value := range values {
+		ref := columnName(i) + strconv.Itoa(w.row)
+		var cell string
+		switch v := value.(type) {
+		case int:
+			cell = fmt.Sprintf(`<c r="%s"><v>%d</v></c>`, ref, v)
+		case int64:
+			cell = fmt.Sprintf(`<c r="%s"><v>%d</v></c>`, ref, v)
+		case uint:
+			cell = fmt.Sprintf(`<c r="%s"><v>%d</v></c>`, ref, v)
+		case float64:
+			cell = fmt.Sprintf(`<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(v, 'f', -1, 64))
+		case time.Time:
+			cell = fmt.Sprintf(`<c r="%s" t="inlineStr"><is><t>%s</t></is></c>`, ref, v.Format("2006-01-02 15:04:05"))
+		default:
+			text, err := escape(SanitizeCell(fmt.Sprint(v)))
+			if err != nil {
+				return err
+			}
+			cell = fmt.Sprintf(`<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, text)
+		}
+		if _, err := io.WriteString(w.sheet, cell); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w.sheet, `</row>`)
+	return err
+}
+
+func (w *StreamWriter) Close() error {
+	if _, err := io.WriteString(w.sheet, sheetFooterXML); err != nil {
+		return err
+	}
+	return w.zip.Close()
+}
+
+// Flush flushes the compressed data written so far to the underlying writer.
+func (w *StreamWriter) Flush() error {
+	return w.zip.Flush()
+}
+
+// columnName converts a zero-based column index to its spreadsheet letters (0 → A, 26 → AA).
+func columnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+func escape(s string) (string, error) {
+	var b strings.Builder
+	if err := xml.EscapeText(&b, []byte(s)); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
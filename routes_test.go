@@ -0,0 +1,1145 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"yom-kitchen/pkg/apierror"
+	"yom-kitchen/pkg/events"
+	"yom-kitchen/pkg/middlewares"
+	"yom-kitchen/pkg/models"
+	"yom-kitchen/pkg/money"
+	"yom-kitchen/pkg/totp"
+)
+
+type access int
+
+const (
+	public access = iota
+	// staff routes need a staff access token.
+	staff
+	// admin routes also need a changed password and the route's permission.
+	admin
+	// client routes need a client token.
+	client
+)
+
+type route struct {
+	method     string
+	path       string
+	access     access
+	permission string
+}
+
+// routes lists every route of the router. TestRouteTableCoversRouter fails when a route is added
+// without being listed here.
+var routes = []route{
+	{"GET", "/admin/stats", admin, models.PermissionStatsRead},
+	{"GET", "/admin/stats/sales", admin, models.PermissionStatsRead},
+	{"GET", "/admin/audit", admin, models.PermissionAuditRead},
+	{"GET", "/admin/permissions", admin, models.PermissionRolesManage},
+
+	{"GET", "/admin/roles", admin, models.PermissionRolesManage},
+	{"POST", "/admin/roles", admin, models.PermissionRolesManage},
+	{"GET", "/admin/roles/:id", admin, models.PermissionRolesManage},
+	{"PUT", "/admin/roles/:id", admin, models.PermissionRolesManage},
+	{"DELETE", "/admin/roles/:id", admin, models.PermissionRolesManage},
+
+	{"POST", "/admin/users", admin, models.PermissionUsersManage},
+	{"GET", "/admin/users/:id", admin, models.PermissionUsersRead},
+	{"GET", "/admin/users", admin, models.PermissionUsersRead},
+	{"PUT", "/admin/users/:id", admin, models.PermissionUsersManage},
+	{"DELETE", "/admin/users/:id", admin, models.PermissionUsersManage},
+	{"GET", "/admin/users/:id/sessions", admin, models.PermissionUsersManage},
+	{"DELETE", "/admin/users/:id/sessions", admin, models.PermissionUsersManage},
+	{"DELETE", "/admin/users/:id/sessions/:sessionId", admin, models.PermissionUsersManage},
+	{"PUT", "/admin/users/:id/roles", admin, models.PermissionRolesManage},
+	{"DELETE", "/admin/users/:id/lockout", admin, models.PermissionUsersManage},
+	{"DELETE", "/admin/users/:id/totp", admin, models.PermissionUsersManage},
+
+	{"POST", "/admin/menus", admin, models.PermissionMenusManage},
+	{"GET", "/admin/menus", admin, models.PermissionMenusRead},
+	{"GET", "/admin/menus/:id", admin, models.PermissionMenusRead},
+	{"PUT", "/admin/menus/:id", admin, models.PermissionMenusManage},
+	{"DELETE", "/admin/menus/:id", admin, models.PermissionMenusManage},
+	{"PATCH", "/admin/menus/:id", admin, models.PermissionMenusAvailability},
+	{"GET", "/admin/menus/:id/recipe", admin, models.PermissionInventoryRead},
+	{"PUT", "/admin/menus/:id/recipe", admin, models.PermissionInventoryManage},
+
+	{"GET", "/admin/inventory/ingredients", admin, models.PermissionInventoryRead},
+	{"POST", "/admin/inventory/ingredients", admin, models.PermissionInventoryManage},
+	{"PUT", "/admin/inventory/ingredients/:id", admin, models.PermissionInventoryManage},
+	{"DELETE", "/admin/inventory/ingredients/:id", admin, models.PermissionInventoryManage},
+	{"POST", "/admin/inventory/ingredients/:id/adjustments", admin, models.PermissionInventoryManage},
+	{"POST", "/admin/inventory/ingredients/:id/deliveries", admin, models.PermissionInventoryManage},
+	{"GET", "/admin/inventory/ingredients/:id/movements", admin, models.PermissionInventoryRead},
+	{"GET", "/admin/inventory/low-stock", admin, models.PermissionInventoryRead},
+
+	{"GET", "/admin/tax-rates", admin, models.PermissionTaxRatesManage},
+	{"PUT", "/admin/tax-rates/:category", admin, models.PermissionTaxRatesManage},
+	{"DELETE", "/admin/tax-rates/:category", admin, models.PermissionTaxRatesManage},
+
+	{"POST", "/admin/promos", admin, models.PermissionPromosManage},
+	{"GET", "/admin/promos", admin, models.PermissionPromosManage},
+	{"GET", "/admin/promos/:id", admin, models.PermissionPromosManage},
+	{"PUT", "/admin/promos/:id", admin, models.PermissionPromosManage},
+	{"DELETE", "/admin/promos/:id", admin, models.PermissionPromosManage},
+
+	{"POST", "/admin/clients", admin, models.PermissionClientsManage},
+	{"GET", "/admin/clients", admin, models.PermissionClientsRead},
+	{"GET", "/admin/clients/:id", admin, models.PermissionClientsRead},
+	{"PUT", "/admin/clients/:id", admin, models.PermissionClientsManage},
+	{"DELETE", "/admin/clients/:id", admin, models.PermissionClientsManage},
+	{"PATCH", "/admin/clients/:id", admin, models.PermissionClientsManage},
+	{"POST", "/admin/clients/:id/passcode", admin, models.PermissionClientsManage},
+	{"POST", "/admin/clients/:id/login-link", admin, models.PermissionClientsManage},
+	{"DELETE", "/admin/clients/:id/lockout", admin, models.PermissionClientsManage},
+
+	{"POST", "/admin/orders", admin, models.PermissionOrdersCreate},
+	{"GET", "/admin/orders/:id", admin, models.PermissionOrdersRead},
+	{"GET", "/admin/orders", admin, models.PermissionOrdersRead},
+	{"GET", "/admin/orders/stream", admin, models.PermissionOrdersRead},
+	{"GET", "/admin/orders/export", admin, models.PermissionOrdersExport},
+	{"DELETE", "/admin/orders/:id", admin, models.PermissionOrdersDelete},
+	{"PUT", "/admin/orders/:id/status", admin, models.PermissionOrdersUpdate},
+	{"GET", "/admin/orders/:id/history", admin, models.PermissionOrdersRead},
+	{"GET", "/admin/orders/:id/receipt.pdf", admin, models.PermissionOrdersRead},
+
+	{"POST", "/client/login", public, ""},
+	{"GET", "/client/menus", public, ""},
+	{"POST", "/client/orders", client, ""},
+	{"GET", "/client/orders", client, ""},
+	{"GET", "/client/orders/:id/receipt.pdf", client, ""},
+	{"POST", "/client/passcode", client, ""},
+
+	{"POST", "/setup", public, ""},
+	{"POST", "/login", public, ""},
+	{"POST", "/login/totp", public, ""},
+	{"POST", "/login/totp/enroll", public, ""},
+	{"POST", "/login/totp/enroll/verify", public, ""},
+	{"POST", "/refresh", public, ""},
+	{"POST", "/logout", staff, ""},
+
+	{"POST", "/account/password", staff, ""},
+	{"POST", "/account/totp", staff, ""},
+	{"POST", "/account/totp/verify", staff, ""},
+	{"POST", "/account/totp/recovery-codes", staff, ""},
+	{"POST", "/account/totp/disable", staff, ""},
+
+	{"GET", "/uploads/*filepath", public, ""},
+	{"HEAD", "/uploads/*filepath", public, ""},
+}
+
+// examplePath fills in the parameters of a route path.
+func examplePath(path string) string {
+	path = strings.ReplaceAll(path, ":sessionId", "1")
+	path = strings.ReplaceAll(path, ":id", "1")
+	path = strings.ReplaceAll(path, ":category", "Mains")
+	return strings.ReplaceAll(path, "*filepath", "missing.jpg")
+}
+
+func cashierHasPermission(permission string) bool {
+	for _, role := range models.DefaultRoles() {
+		if role.Name == models.RoleCashier {
+			return models.GrantsPermission(role.PermissionNames(), permission)
+		}
+	}
+	return false
+}
+
+func TestRouteTableCoversRouter(t *testing.T) {
+	// Without an admin the router also serves POST /setup.
+	h := newEmptyHarness(t)
+
+	listed := make(map[string]bool, len(routes))
+	for _, r := range routes {
+		listed[r.method+" "+r.path] = true
+	}
+	served := make(map[string]bool)
+	for _, info := range h.router.Routes() {
+		key := info.Method + " " + info.Path
+		served[key] = true
+		if !listed[key] {
+			t.Errorf("Route %s is not in the route table", key)
+		}
+	}
+	for key := range listed {
+		if !served[key] {
+			t.Errorf("Route %s is in the route table but not served", key)
+		}
+	}
+}
+
+func TestRouteAccess(t *testing.T) {
+	h := newHarness(t)
+	cashierToken := h.cashierToken()
+	clientToken := h.clientLogin(h.Client)
+
+	newcomer := seedUser(t, h.db, "newcomer", "newcomer-password", true)
+	if err := h.db.Model(&newcomer).UpdateColumn("must_change_password", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	newcomerToken := h.login(newcomer.Username, "newcomer-password")
+
+	for _, r := range routes {
+		if r.access == public {
+			continue
+		}
+		path := examplePath(r.path)
+		t.Run(r.method+" "+r.path, func(t *testing.T) {
+			expectError(t, h.do(r.method, path, "", nil), http.StatusUnauthorized, apierror.CodeUnauthorized)
+			body := expectError(t, h.do(r.method, path, "not-a-jwt", nil), http.StatusUnauthorized, apierror.CodeInvalidToken)
+			if body.Error.Message != "Invalid or expired token" {
+				t.Fatalf("Expected a fixed invalid token message, got %q", body.Error.Message)
+			}
+
+			switch r.access {
+			case staff, admin:
+				expectError(t, h.do(r.method, path, clientToken, nil), http.StatusUnauthorized, apierror.CodeInvalidToken)
+			case client:
+				expectError(t, h.do(r.method, path, cashierToken, nil), http.StatusUnauthorized, apierror.CodeInvalidToken)
+			}
+
+			if r.access == admin {
+				expectError(t, h.do(r.method, path, newcomerToken, nil), http.StatusForbidden, apierror.CodePasswordChangeRequired)
+				if !cashierHasPermission(r.permission) {
+					expectError(t, h.do(r.method, path, cashierToken, nil), http.StatusForbidden, apierror.CodeForbidden)
+				}
+			}
+		})
+	}
+}
+
+func TestUnknownRoute(t *testing.T) {
+	h := newHarness(t)
+	rec := h.do(http.MethodGet, "/no/such/route", "", nil)
+	body := expectError(t, rec, http.StatusNotFound, apierror.CodeNotFound)
+	if body.Error.RequestID == "" || rec.Header().Get(apierror.RequestIDHeader) != body.Error.RequestID {
+		t.Errorf("Expected the request ID in the header and the body, got %q and %q", rec.Header().Get(apierror.RequestIDHeader), body.Error.RequestID)
+	}
+}
+
+func TestSetup(t *testing.T) {
+	h := newEmptyHarness(t)
+	if h.setupToken == "" {
+		t.Fatal("Expected a setup token without an admin")
+	}
+
+	request := map[string]string{"setup_token": "wrong", "username": "owner", "password": "owner-password"}
+	expectError(t, h.do(http.MethodPost, "/setup", "", request), http.StatusUnauthorized, apierror.CodeUnauthorized)
+
+	request["setup_token"] = h.setupToken
+	expectStatus(t, h.do(http.MethodPost, "/setup", "", request), http.StatusCreated)
+	expectError(t, h.do(http.MethodPost, "/setup", "", request), http.StatusConflict, apierror.CodeConflict)
+
+	token := h.login("owner", "owner-password")
+	expectStatus(t, h.do(http.MethodGet, "/admin/stats", token, nil), http.StatusOK)
+}
+
+func TestLoginLockoutAndUnlock(t *testing.T) {
+	h := newHarness(t)
+	adminToken := h.adminToken()
+
+	expectError(t, h.do(http.MethodPost, "/login", "", map[string]string{"username": "nobody", "password": "x"}),
+		http.StatusUnauthorized, apierror.CodeUnauthorized)
+	expectError(t, h.do(http.MethodPost, "/login", "", map[string]string{"username": "cashier"}),
+		http.StatusBadRequest, apierror.CodeValidation)
+
+	wrong := map[string]string{"username": "cashier", "password": "wrong-password"}
+	expectError(t, h.do(http.MethodPost, "/login", "", wrong), http.StatusUnauthorized, apierror.CodeUnauthorized)
+	// A failure makes the account wait before the next attempt, even with the right password.
+	right := map[string]string{"username": "cashier", "password": cashierPassword}
+	rec := h.do(http.MethodPost, "/login", "", right)
+	expectError(t, rec, http.StatusTooManyRequests, apierror.CodeTooManyRequests)
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("Expected a Retry-After header")
+	}
+
+	expectStatus(t, h.do(http.MethodDelete, fmt.Sprintf("/admin/users/%d/lockout", h.Cashier.ID), adminToken, nil), http.StatusOK)
+
+	// Without trusted proxies X-Forwarded-For cannot change the client IP.
+	body, err := json.Marshal(right)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	expectStatus(t, h.send(req, ""), http.StatusOK)
+	var session models.Session
+	if err := h.db.Where("user_id = ?", h.Cashier.ID).Order("id DESC").First(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+	if remoteIP, _, _ := strings.Cut(req.RemoteAddr, ":"); session.IPAddress != remoteIP {
+		t.Fatalf("Expected the session IP to be %s, got %s", remoteIP, session.IPAddress)
+	}
+}
+
+func TestRefreshAndLogout(t *testing.T) {
+	h := newHarness(t)
+	rec := h.do(http.MethodPost, "/login", "", map[string]string{"username": "admin", "password": adminPassword})
+	expectStatus(t, rec, http.StatusOK)
+	login := decode[tokenBody](t, rec)
+
+	rec = h.do(http.MethodPost, "/refresh", "", map[string]string{"refresh_token": login.RefreshToken})
+	expectStatus(t, rec, http.StatusOK)
+	refreshed := decode[tokenBody](t, rec)
+	if refreshed.RefreshToken == login.RefreshToken {
+		t.Fatal("Expected a new refresh token")
+	}
+	expectStatus(t, h.do(http.MethodGet, "/admin/stats", refreshed.Token, nil), http.StatusOK)
+
+	// Presenting a rotated refresh token again revokes the session.
+	expectError(t, h.do(http.MethodPost, "/refresh", "", map[string]string{"refresh_token": login.RefreshToken}),
+		http.StatusUnauthorized, apierror.CodeUnauthorized)
+	expectError(t, h.do(http.MethodGet, "/admin/stats", refreshed.Token, nil), http.StatusUnauthorized, apierror.CodeInvalidToken)
+	expectError(t, h.do(http.MethodPost, "/refresh", "", map[string]string{"refresh_token": refreshed.RefreshToken}),
+		http.StatusUnauthorized, apierror.CodeUnauthorized)
+
+	token := h.adminToken()
+	expectStatus(t, h.do(http.MethodPost, "/logout", token, nil), http.StatusOK)
+	expectError(t, h.do(http.MethodGet, "/admin/stats", token, nil), http.StatusUnauthorized, apierror.CodeInvalidToken)
+}
+
+func TestChangePassword(t *testing.T) {
+	h := newHarness(t)
+	newcomer := seedUser(t, h.db, "newcomer", "initial-password", false)
+	if err := h.db.Model(&newcomer).UpdateColumn("must_change_password", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	rec := h.do(http.MethodPost, "/login", "", map[string]string{"username": "newcomer", "password": "initial-password"})
+	expectStatus(t, rec, http.StatusOK)
+	login := decode[tokenBody](t, rec)
+	if !login.MustChangePassword {
+		t.Fatal("Expected must_change_password in the login response")
+	}
+	expectError(t, h.do(http.MethodGet, "/admin/stats", login.Token, nil), http.StatusForbidden, apierror.CodePasswordChangeRequired)
+
+	expectError(t, h.do(http.MethodPost, "/account/password", login.Token, map[string]string{"current_password": "initial-password", "new_password": "short"}),
+		http.StatusBadRequest, apierror.CodeBadRequest)
+	expectError(t, h.do(http.MethodPost, "/account/password", login.Token, map[string]string{"current_password": "wrong-password", "new_password": "changed-password"}),
+		http.StatusUnauthorized, apierror.CodeUnauthorized)
+	expectStatus(t, h.do(http.MethodPost, "/account/password", login.Token, map[string]string{"current_password": "initial-password", "new_password": "changed-password"}),
+		http.StatusOK)
+
+	// The password is changed, so only the missing permission keeps the user out now.
+	expectError(t, h.do(http.MethodGet, "/admin/stats", login.Token, nil), http.StatusForbidden, apierror.CodeForbidden)
+	h.login("newcomer", "changed-password")
+}
+
+func currentTOTPCode(t *testing.T, secret string, stepOffset int64) string {
+	t.Helper()
+	code, err := totp.Code(secret, totp.Step(time.Now())+stepOffset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+type enrollmentBody struct {
+	Secret        string   `json:"secret"`
+	RecoveryCodes []string `json:"recovery_codes"`
+	tokenBody
+}
+
+func TestAccountTOTP(t *testing.T) {
+	h := newHarness(t)
+	token := h.adminToken()
+
+	rec := h.do(http.MethodPost, "/account/totp", token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	secret := decode[enrollmentBody](t, rec).Secret
+
+	expectError(t, h.do(http.MethodPost, "/account/totp/verify", token, map[string]string{"code": "000000"}),
+		http.StatusUnauthorized, apierror.CodeUnauthorized)
+	// The failed code makes the account wait before the next attempt.
+	expectStatus(t, h.do(http.MethodDelete, fmt.Sprintf("/admin/users/%d/lockout", h.Admin.ID), token, nil), http.StatusOK)
+	rec = h.do(http.MethodPost, "/account/totp/verify", token, map[string]string{"code": currentTOTPCode(t, secret, 0)})
+	expectStatus(t, rec, http.StatusOK)
+	if codes := decode[enrollmentBody](t, rec).RecoveryCodes; len(codes) == 0 {
+		t.Fatal("Expected recovery codes")
+	}
+
+	rec = h.do(http.MethodPost, "/account/totp/recovery-codes", token, map[string]string{"code": currentTOTPCode(t, secret, 1)})
+	expectStatus(t, rec, http.StatusOK)
+	recoveryCodes := decode[enrollmentBody](t, rec).RecoveryCodes
+
+	rec = h.do(http.MethodPost, "/login", "", map[string]string{"username": "admin", "password": adminPassword})
+	expectStatus(t, rec, http.StatusOK)
+	challenge := decode[tokenBody](t, rec)
+	if !challenge.MFARequired || challenge.Token != "" {
+		t.Fatalf("Expected a two-factor challenge, got %s", rec.Body.String())
+	}
+	expectError(t, h.do(http.MethodGet, "/admin/stats", challenge.MFAToken, nil), http.StatusUnauthorized, apierror.CodeInvalidToken)
+
+	rec = h.do(http.MethodPost, "/login/totp", "", map[string]string{"mfa_token": challenge.MFAToken, "recovery_code": recoveryCodes[0]})
+	expectStatus(t, rec, http.StatusOK)
+	token = decode[tokenBody](t, rec).Token
+	expectStatus(t, h.do(http.MethodGet, "/admin/stats", token, nil), http.StatusOK)
+
+	expectError(t, h.do(http.MethodPost, "/account/totp/disable", token, map[string]string{"password": adminPassword, "recovery_code": recoveryCodes[0]}),
+		http.StatusUnauthorized, apierror.CodeUnauthorized)
+	expectStatus(t, h.do(http.MethodPost, "/account/totp/disable", token, map[string]string{"password": adminPassword, "recovery_code": recoveryCodes[1]}),
+		http.StatusOK)
+	h.adminToken()
+}
+
+func TestLoginTOTPEnrollmentAndReset(t *testing.T) {
+	h := newHarness(t)
+	adminToken := h.adminToken()
+
+	rec := h.do(http.MethodPost, "/admin/roles", adminToken, map[string]any{
+		"name": "secure-cashier", "require_totp": true, "permissions": []string{models.PermissionOrdersRead},
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	role := decode[struct{ ID uint }](t, rec)
+	expectStatus(t, h.do(http.MethodPut, fmt.Sprintf("/admin/users/%d/roles", h.Cashier.ID), adminToken, map[string]any{"role_ids": []uint{role.ID}}),
+		http.StatusOK)
+
+	rec = h.do(http.MethodPost, "/login", "", map[string]string{"username": "cashier", "password": cashierPassword})
+	expectStatus(t, rec, http.StatusOK)
+	challenge := decode[tokenBody](t, rec)
+	if !challenge.EnrollmentRequired {
+		t.Fatalf("Expected enrollment to be required, got %s", rec.Body.String())
+	}
+	expectError(t, h.do(http.MethodPost, "/login/totp", "", map[string]string{"mfa_token": challenge.MFAToken, "code": "123456"}),
+		http.StatusConflict, apierror.CodeConflict)
+
+	rec = h.do(http.MethodPost, "/login/totp/enroll", "", map[string]string{"mfa_token": challenge.MFAToken})
+	expectStatus(t, rec, http.StatusOK)
+	secret := decode[enrollmentBody](t, rec).Secret
+	rec = h.do(http.MethodPost, "/login/totp/enroll/verify", "", map[string]string{"mfa_token": challenge.MFAToken, "code": currentTOTPCode(t, secret, 0)})
+	expectStatus(t, rec, http.StatusOK)
+	enrolled := decode[enrollmentBody](t, rec)
+	if enrolled.Token == "" || len(enrolled.RecoveryCodes) == 0 {
+		t.Fatalf("Expected tokens and recovery codes, got %s", rec.Body.String())
+	}
+	expectStatus(t, h.do(http.MethodGet, "/admin/orders", enrolled.Token, nil), http.StatusOK)
+
+	rec = h.do(http.MethodPost, "/login", "", map[string]string{"username": "cashier", "password": cashierPassword})
+	expectStatus(t, rec, http.StatusOK)
+	challenge = decode[tokenBody](t, rec)
+	rec = h.do(http.MethodPost, "/login/totp", "", map[string]string{"mfa_token": challenge.MFAToken, "code": currentTOTPCode(t, secret, 1)})
+	expectStatus(t, rec, http.StatusOK)
+
+	// The role requires two-factor authentication, so disabling it is refused.
+	expectError(t, h.do(http.MethodPost, "/account/totp/disable", enrolled.Token, map[string]string{"password": cashierPassword, "recovery_code": enrolled.RecoveryCodes[0]}),
+		http.StatusConflict, apierror.CodeConflict)
+
+	expectStatus(t, h.do(http.MethodDelete, fmt.Sprintf("/admin/users/%d/totp", h.Cashier.ID), adminToken, nil), http.StatusOK)
+	rec = h.do(http.MethodPost, "/login", "", map[string]string{"username": "cashier", "password": cashierPassword})
+	expectStatus(t, rec, http.StatusOK)
+	if !decode[tokenBody](t, rec).EnrollmentRequired {
+		t.Fatal("Expected enrollment to be required again after the reset")
+	}
+}
+
+type userBody struct {
+	ID       uint     `json:"id"`
+	Username string   `json:"username"`
+	Roles    []string `json:"roles"`
+}
+
+type sessionsBody struct {
+	Sessions []struct {
+		ID uint `json:"id"`
+	} `json:"sessions"`
+	CurrentSessionID uint `json:"current_session_id"`
+}
+
+func TestUsersAdmin(t *testing.T) {
+	h := newHarness(t)
+	token := h.adminToken()
+
+	var kitchen models.Role
+	if err := h.db.Where("name = ?", models.RoleKitchen).First(&kitchen).Error; err != nil {
+		t.Fatal(err)
+	}
+	newUser := map[string]any{"username": "waiter", "password": "waiter-password", "role_ids": []uint{kitchen.ID}}
+	rec := h.do(http.MethodPost, "/admin/users", token, newUser)
+	expectStatus(t, rec, http.StatusCreated)
+	userID := decode[struct {
+		UserID uint `json:"user_id"`
+	}](t, rec).UserID
+	userPath := fmt.Sprintf("/admin/users/%d", userID)
+	expectError(t, h.do(http.MethodPost, "/admin/users", token, newUser), http.StatusConflict, apierror.CodeConflict)
+	expectError(t, h.do(http.MethodPost, "/admin/users", token, map[string]any{"username": "x", "password": "x-password", "role_ids": []uint{999999}}),
+		http.StatusBadRequest, apierror.CodeBadRequest)
+
+	rec = h.do(http.MethodGet, userPath, token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if user := decode[userBody](t, rec); user.Username != "waiter" || !slices.Equal(user.Roles, []string{models.RoleKitchen}) {
+		t.Fatalf("Unexpected user %s", rec.Body.String())
+	}
+	expectError(t, h.do(http.MethodGet, "/admin/users/999999", token, nil), http.StatusNotFound, apierror.CodeNotFound)
+	expectError(t, h.do(http.MethodGet, "/admin/users/abc", token, nil), http.StatusBadRequest, apierror.CodeBadRequest)
+
+	rec = h.do(http.MethodGet, "/admin/users?sort=username", token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if list := decode[listBody[userBody]](t, rec); list.Total != 3 || list.Items[0].Username != "admin" {
+		t.Fatalf("Unexpected user list %s", rec.Body.String())
+	}
+
+	expectStatus(t, h.do(http.MethodPut, userPath, token, map[string]string{"username": "server"}), http.StatusOK)
+	expectError(t, h.do(http.MethodPut, userPath, token, map[string]string{"username": "cashier"}), http.StatusConflict, apierror.CodeConflict)
+	expectStatus(t, h.do(http.MethodPut, userPath+"/roles", token, map[string]any{"role_ids": []uint{}}), http.StatusOK)
+
+	waiterToken := h.login("server", "waiter-password")
+	rec = h.do(http.MethodGet, userPath+"/sessions", token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	sessions := decode[sessionsBody](t, rec).Sessions
+	if len(sessions) != 1 {
+		t.Fatalf("Expected one session, got %s", rec.Body.String())
+	}
+	expectStatus(t, h.do(http.MethodDelete, fmt.Sprintf("%s/sessions/%d", userPath, sessions[0].ID), token, nil), http.StatusOK)
+	expectError(t, h.do(http.MethodPost, "/account/totp", waiterToken, nil), http.StatusUnauthorized, apierror.CodeInvalidToken)
+	expectError(t, h.do(http.MethodDelete, userPath+"/sessions/999999", token, nil), http.StatusNotFound, apierror.CodeNotFound)
+
+	waiterToken = h.login("server", "waiter-password")
+	rec = h.do(http.MethodDelete, userPath+"/sessions", token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if revoked := decode[struct{ Revoked int64 }](t, rec).Revoked; revoked != 1 {
+		t.Fatalf("Expected one revoked session, got %d", revoked)
+	}
+	expectError(t, h.do(http.MethodPost, "/account/totp", waiterToken, nil), http.StatusUnauthorized, apierror.CodeInvalidToken)
+
+	expectStatus(t, h.do(http.MethodDelete, userPath, token, nil), http.StatusOK)
+	expectError(t, h.do(http.MethodGet, userPath, token, nil), http.StatusNotFound, apierror.CodeNotFound)
+}
+
+type roleBody struct {
+	ID          uint     `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	IsSystem    bool     `json:"is_system"`
+	Permissions []string `json:"permissions"`
+}
+
+func TestRolesAdmin(t *testing.T) {
+	h := newHarness(t)
+	token := h.adminToken()
+
+	rec := h.do(http.MethodGet, "/admin/permissions", token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if permissions := decode[struct{ Permissions []string }](t, rec).Permissions; !slices.Contains(permissions, models.PermissionAll) {
+		t.Fatalf("Expected %q among the permissions, got %v", models.PermissionAll, permissions)
+	}
+
+	rec = h.do(http.MethodGet, "/admin/roles", token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if roles := decode[[]roleBody](t, rec); len(roles) != len(models.DefaultRoles()) {
+		t.Fatalf("Expected the system roles, got %s", rec.Body.String())
+	}
+
+	expectError(t, h.do(http.MethodPost, "/admin/roles", token, map[string]any{"name": "host", "permissions": []string{"tables:manage"}}),
+		http.StatusBadRequest, apierror.CodeBadRequest)
+	rec = h.do(http.MethodPost, "/admin/roles", token, map[string]any{"name": "host", "permissions": []string{models.PermissionClientsRead}})
+	expectStatus(t, rec, http.StatusCreated)
+	role := decode[roleBody](t, rec)
+	rolePath := fmt.Sprintf("/admin/roles/%d", role.ID)
+	expectError(t, h.do(http.MethodPost, "/admin/roles", token, map[string]any{"name": "host"}), http.StatusConflict, apierror.CodeConflict)
+
+	rec = h.do(http.MethodPut, rolePath, token, map[string]any{"description": "Seats guests"})
+	expectStatus(t, rec, http.StatusOK)
+	rec = h.do(http.MethodGet, rolePath, token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if got := decode[roleBody](t, rec); got.Description != "Seats guests" || !slices.Equal(got.Permissions, []string{models.PermissionClientsRead}) {
+		t.Fatalf("Unexpected role %s", rec.Body.String())
+	}
+
+	expectStatus(t, h.do(http.MethodDelete, rolePath, token, nil), http.StatusOK)
+	expectError(t, h.do(http.MethodGet, rolePath, token, nil), http.StatusNotFound, apierror.CodeNotFound)
+
+	var owner models.Role
+	if err := h.db.Where("name = ?", models.RoleOwner).First(&owner).Error; err != nil {
+		t.Fatal(err)
+	}
+	expectError(t, h.do(http.MethodDelete, fmt.Sprintf("/admin/roles/%d", owner.ID), token, nil), http.StatusConflict, apierror.CodeConflict)
+}
+
+// testPNG returns a small PNG image.
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for x := 0; x < 16; x++ {
+		for y := 0; y < 16; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 16), G: 128, B: uint8(y * 16), A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// multipartRequest builds a multipart form request, with an "image" file when image is not nil.
+func multipartRequest(t *testing.T, method, path string, fields map[string]string, image []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range fields {
+		if err := form.WriteField(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if image != nil {
+		part, err := form.CreateFormFile("image", "dish.png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(image)
+	}
+	if err := form.Close(); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(method, path, &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return req
+}
+
+func TestMenusAdmin(t *testing.T) {
+	h := newHarness(t)
+	token := h.adminToken()
+
+	fields := map[string]string{"name": "Shiro", "desc": "Chickpea stew", "price": "120.50", "category": "Mains"}
+	rec := h.send(multipartRequest(t, http.MethodPost, "/admin/menus", fields, testPNG(t)), token)
+	expectStatus(t, rec, http.StatusCreated)
+	item := decode[models.MenuItem](t, rec)
+	if item.Price != money.FromFloat(120.50) || item.ImageUrl == "" || len(item.ImageUrls) == 0 {
+		t.Fatalf("Unexpected menu item %s", rec.Body.String())
+	}
+	expectStatus(t, h.do(http.MethodGet, item.ImageUrl, "", nil), http.StatusOK)
+	expectStatus(t, h.do(http.MethodGet, "/uploads/missing.jpg", "", nil), http.StatusNotFound)
+
+	expectError(t, h.send(multipartRequest(t, http.MethodPost, "/admin/menus", fields, nil), token), http.StatusConflict, apierror.CodeConflict)
+	invalid := multipartRequest(t, http.MethodPost, "/admin/menus", map[string]string{"name": "Bad", "price": "1"}, []byte("not an image"))
+	expectError(t, h.send(invalid, token), http.StatusBadRequest, apierror.CodeBadRequest)
+	// Bodies over the image limit are refused while they are read.
+	oversized := multipartRequest(t, http.MethodPost, "/admin/menus", map[string]string{"name": "Huge", "price": "1"}, make([]byte, 7<<20))
+	expectError(t, h.send(oversized, token), http.StatusRequestEntityTooLarge, apierror.CodePayloadTooLarge)
+
+	rec = h.do(http.MethodGet, "/admin/menus?available=false", token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if list := decode[listBody[models.MenuItem]](t, rec); list.Total != 1 || list.Items[0].ID != h.UnavailableMenu.ID {
+		t.Fatalf("Unexpected unavailable menu items %s", rec.Body.String())
+	}
+	rec = h.do(http.MethodGet, "/admin/menus?category=Mains", token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if list := decode[listBody[models.MenuItem]](t, rec); list.Total != 3 {
+		t.Fatalf("Expected 3 menu items, got %s", rec.Body.String())
+	}
+
+	itemPath := fmt.Sprintf("/admin/menus/%d", item.ID)
+	rec = h.send(multipartRequest(t, http.MethodPut, itemPath, map[string]string{"price": "130"}, nil), token)
+	expectStatus(t, rec, http.StatusOK)
+	rec = h.do(http.MethodGet, itemPath, token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if got := decode[models.MenuItem](t, rec); got.Price != money.FromFloat(130) || got.Name != "Shiro" {
+		t.Fatalf("Unexpected menu item after update %s", rec.Body.String())
+	}
+
+	rec = h.do(http.MethodPatch, itemPath, token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if decode[struct {
+		MenuItem models.MenuItem `json:"menu_item"`
+	}](t, rec).MenuItem.Available {
+		t.Fatal("Expected the menu item to be unavailable after toggling")
+	}
+
+	rec = h.do(http.MethodGet, "/client/menus", "", nil)
+	expectStatus(t, rec, http.StatusOK)
+	available := decode[[]models.MenuItem](t, rec)
+	if len(available) != 1 || available[0].ID != h.Menu.ID {
+		t.Fatalf("Expected only %q on the client menu, got %s", h.Menu.Name, rec.Body.String())
+	}
+
+	expectStatus(t, h.do(http.MethodDelete, itemPath, token, nil), http.StatusOK)
+	expectError(t, h.do(http.MethodGet, itemPath, token, nil), http.StatusNotFound, apierror.CodeNotFound)
+	expectError(t, h.do(http.MethodDelete, itemPath, token, nil), http.StatusNotFound, apierror.CodeNotFound)
+}
+
+func TestInventoryAdmin(t *testing.T) {
+	h := newHarness(t)
+	token := h.adminToken()
+
+	rec := h.do(http.MethodPost, "/admin/inventory/ingredients", token, map[string]any{
+		"name": "Chicken", "unit": "pcs", "stock_quantity": 10, "low_stock_threshold": 2,
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	ingredient := decode[models.Ingredient](t, rec)
+	ingredientPath := fmt.Sprintf("/admin/inventory/ingredients/%d", ingredient.ID)
+
+	rec = h.do(http.MethodGet, "/admin/inventory/ingredients", token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if list := decode[listBody[models.Ingredient]](t, rec); list.Total != 1 {
+		t.Fatalf("Expected one ingredient, got %s", rec.Body.String())
+	}
+
+	expectStatus(t, h.do(http.MethodPut, ingredientPath, token, map[string]any{"low_stock_threshold": 8}), http.StatusOK)
+	expectStatus(t, h.do(http.MethodPost, ingredientPath+"/adjustments", token, map[string]any{"quantity": -4, "note": "Spoiled"}), http.StatusOK)
+	expectError(t, h.do(http.MethodPost, ingredientPath+"/deliveries", token, map[string]any{"quantity": -1}),
+		http.StatusBadRequest, apierror.CodeValidation)
+	rec = h.do(http.MethodPost, ingredientPath+"/deliveries", token, map[string]any{"quantity": 1})
+	expectStatus(t, rec, http.StatusOK)
+	if stock := decode[struct{ Ingredient models.Ingredient }](t, rec).Ingredient.StockQuantity; stock != 7 {
+		t.Fatalf("Expected a stock of 7, got %v", stock)
+	}
+
+	rec = h.do(http.MethodGet, ingredientPath+"/movements", token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if list := decode[listBody[models.StockMovement]](t, rec); list.Total != 2 {
+		t.Fatalf("Expected two stock movements, got %s", rec.Body.String())
+	}
+
+	recipePath := fmt.Sprintf("/admin/menus/%d/recipe", h.Menu.ID)
+	expectStatus(t, h.do(http.MethodPut, recipePath, token, map[string]any{
+		"items": []map[string]any{{"ingredient_id": ingredient.ID, "quantity_per_portion": 1}},
+	}), http.StatusOK)
+	rec = h.do(http.MethodGet, recipePath, token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if recipe := decode[struct{ Recipe []models.RecipeItem }](t, rec).Recipe; len(recipe) != 1 || recipe[0].IngredientID != ingredient.ID {
+		t.Fatalf("Unexpected recipe %s", rec.Body.String())
+	}
+
+	rec = h.do(http.MethodGet, "/admin/inventory/low-stock", token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	lowStock := decode[[]struct {
+		Name      string   `json:"name"`
+		MenuItems []string `json:"menu_items"`
+	}](t, rec)
+	if len(lowStock) != 1 || !slices.Equal(lowStock[0].MenuItems, []string{h.Menu.Name}) {
+		t.Fatalf("Unexpected low stock report %s", rec.Body.String())
+	}
+
+	expectError(t, h.do(http.MethodDelete, ingredientPath, token, nil), http.StatusConflict, apierror.CodeConflict)
+	expectStatus(t, h.do(http.MethodPut, recipePath, token, map[string]any{"items": []any{}}), http.StatusOK)
+	expectStatus(t, h.do(http.MethodDelete, ingredientPath, token, nil), http.StatusOK)
+	expectError(t, h.do(http.MethodDelete, ingredientPath, token, nil), http.StatusNotFound, apierror.CodeNotFound)
+}
+
+func TestTaxRatesAdmin(t *testing.T) {
+	h := newHarness(t)
+	token := h.adminToken()
+
+	expectError(t, h.do(http.MethodPut, "/admin/tax-rates/Mains", token, map[string]any{"rate": 150}), http.StatusBadRequest, apierror.CodeValidation)
+	expectStatus(t, h.do(http.MethodPut, "/admin/tax-rates/Mains", token, map[string]any{"rate": 15}), http.StatusOK)
+
+	rec := h.do(http.MethodGet, "/admin/tax-rates", token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	rates := decode[struct{ Categories []models.CategoryTaxRate }](t, rec).Categories
+	if len(rates) != 1 || rates[0].Category != "Mains" || rates[0].Rate != 15 {
+		t.Fatalf("Unexpected tax rates %s", rec.Body.String())
+	}
+
+	// Orders are taxed at the category rate.
+	expectStatus(t, h.do(http.MethodPost, "/admin/orders", token, map[string]any{
+		"client_id": h.Client.ID, "order_items": []map[string]any{{"menu_item_id": h.Menu.ID, "quantity": 1}},
+	}), http.StatusCreated)
+	if order := h.latestOrder(h.Client.ID); order.TaxAmount != money.FromFloat(37.50) {
+		t.Fatalf("Expected a tax of 37.50, got %s", order.TaxAmount)
+	}
+
+	expectStatus(t, h.do(http.MethodDelete, "/admin/tax-rates/Mains", token, nil), http.StatusOK)
+	expectError(t, h.do(http.MethodDelete, "/admin/tax-rates/Mains", token, nil), http.StatusNotFound, apierror.CodeNotFound)
+}
+
+func TestPromosAdmin(t *testing.T) {
+	h := newHarness(t)
+	token := h.adminToken()
+
+	expectError(t, h.do(http.MethodPost, "/admin/promos", token, map[string]any{"code": "bad", "discount_type": "percentage"}),
+		http.StatusBadRequest, apierror.CodeBadRequest)
+	promoRequest := map[string]any{"code": "save10", "discount_type": "percentage", "percent": 10, "max_per_client": 1}
+	rec := h.do(http.MethodPost, "/admin/promos", token, promoRequest)
+	expectStatus(t, rec, http.StatusCreated)
+	promo := decode[models.Promo](t, rec)
+	if promo.Code != "SAVE10" {
+		t.Fatalf("Expected the code to be normalized, got %q", promo.Code)
+	}
+	promoPath := fmt.Sprintf("/admin/promos/%d", promo.ID)
+	expectError(t, h.do(http.MethodPost, "/admin/promos", token, promoRequest), http.StatusConflict, apierror.CodeConflict)
+
+	rec = h.do(http.MethodGet, "/admin/promos", token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if list := decode[listBody[models.Promo]](t, rec); list.Total != 1 {
+		t.Fatalf("Expected one promo, got %s", rec.Body.String())
+	}
+
+	clientToken := h.clientLogin(h.Client)
+	order := map[string]any{"order_items": []map[string]any{{"menu_item_id": h.Menu.ID, "quantity": 1}}, "promo_code": "Save10"}
+	expectStatus(t, h.do(http.MethodPost, "/client/orders", clientToken, order), http.StatusCreated)
+	if got := h.latestOrder(h.Client.ID); got.DiscountAmount != money.FromFloat(25) || got.PromoCode != "SAVE10" {
+		t.Fatalf("Expected a discount of 25.00 from SAVE10, got %s from %q", got.DiscountAmount, got.PromoCode)
+	}
+	// The promo can be used once per client.
+	expectError(t, h.do(http.MethodPost, "/client/orders", clientToken, order), http.StatusBadRequest, apierror.CodeBadRequest)
+
+	rec = h.do(http.MethodGet, promoPath, token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	detail := decode[struct {
+		Promo       models.Promo
+		Redemptions []models.PromoRedemption
+	}](t, rec)
+	if detail.Promo.RedemptionCount != 1 || len(detail.Redemptions) != 1 {
+		t.Fatalf("Expected one redemption, got %s", rec.Body.String())
+	}
+
+	// Deleting the order gives the redemption back to the client.
+	redeemed := h.latestOrder(h.Client.ID)
+	expectStatus(t, h.do(http.MethodDelete, fmt.Sprintf("/admin/orders/%d", redeemed.ID), token, nil), http.StatusOK)
+	rec = h.do(http.MethodGet, promoPath, token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if detail := decode[struct{ Promo models.Promo }](t, rec); detail.Promo.RedemptionCount != 0 {
+		t.Fatalf("Expected no redemption after deleting the order, got %s", rec.Body.String())
+	}
+	expectStatus(t, h.do(http.MethodPost, "/client/orders", clientToken, order), http.StatusCreated)
+
+	promoRequest["percent"] = 20
+	rec = h.do(http.MethodPut, promoPath, token, promoRequest)
+	expectStatus(t, rec, http.StatusOK)
+	if percent := decode[struct{ Promo models.Promo }](t, rec).Promo.Percent; percent != 20 {
+		t.Fatalf("Expected a percent of 20, got %v", percent)
+	}
+
+	expectStatus(t, h.do(http.MethodDelete, promoPath, token, nil), http.StatusOK)
+	expectError(t, h.do(http.MethodGet, promoPath, token, nil), http.StatusNotFound, apierror.CodeNotFound)
+}
+
+func TestClientsAdmin(t *testing.T) {
+	h := newHarness(t)
+	token := h.adminToken()
+
+	newClient := map[string]any{"name": "Hanna Girma", "email": "hanna@example.com", "phone": "+251911000000", "is_active": true}
+	rec := h.do(http.MethodPost, "/admin/clients", token, newClient)
+	expectStatus(t, rec, http.StatusCreated)
+	created := decode[models.Client](t, rec)
+	if created.Passcode == "" {
+		t.Fatal("Expected the new client's passcode in the response")
+	}
+	clientPath := fmt.Sprintf("/admin/clients/%d", created.ID)
+	expectError(t, h.do(http.MethodPost, "/admin/clients", token, newClient), http.StatusConflict, apierror.CodeConflict)
+	h.clientLogin(created)
+
+	rec = h.do(http.MethodGet, "/admin/clients?active=false", token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if list := decode[listBody[models.Client]](t, rec); list.Total != 1 || list.Items[0].ID != h.InactiveClient.ID {
+		t.Fatalf("Unexpected inactive clients %s", rec.Body.String())
+	}
+	expectError(t, h.do(http.MethodGet, "/admin/clients?active=maybe", token, nil), http.StatusBadRequest, apierror.CodeBadRequest)
+
+	expectStatus(t, h.do(http.MethodPut, clientPath, token, map[string]any{"address": "Bole, Addis Ababa"}), http.StatusOK)
+	rec = h.do(http.MethodGet, clientPath, token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if got := decode[models.Client](t, rec); got.Address != "Bole, Addis Ababa" || got.Name != "Hanna Girma" {
+		t.Fatalf("Unexpected client after update %s", rec.Body.String())
+	}
+
+	inactivePath := fmt.Sprintf("/admin/clients/%d", h.InactiveClient.ID)
+	expectError(t, h.do(http.MethodPost, "/client/login", "", map[string]string{"email": h.InactiveClient.Email, "passcode": h.InactiveClient.Passcode}),
+		http.StatusForbidden, apierror.CodeForbidden)
+	expectStatus(t, h.do(http.MethodPatch, inactivePath, token, map[string]any{"is_active": true}), http.StatusOK)
+	h.clientLogin(h.InactiveClient)
+
+	// A client carried over from the legacy passcodes has to be given a new passcode.
+	createdToken := h.clientLogin(created)
+	if err := h.db.Model(&models.Client{}).Where("id = ?", created.ID).UpdateColumn("passcode_rotation_required", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	expectError(t, h.do(http.MethodPost, "/client/login", "", map[string]string{"email": created.Email, "passcode": created.Passcode}),
+		http.StatusForbidden, apierror.CodePasscodeRotationRequired)
+
+	rec = h.do(http.MethodPost, clientPath+"/passcode", token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	expectError(t, h.do(http.MethodGet, "/client/orders", createdToken, nil), http.StatusUnauthorized, apierror.CodeInvalidToken)
+	rotated := created
+	rotated.Passcode = decode[models.Client](t, rec).Passcode
+	h.clientLogin(rotated)
+	expectError(t, h.do(http.MethodPost, "/client/login", "", map[string]string{"email": created.Email, "passcode": created.Passcode}),
+		http.StatusUnauthorized, apierror.CodeUnauthorized)
+	// The failed login makes the client wait before the next attempt.
+	expectError(t, h.do(http.MethodPost, "/client/login", "", map[string]string{"email": rotated.Email, "passcode": rotated.Passcode}),
+		http.StatusTooManyRequests, apierror.CodeTooManyRequests)
+	expectStatus(t, h.do(http.MethodDelete, clientPath+"/lockout", token, nil), http.StatusOK)
+	h.clientLogin(rotated)
+
+	rec = h.do(http.MethodPost, clientPath+"/login-link", token, nil)
+	expectStatus(t, rec, http.StatusCreated)
+	link := decode[tokenBody](t, rec)
+	expectStatus(t, h.do(http.MethodPost, "/client/login", "", map[string]string{"token": link.Token}), http.StatusOK)
+	expectError(t, h.do(http.MethodPost, "/client/login", "", map[string]string{"token": link.Token}), http.StatusUnauthorized, apierror.CodeUnauthorized)
+
+	expectStatus(t, h.do(http.MethodDelete, clientPath, token, nil), http.StatusOK)
+	expectError(t, h.do(http.MethodGet, clientPath, token, nil), http.StatusNotFound, apierror.CodeNotFound)
+}
+
+type historyBody struct {
+	Status             string   `json:"status"`
+	AllowedTransitions []string `json:"allowed_transitions"`
+	History            []models.OrderStatusEvent
+}
+
+func TestOrdersAdmin(t *testing.T) {
+	h := newHarness(t)
+	token := h.adminToken()
+
+	expectError(t, h.do(http.MethodPost, "/admin/orders", token, map[string]any{
+		"client_id": h.Client.ID, "order_items": []map[string]any{{"menu_item_id": h.UnavailableMenu.ID, "quantity": 1}},
+	}), http.StatusBadRequest, apierror.CodeBadRequest)
+	expectError(t, h.do(http.MethodPost, "/admin/orders", token, map[string]any{
+		"client_id": 999999, "order_items": []map[string]any{{"menu_item_id": h.Menu.ID, "quantity": 1}},
+	}), http.StatusBadRequest, apierror.CodeBadRequest)
+	expectError(t, h.do(http.MethodPost, "/admin/orders", token, map[string]any{"client_id": h.Client.ID}),
+		http.StatusBadRequest, apierror.CodeValidation)
+
+	orderRequest := map[string]any{
+		"client_id":   h.Client.ID,
+		"order_items": []map[string]any{{"menu_item_id": h.Menu.ID, "quantity": 2}},
+		"notes":       "=1+1 no onions",
+	}
+	create := func(key string, body any) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/admin/orders", strings.NewReader(mustJSON(t, body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(middlewares.IdempotencyKeyHeader, key)
+		return h.send(req, token)
+	}
+	expectStatus(t, create("order-1", orderRequest), http.StatusCreated)
+	rec := create("order-1", orderRequest)
+	expectStatus(t, rec, http.StatusCreated)
+	if rec.Header().Get(middlewares.IdempotentReplayedHeader) != "true" {
+		t.Fatal("Expected the retried request to be replayed")
+	}
+	expectError(t, create("order-1", map[string]any{"client_id": h.OtherClient.ID, "order_items": orderRequest["order_items"]}),
+		http.StatusUnprocessableEntity, apierror.CodeIdempotencyKeyReused)
+
+	var count int64
+	h.db.Model(&models.Order{}).Count(&count)
+	if count != 1 {
+		t.Fatalf("Expected one order, got %d", count)
+	}
+	order := h.latestOrder(h.Client.ID)
+	if order.TotalAmount != money.FromFloat(500) || order.Status != models.OrderStatusPending {
+		t.Fatalf("Unexpected order total %s and status %s", order.TotalAmount, order.Status)
+	}
+	orderPath := fmt.Sprintf("/admin/orders/%d", order.ID)
+
+	rec = h.do(http.MethodGet, orderPath, token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if got := decode[models.Order](t, rec); len(got.OrderItems) != 1 || got.Client.ID != h.Client.ID {
+		t.Fatalf("Unexpected order %s", rec.Body.String())
+	}
+	rec = h.do(http.MethodGet, "/admin/orders?status=Pending&client_id="+strconv.Itoa(int(h.Client.ID)), token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if list := decode[listBody[models.Order]](t, rec); list.Total != 1 {
+		t.Fatalf("Expected one pending order, got %s", rec.Body.String())
+	}
+	expectError(t, h.do(http.MethodGet, "/admin/orders?status=Lost", token, nil), http.StatusBadRequest, apierror.CodeBadRequest)
+
+	body := expectError(t, h.do(http.MethodPut, orderPath+"/status", token, map[string]string{"status": models.OrderStatusDelivered}),
+		http.StatusConflict, apierror.CodeInvalidStatusTransition)
+	if body.Error.Details["current_status"] != models.OrderStatusPending {
+		t.Fatalf("Expected the current status in the details, got %v", body.Error.Details)
+	}
+	for _, status := range []string{models.OrderStatusAccepted, models.OrderStatusReady, models.OrderStatusDelivered} {
+		expectStatus(t, h.do(http.MethodPut, orderPath+"/status", token, map[string]string{"status": status}), http.StatusOK)
+	}
+
+	rec = h.do(http.MethodGet, orderPath+"/history", token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	history := decode[historyBody](t, rec)
+	if history.Status != models.OrderStatusDelivered || len(history.AllowedTransitions) != 0 || len(history.History) != 4 {
+		t.Fatalf("Unexpected order history %s", rec.Body.String())
+	}
+
+	rec = h.do(http.MethodGet, orderPath+"/receipt.pdf", token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if !bytes.HasPrefix(rec.Body.Bytes(), []byte("%PDF")) {
+		t.Fatal("Expected a PDF receipt")
+	}
+
+	rec = h.do(http.MethodGet, "/admin/orders/export", token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if !strings.Contains(rec.Body.String(), h.Menu.Name) {
+		t.Fatalf("Expected the order in the export, got %s", rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "'=1+1 no onions") {
+		t.Fatalf("Expected the notes to be escaped in the export, got %s", rec.Body.String())
+	}
+	expectStatus(t, h.do(http.MethodGet, "/admin/orders/export?format=xlsx", token, nil), http.StatusOK)
+	expectError(t, h.do(http.MethodGet, "/admin/orders/export?format=pdf", token, nil), http.StatusBadRequest, apierror.CodeBadRequest)
+
+	// The stream replays the events after last_event_id, here the status changes after the order
+	// was created, and then waits for more until the request is cancelled.
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/admin/orders/stream?last_event_id=1", nil).WithContext(ctx)
+	rec = h.send(req, token)
+	expectStatus(t, rec, http.StatusOK)
+	if got := strings.Count(rec.Body.String(), "event:"+events.OrderStatusChanged); got != 3 {
+		t.Errorf("Expected 3 %s events in the stream, got %s", events.OrderStatusChanged, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "event:"+events.OrderCreated) {
+		t.Errorf("Expected the stream to skip the events up to last_event_id, got %s", rec.Body.String())
+	}
+
+	expectStatus(t, h.do(http.MethodDelete, orderPath, token, nil), http.StatusOK)
+	expectError(t, h.do(http.MethodGet, orderPath, token, nil), http.StatusNotFound, apierror.CodeNotFound)
+}
+
+func TestOrderStockConsumption(t *testing.T) {
+	h := newHarness(t)
+	token := h.adminToken()
+
+	ingredient := models.Ingredient{Name: "Chicken", Unit: "pcs", StockQuantity: 3}
+	if err := h.db.Create(&ingredient).Error; err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, h.do(http.MethodPut, fmt.Sprintf("/admin/menus/%d/recipe", h.Menu.ID), token, map[string]any{
+		"items": []map[string]any{{"ingredient_id": ingredient.ID, "quantity_per_portion": 1}},
+	}), http.StatusOK)
+
+	expectStatus(t, h.do(http.MethodPost, "/admin/orders", token, map[string]any{
+		"client_id": h.Client.ID, "order_items": []map[string]any{{"menu_item_id": h.Menu.ID, "quantity": 3}},
+	}), http.StatusCreated)
+	order := h.latestOrder(h.Client.ID)
+	statusPath := fmt.Sprintf("/admin/orders/%d/status", order.ID)
+
+	stock := func() float64 {
+		var got models.Ingredient
+		if err := h.db.First(&got, ingredient.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		return got.StockQuantity
+	}
+	expectStatus(t, h.do(http.MethodPut, statusPath, token, map[string]string{"status": models.OrderStatusAccepted}), http.StatusOK)
+	if got := stock(); got != 0 {
+		t.Fatalf("Expected the stock to be used up, got %v", got)
+	}
+	rec := h.do(http.MethodGet, "/client/menus", "", nil)
+	expectStatus(t, rec, http.StatusOK)
+	if menus := decode[[]models.MenuItem](t, rec); len(menus) != 0 {
+		t.Fatalf("Expected no available menu items without stock, got %s", rec.Body.String())
+	}
+
+	expectStatus(t, h.do(http.MethodPut, statusPath, token, map[string]string{"status": models.OrderStatusCancelled, "reason": "Client left"}), http.StatusOK)
+	if got := stock(); got != 3 {
+		t.Fatalf("Expected the stock to be restored, got %v", got)
+	}
+
+	// Deleting an accepted order puts its ingredients back as well.
+	expectStatus(t, h.do(http.MethodPost, "/admin/orders", token, map[string]any{
+		"client_id": h.Client.ID, "order_items": []map[string]any{{"menu_item_id": h.Menu.ID, "quantity": 2}},
+	}), http.StatusCreated)
+	order = h.latestOrder(h.Client.ID)
+	expectStatus(t, h.do(http.MethodPut, fmt.Sprintf("/admin/orders/%d/status", order.ID), token,
+		map[string]string{"status": models.OrderStatusAccepted}), http.StatusOK)
+	if got := stock(); got != 1 {
+		t.Fatalf("Expected two portions to be taken out of stock, got %v", got)
+	}
+	expectStatus(t, h.do(http.MethodDelete, fmt.Sprintf("/admin/orders/%d", order.ID), token, nil), http.StatusOK)
+	if got := stock(); got != 3 {
+		t.Fatalf("Expected the stock to be restored after deleting the order, got %v", got)
+	}
+}
+
+func TestClientOrders(t *testing.T) {
+	h := newHarness(t)
+	token := h.clientLogin(h.Client)
+
+	expectError(t, h.do(http.MethodPost, "/client/login", "", map[string]string{"email": "nobody@example.com", "passcode": "WRONG"}),
+		http.StatusUnauthorized, apierror.CodeUnauthorized)
+	expectError(t, h.do(http.MethodPost, "/client/login", "", map[string]string{"email": h.OtherClient.Email}),
+		http.StatusBadRequest, apierror.CodeBadRequest)
+
+	expectError(t, h.do(http.MethodPost, "/client/orders", token, map[string]any{
+		"order_items": []map[string]any{{"menu_item_id": h.UnavailableMenu.ID, "quantity": 1}},
+	}), http.StatusBadRequest, apierror.CodeBadRequest)
+	expectStatus(t, h.do(http.MethodPost, "/client/orders", token, map[string]any{
+		"order_items": []map[string]any{{"menu_item_id": h.Menu.ID, "quantity": 1}},
+	}), http.StatusCreated)
+
+	rec := h.do(http.MethodGet, "/client/orders", token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	orders := decode[[]models.Order](t, rec)
+	if len(orders) != 1 {
+		t.Fatalf("Expected one order, got %s", rec.Body.String())
+	}
+	receiptPath := fmt.Sprintf("/client/orders/%d/receipt.pdf", orders[0].ID)
+	rec = h.do(http.MethodGet, receiptPath, token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if !bytes.HasPrefix(rec.Body.Bytes(), []byte("%PDF")) {
+		t.Fatal("Expected a PDF receipt")
+	}
+
+	otherToken := h.clientLogin(h.OtherClient)
+	expectError(t, h.do(http.MethodGet, receiptPath, otherToken, nil), http.StatusNotFound, apierror.CodeNotFound)
+	rec = h.do(http.MethodGet, "/client/orders", otherToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if orders := decode[[]models.Order](t, rec); len(orders) != 0 {
+		t.Fatalf("Expected no orders for another client, got %s", rec.Body.String())
+	}
+
+	rec = h.do(http.MethodPost, "/client/passcode", token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	rotated := h.Client
+	rotated.Passcode = decode[models.Client](t, rec).Passcode
+	// Rotating the passcode revokes the tokens issued before.
+	expectError(t, h.do(http.MethodGet, "/client/orders", token, nil), http.StatusUnauthorized, apierror.CodeInvalidToken)
+	token = h.clientLogin(rotated)
+
+	// Deactivating a client locks out their tokens.
+	if err := h.db.Model(&h.Client).UpdateColumn("is_active", false).Error; err != nil {
+		t.Fatal(err)
+	}
+	expectError(t, h.do(http.MethodGet, "/client/orders", token, nil), http.StatusForbidden, apierror.CodeForbidden)
+}
+
+func TestStatsAndAudit(t *testing.T) {
+	h := newHarness(t)
+	token := h.adminToken()
+
+	expectStatus(t, h.do(http.MethodPost, "/admin/orders", token, map[string]any{
+		"client_id": h.Client.ID, "order_items": []map[string]any{{"menu_item_id": h.Menu.ID, "quantity": 1}},
+	}), http.StatusCreated)
+
+	rec := h.do(http.MethodGet, "/admin/stats", token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	stats := decode[map[string]any](t, rec)
+	if len(stats) == 0 {
+		t.Fatal("Expected statistics")
+	}
+	today := time.Now().In(h.cfg.Business.Location).Format("2006-01-02")
+	expectStatus(t, h.do(http.MethodGet, "/admin/stats/sales?from="+today+"&to="+today, token, nil), http.StatusOK)
+	expectError(t, h.do(http.MethodGet, "/admin/stats/sales?from=yesterday", token, nil), http.StatusBadRequest, apierror.CodeBadRequest)
+	expectError(t, h.do(http.MethodGet, "/admin/stats/sales?from=0001-01-01&granularity=day", token, nil), http.StatusBadRequest, apierror.CodeBadRequest)
+	expectStatus(t, h.do(http.MethodGet, "/admin/stats/sales?from=2000-01-01&granularity=month", token, nil), http.StatusOK)
+
+	rec = h.do(http.MethodGet, "/admin/audit?entity_type=order", token, nil)
+	expectStatus(t, rec, http.StatusOK)
+	entries := decode[listBody[models.AuditLog]](t, rec)
+	if entries.Total != 1 || entries.Items[0].Action != models.AuditActionCreate {
+		t.Fatalf("Expected the order creation in the audit log, got %s", rec.Body.String())
+	}
+}
+
+func mustJSON(t *testing.T, v any) string {
+	t.Helper()
+	body, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}